@@ -5,9 +5,23 @@ import '@xterm/xterm/css/xterm.css'
 
 interface InteractiveTerminalProps {
     sessionId: string
+    // token authorizes input; only the client that started the deploy receives it.
+    token: string
+    // chunksB64 is the output streamed over the deploy SSE, used when the WebSocket is unavailable.
     chunksB64: string[]
 }
 
+// Control frames of the PTY WebSocket (output and input travel as binary frames).
+interface PTYFrame {
+    type: 'offset' | 'resize' | 'error' | 'close'
+    offset?: number
+    cols?: number
+    rows?: number
+    message?: string
+}
+
+const MAX_RECONNECTS = 10
+
 function bytesToBase64(bytes: Uint8Array): string {
     // Convert to base64 without blowing the stack
     let binary = ''
@@ -25,12 +39,36 @@ function base64ToBytes(b64: string): Uint8Array {
     return bytes
 }
 
-export function InteractiveTerminal({ sessionId, chunksB64 }: InteractiveTerminalProps) {
+async function apiUrl(path: string): Promise<string> {
+    const { getApiBaseUrl } = await import('../utils/api')
+    return `${await getApiBaseUrl()}${path}`
+}
+
+async function postPTY(path: string, body: Record<string, unknown>) {
+    await fetch(await apiUrl(path), {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    })
+}
+
+async function socketUrl(sessionId: string, token: string, offset: number): Promise<string> {
+    const path = `/api/pty/${encodeURIComponent(sessionId)}/ws?token=${encodeURIComponent(token)}&offset=${offset}`
+    const url = await apiUrl(path)
+    if (url.startsWith('http')) return url.replace(/^http/, 'ws')
+    const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+    return `${proto}//${window.location.host}${url}`
+}
+
+export function InteractiveTerminal({ sessionId, token, chunksB64 }: InteractiveTerminalProps) {
     const terminalRef = useRef<HTMLDivElement>(null)
     const xtermRef = useRef<Terminal | null>(null)
     const fitAddonRef = useRef<FitAddon | null>(null)
     const processedRef = useRef(0)
     const textDecoderRef = useRef<TextDecoder | null>(null)
+    // Until the WebSocket connects, output comes from chunksB64 and input goes over POST.
+    const socketRef = useRef<WebSocket | null>(null)
+    const usingSocketRef = useRef(false)
 
     useEffect(() => {
         if (!terminalRef.current || xtermRef.current) return
@@ -59,19 +97,87 @@ export function InteractiveTerminal({ sessionId, chunksB64 }: InteractiveTermina
         const handleResize = () => fitAddon.fit()
         window.addEventListener('resize', handleResize)
 
+        let disposed = false
+        let ended = false
+        let offset = 0
+        let reconnects = 0
+        let reconnectTimer: ReturnType<typeof setTimeout> | undefined
+        const encoder = new TextEncoder()
+        const socketDecoder = new TextDecoder()
+
+        const sendFrame = (frame: PTYFrame) => {
+            const ws = socketRef.current
+            if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(frame))
+        }
+
+        const connect = async () => {
+            if (disposed || ended) return
+            const url = await socketUrl(sessionId, token, offset)
+            if (disposed) return
+            const ws = new WebSocket(url)
+            ws.binaryType = 'arraybuffer'
+            socketRef.current = ws
+
+            ws.onopen = () => {
+                reconnects = 0
+                if (!usingSocketRef.current) {
+                    // Switch over from the SSE stream: the socket replays the output from the start.
+                    usingSocketRef.current = true
+                    term.reset()
+                }
+                sendFrame({ type: 'resize', cols: term.cols, rows: term.rows })
+            }
+            ws.onmessage = (event) => {
+                if (event.data instanceof ArrayBuffer) {
+                    const bytes = new Uint8Array(event.data)
+                    offset += bytes.length
+                    term.write(socketDecoder.decode(bytes, { stream: true }))
+                    return
+                }
+                const frame = JSON.parse(event.data) as PTYFrame
+                if (frame.type === 'offset') {
+                    const from = frame.offset ?? 0
+                    if (from !== offset) term.write('\r\n\x1b[2m[output skipped]\x1b[0m\r\n')
+                    offset = from
+                } else if (frame.type === 'close') {
+                    ended = true
+                } else if (frame.type === 'error') {
+                    console.error('PTY session error:', frame.message)
+                }
+            }
+            ws.onclose = () => {
+                if (socketRef.current === ws) socketRef.current = null
+                if (disposed || ended || reconnects >= MAX_RECONNECTS) return
+                reconnects++
+                reconnectTimer = setTimeout(connect, Math.min(500 * 2 ** reconnects, 10000))
+            }
+        }
+        if (token) connect()
+
+        // Keep the remote PTY the same size as the terminal, so TUIs lay out for what is shown.
+        const resizeDisposable = term.onResize(async ({ cols, rows }) => {
+            if (usingSocketRef.current) {
+                sendFrame({ type: 'resize', cols, rows })
+                return
+            }
+            try {
+                await postPTY('/api/pty/resize', { sessionId, token, cols, rows })
+            } catch (e) {
+                console.error('PTY resize failed:', e)
+            }
+        })
+        if (token) postPTY('/api/pty/resize', { sessionId, token, cols: term.cols, rows: term.rows }).catch(() => {})
+
         // Send keystrokes to backend
         const disposable = term.onData(async (data) => {
+            const bytes = encoder.encode(data)
+            const ws = socketRef.current
+            if (ws && ws.readyState === WebSocket.OPEN) {
+                ws.send(bytes)
+                return
+            }
             try {
-                const bytes = new TextEncoder().encode(data)
-                const dataB64 = bytesToBase64(bytes)
-                const { getApiBaseUrl } = await import('../utils/api')
-                const baseUrl = await getApiBaseUrl()
-                const url = `${baseUrl}/api/pty/input`
-                await fetch(url, {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify({ sessionId, dataB64 })
-                })
+                await postPTY('/api/pty/input', { sessionId, token, dataB64: bytesToBase64(bytes) })
             } catch (e) {
                 // best-effort; don't crash UI
                 console.error('PTY input failed:', e)
@@ -79,17 +185,29 @@ export function InteractiveTerminal({ sessionId, chunksB64 }: InteractiveTermina
         })
 
         return () => {
+            disposed = true
+            clearTimeout(reconnectTimer)
+            if (socketRef.current) {
+                sendFrame({ type: 'close' })
+                socketRef.current.close()
+                socketRef.current = null
+            }
+            usingSocketRef.current = false
             window.removeEventListener('resize', handleResize)
+            resizeDisposable.dispose()
             disposable.dispose()
             term.dispose()
+            xtermRef.current = null
+            processedRef.current = 0
         }
-    }, [sessionId])
+    }, [sessionId, token])
 
-    // Append new output chunks
+    // Append new output chunks from the SSE stream, unless the WebSocket delivers them.
     useEffect(() => {
         if (!xtermRef.current || !textDecoderRef.current) return
         const newChunks = chunksB64.slice(processedRef.current)
-        if (newChunks.length === 0) return
+        processedRef.current = chunksB64.length
+        if (newChunks.length === 0 || usingSocketRef.current) return
 
         for (const b64 of newChunks) {
             try {
@@ -100,10 +218,7 @@ export function InteractiveTerminal({ sessionId, chunksB64 }: InteractiveTermina
                 console.error('PTY decode/write failed:', e)
             }
         }
-        processedRef.current = chunksB64.length
     }, [chunksB64])
 
     return <div className="h-full w-full" ref={terminalRef} />
 }
-
-
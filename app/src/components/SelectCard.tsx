@@ -4,7 +4,9 @@ export function SelectCard({
     selected,
     onClick,
     icon,
-    badge
+    badge,
+    badgeTone = 'warning',
+    badgeTitle
 }: {
     title: string
     description: string
@@ -12,6 +14,8 @@ export function SelectCard({
     onClick: () => void
     icon?: React.ReactNode
     badge?: string
+    badgeTone?: 'warning' | 'success' | 'danger'
+    badgeTitle?: string
 }) {
     return (
         <div
@@ -43,7 +47,12 @@ export function SelectCard({
                 )}
             </div>
             {badge && (
-                <span className="absolute top-4 right-4 text-xs font-medium px-2 py-0.5 rounded-full bg-orange-100 text-orange-700">
+                <span
+                    title={badgeTitle}
+                    className={`absolute top-4 right-4 text-xs font-medium px-2 py-0.5 rounded-full
+                        ${badgeTone === 'success' ? 'bg-green-100 text-green-700' : badgeTone === 'danger' ? 'bg-red-100 text-red-700' : 'bg-orange-100 text-orange-700'}
+                    `}
+                >
                     {badge}
                 </span>
             )}
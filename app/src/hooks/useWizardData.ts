@@ -1,9 +1,11 @@
 import { useEffect, useState } from 'react'
-import type { App, Provider, Region, Size } from '../types'
+import type { App, BrokenApp, MarketplaceStatus, Provider, Region, Size } from '../types'
 import { apiFetch } from '../utils/api'
 
 export function useWizardData() {
   const [apps, setApps] = useState<App[]>([])
+  const [brokenApps, setBrokenApps] = useState<BrokenApp[]>([])
+  const [categories, setCategories] = useState<string[]>([])
   const [providers, setProviders] = useState<Provider[]>([])
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
@@ -11,12 +13,17 @@ export function useWizardData() {
   useEffect(() => {
     Promise.all([
         apiFetch<App[]>('/api/apps'),
-        apiFetch<Provider[]>('/api/providers')
+        apiFetch<Provider[]>('/api/providers'),
+        // Filters and the broken app list are extras; the wizard works without them.
+        apiFetch<string[]>('/api/apps/categories').catch(() => []),
+        apiFetch<MarketplaceStatus | null>('/api/marketplace/status').catch(() => null)
     ])
-    .then(([appsData, providersData]) => {
+    .then(([appsData, providersData, categoriesData, statusData]) => {
         // Ensure we always have arrays, even if API returns something unexpected
         setApps(Array.isArray(appsData) ? appsData : [])
         setProviders(Array.isArray(providersData) ? providersData : [])
+        setCategories(Array.isArray(categoriesData) ? categoriesData : [])
+        setBrokenApps(statusData?.broken ?? [])
     })
     .catch(err => {
         setError(err.message)
@@ -26,7 +33,7 @@ export function useWizardData() {
     .finally(() => setLoading(false))
   }, [])
 
-  return { apps, providers, loading, error }
+  return { apps, brokenApps, categories, providers, loading, error }
 }
 
 export function useRegions(provider: string) {
@@ -2,6 +2,7 @@ import { useState, useEffect, useRef } from 'react'
 import { useWizardData } from '../hooks/useWizardData'
 import { encryptForServer } from '../utils/crypto'
 import { apiFetch, getApiBaseUrl, getAssetUrl } from '../utils/api'
+import { hasComputedDefault, questionVisible, visibleAnswers } from '../utils/wizard'
 import type { Region, Size } from '../types'
 import { InstallerLayout, type Step } from '../components/InstallerLayout'
 import { StepApplication } from './wizard/StepApplication'
@@ -21,7 +22,7 @@ const STEPS: Step[] = [
 ]
 
 export function Wizard() {
-    const { apps, providers, loading, error } = useWizardData()
+    const { apps, brokenApps, categories, providers, loading, error } = useWizardData()
     
     // Ensure apps and providers are always arrays (defensive check)
     const safeApps = Array.isArray(apps) ? apps : []
@@ -29,6 +30,7 @@ export function Wizard() {
 
     // Form State
     const [appName, setAppName] = useState<string>('')
+    const [appVersion, setAppVersion] = useState<string>('')
     const [serverName, setServerName] = useState<string>('')
     const [providerName, setProviderName] = useState<string>('')
     const [configToken, setConfigToken] = useState('')
@@ -46,6 +48,7 @@ export function Wizard() {
     const [cloudflareTokenVerified, setCloudflareTokenVerified] = useState(false)
     const [cloudflareTokenError, setCloudflareTokenError] = useState<string | null>(null)
     const [appWizardAnswers, setAppWizardAnswers] = useState<Record<string, any>>({})
+    const [appWizardErrors, setAppWizardErrors] = useState<Record<string, string>>({})
     const [, setProviderHasCreds] = useState<boolean | null>(null)
 
     // Wizard State
@@ -59,6 +62,8 @@ export function Wizard() {
     const [deployComplete, setDeployComplete] = useState(false)
     const [deployedUrl, setDeployedUrl] = useState<string>('')
     const [ptySessionId, setPtySessionId] = useState<string>('')
+    const [ptyToken, setPtyToken] = useState<string>('')
+    const [deploymentId, setDeploymentId] = useState<string>('')
     const [ptyChunksB64, setPtyChunksB64] = useState<string[]>([])
     const [ttyAutoAnswering, setTtyAutoAnswering] = useState(false)
 
@@ -100,8 +105,21 @@ export function Wizard() {
             if (!q?.id) continue
             if (q.type === 'boolean') {
                 defaults[q.id] = typeof q.default === 'boolean' ? q.default : true
-            } else if (q.type === 'text') {
-                defaults[q.id] = typeof q.default === 'string' ? q.default : ''
+            } else if (['text', 'password', 'email', 'url', 'domain'].includes(q.type)) {
+                // Computed defaults ("{opts.Email}") are left blank for the server to fill in.
+                defaults[q.id] = typeof q.default === 'string' && !hasComputedDefault(q) ? q.default : ''
+            } else if (q.type === 'number') {
+                defaults[q.id] = typeof q.default === 'number' ? q.default : ''
+            } else if (q.type === 'select') {
+                const choices = q.choices || []
+                defaults[q.id] = typeof q.default === 'string'
+                    ? q.default
+                    : (choices.find(c => c.default === true)?.name ?? '')
+            } else if (q.type === 'multiselect') {
+                const choices = q.choices || []
+                defaults[q.id] = Array.isArray(q.default)
+                    ? q.default
+                    : choices.filter(c => c.default === true).map(c => c.name)
             } else if (q.type === 'choice') {
                 const choices = q.choices || []
                 const trueDefaults = choices.filter(c => c.default === true).map(c => c.name)
@@ -114,21 +132,14 @@ export function Wizard() {
             }
         }
         setAppWizardAnswers(prev => ({ ...defaults, ...prev }))
+        setAppWizardErrors({})
     }, [selectedApp])
     
     // Helper function to get app logo (with backend URL prefix for desktop mode)
     const getAppLogo = (name: string): string | undefined => {
-        const logoMap: Record<string, string> = {
-            'openreplay': '/openreplay.svg',
-            'openpanel': '/openpanel.svg',
-            'plausible': '/plausible.svg',
-            'umami': '/umami.svg',
-            'swetrix': '/swetrix.png',
-            'rybbit': '/rybbit.svg',
-        }
-        const logoPath = logoMap[name.toLowerCase()]
-        if (!logoPath) return undefined
-        return getAssetUrl(logoPath)
+        const iconUrl = safeApps.find(a => a.name === name)?.icon_url
+        if (!iconUrl) return undefined
+        return iconUrl.startsWith('/') ? getAssetUrl(iconUrl) : iconUrl
     }
     
     // Helper function to get provider logo (with backend URL prefix for desktop mode)
@@ -199,7 +210,7 @@ export function Wizard() {
             setSizes([])
             setSize('')
             setSizesLoading(true)
-            apiFetch<Size[]>(`/api/sizes?provider=${providerName}&region=${encodeURIComponent(region)}`)
+            apiFetch<Size[]>(`/api/sizes?provider=${providerName}&region=${encodeURIComponent(region)}${appName ? `&app=${encodeURIComponent(appName)}` : ''}`)
                 .then(data => setSizes(data || []))
                 .catch(err => {
                     if (err.message?.includes('401') || err.message?.includes('Authentication')) {
@@ -213,7 +224,7 @@ export function Wizard() {
             setSizes([])
             setSize('')
         }
-    }, [providerName, region, showConfig])
+    }, [providerName, region, showConfig, appName])
 
     // Domain Check Logic for Auto mode
     useEffect(() => {
@@ -245,6 +256,11 @@ export function Wizard() {
         return () => clearTimeout(timeoutId)
     }, [domainAuto])
 
+    // Versions belong to one app; switching apps goes back to its default.
+    useEffect(() => {
+        setAppVersion('')
+    }, [appName])
+
     // Auto-fill server name when app changes
     useEffect(() => {
         if (appName && !serverName) {
@@ -601,6 +617,7 @@ export function Wizard() {
     // Wizard State Object
     const wizardState: WizardState = {
         appName,
+        appVersion,
         serverName,
         providerName,
         configToken,
@@ -633,11 +650,13 @@ export function Wizard() {
         selectedApp,
         selectedProvider,
         appWizardAnswers,
+        appWizardErrors,
     }
 
     // Wizard Actions Object
     const wizardActions: WizardActions = {
         setAppName,
+        setAppVersion,
         setServerName,
         setProviderName,
         setConfigToken,
@@ -658,6 +677,12 @@ export function Wizard() {
         handleSaveGcpProjectSelection,
         setAppWizardAnswer: (id: string, value: any) => {
             setAppWizardAnswers(prev => ({ ...prev, [id]: value }))
+            setAppWizardErrors(prev => {
+                if (!(id in prev)) return prev
+                const next = { ...prev }
+                delete next[id]
+                return next
+            })
         },
     }
 
@@ -666,6 +691,8 @@ export function Wizard() {
         setDeployComplete(false)
         setDeployedUrl('')
         setPtySessionId('')
+        setPtyToken('')
+        setDeploymentId('')
         setPtyChunksB64([])
         setTtyAutoAnswering(false)
         setLogs([])
@@ -686,6 +713,7 @@ export function Wizard() {
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
                     app: appName,
+                    appVersion: appVersion || undefined,
                     provider: providerName,
                     region,
                     size,
@@ -695,11 +723,25 @@ export function Wizard() {
                     cloudflareToken: cfEnc ? cfEnc.ciphertextB64 : undefined,
                     cloudflareTokenKeyId: cfEnc ? cfEnc.keyId : undefined,
                     cloudflareAccountId: dnsMode === 'auto' && detectedDomainProvider === 'cloudflare' ? cloudflareAccountId : undefined,
-                    wizardAnswers: appWizardAnswers,
+                    wizardAnswers: visibleAnswers(selectedApp?.wizard?.application?.custom_questions || [], appWizardAnswers),
                 })
             })
             if (!response.ok) {
                 const text = await response.text()
+                if (response.status === 400) {
+                    // Invalid wizard answers come back keyed by question ID; send the user back to fix them.
+                    try {
+                        const body = JSON.parse(text) as { error?: string; errors?: Record<string, string> }
+                        if (body.errors && Object.keys(body.errors).length > 0) {
+                            setAppWizardErrors(body.errors)
+                            setDeploying(false)
+                            setCurrentStepIndex(0)
+                            return
+                        }
+                    } catch {
+                        // Not a validation error; fall through.
+                    }
+                }
                 throw new Error(`Deployment failed: ${response.status} ${text}`)
             }
 
@@ -734,8 +776,11 @@ export function Wizard() {
                                     const msg = line.slice(6).trim()
                                     if (msg) {
                                         if (msg.startsWith('[SELFHOSTED::PTY_SESSION]')) {
-                                            const id = msg.replace('[SELFHOSTED::PTY_SESSION]', '').trim()
-                                            if (id) setPtySessionId(id)
+                                            const [id, token = ''] = msg.replace('[SELFHOSTED::PTY_SESSION]', '').trim().split(/\s+/)
+                                            if (id) {
+                                                setPtySessionId(id)
+                                                setPtyToken(token)
+                                            }
                                             continue
                                         }
                                         if (msg.startsWith('[SELFHOSTED::PTY]')) {
@@ -751,6 +796,10 @@ export function Wizard() {
                                             continue
                                         }
 
+                                        if (msg.startsWith('[SELFHOSTED::DEPLOYMENT]')) {
+                                            setDeploymentId(msg.replace('[SELFHOSTED::DEPLOYMENT]', '').trim())
+                                            continue
+                                        }
                                         if (msg === '[SELFHOSTED::DONE]') {
                                             deploymentComplete = true
                                             setDeploying(false)
@@ -796,8 +845,11 @@ export function Wizard() {
                                 lastMessageTime = Date.now() // Update last message time
                                 
                                 if (msg.startsWith('[SELFHOSTED::PTY_SESSION]')) {
-                                    const id = msg.replace('[SELFHOSTED::PTY_SESSION]', '').trim()
-                                    if (id) setPtySessionId(id)
+                                    const [id, token = ''] = msg.replace('[SELFHOSTED::PTY_SESSION]', '').trim().split(/\s+/)
+                                    if (id) {
+                                        setPtySessionId(id)
+                                        setPtyToken(token)
+                                    }
                                     continue
                                 }
                                 if (msg.startsWith('[SELFHOSTED::PTY]')) {
@@ -812,6 +864,10 @@ export function Wizard() {
                                     continue
                                 }
 
+                                if (msg.startsWith('[SELFHOSTED::DEPLOYMENT]')) {
+                                    setDeploymentId(msg.replace('[SELFHOSTED::DEPLOYMENT]', '').trim())
+                                    continue
+                                }
                                 if (msg === '[SELFHOSTED::DONE]') {
                                     deploymentComplete = true
                                     setDeploying(false)
@@ -874,7 +930,7 @@ export function Wizard() {
         await fetch(`${baseUrl}/api/pty/input`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ sessionId, dataB64 })
+            body: JSON.stringify({ sessionId, token: ptyToken, dataB64 })
         })
     }
 
@@ -888,6 +944,8 @@ export function Wizard() {
         setTtyAutoAnswering(true)
         try {
             for (const q of qs) {
+                // Hidden questions aren't asked by the installer either.
+                if (!questionVisible(q, appWizardAnswers)) continue
                 const answer = appWizardAnswers[q.id]
 
                 if (q.type === 'boolean') {
@@ -897,17 +955,17 @@ export function Wizard() {
                     continue
                 }
 
-                if (q.type === 'text') {
-                    const v = typeof answer === 'string' ? answer : ''
+                if (['text', 'password', 'email', 'url', 'domain', 'number'].includes(q.type)) {
+                    const v = typeof answer === 'string' || typeof answer === 'number' ? String(answer) : ''
                     await sendPTY(ptySessionId, v + '\r')
                     await delay(400)
                     continue
                 }
 
-                if (q.type === 'choice') {
+                if (q.type === 'choice' || q.type === 'select' || q.type === 'multiselect') {
                     const choices = q.choices || []
                     const defaultTrue = choices.filter(c => c.default === true).map(c => c.name)
-                    const isMulti = defaultTrue.length > 1
+                    const isMulti = q.type === 'multiselect' || (q.type === 'choice' && defaultTrue.length > 1)
 
                     if (isMulti) {
                         const desired = new Set<string>(Array.isArray(answer) ? answer : defaultTrue)
@@ -971,6 +1029,8 @@ export function Wizard() {
             {currentStepIndex === 0 && (
                 <StepApplication
                     apps={safeApps}
+                    brokenApps={brokenApps}
+                    categories={categories}
                     state={wizardState}
                     actions={wizardActions}
                     getAppLogo={getAppLogo}
@@ -980,7 +1040,9 @@ export function Wizard() {
             {/* Step 2: Cloud Config */}
             {currentStepIndex === 1 && (
                 <StepCloudProvider
-                    providers={safeProviders}
+                    providers={selectedApp?.providers?.length
+                        ? safeProviders.filter(p => selectedApp.providers!.includes(p.name))
+                        : safeProviders}
                     state={wizardState}
                     actions={wizardActions}
                     getProviderLogo={getProviderLogo}
@@ -1010,6 +1072,7 @@ export function Wizard() {
                 <StepInstallation
                     logs={logs}
                 ptySessionId={ptySessionId}
+                ptyToken={ptyToken}
                 ptyChunksB64={ptyChunksB64}
                 hasTTYAutomation={(selectedApp?.wizard?.application?.custom_questions || []).length > 0}
                 ttyAutoAnswering={ttyAutoAnswering}
@@ -1017,6 +1080,7 @@ export function Wizard() {
                     deployError={deployError}
                     deployComplete={deployComplete}
                     deployedUrl={deployedUrl}
+                    deploymentId={deploymentId}
                     showFullLogs={showFullLogs}
                     setShowFullLogs={setShowFullLogs}
                     setDeployError={setDeployError}
@@ -1,17 +1,77 @@
-import { Server } from 'lucide-react'
+import { useState } from 'react'
+import { Search, Server } from 'lucide-react'
 import { SelectCard } from '../../components/SelectCard'
-import type { App, WizardQuestion } from '../../types'
+import type { App, BrokenApp, WizardQuestion } from '../../types'
 import type { WizardState, WizardActions } from './types'
+import { defaultPreview, hasComputedDefault, questionVisible } from '../../utils/wizard'
+
+// verificationBadge describes an app's signature check on its card. Bundled apps and apps
+// implemented in Go get no badge.
+function verificationBadge(app: App): { text: string; tone: 'warning' | 'success' | 'danger'; title: string } | null {
+    const v = app.verification
+    if (!v) return null
+    const from = v.source.startsWith('catalog:') ? `from ${v.source.slice('catalog:'.length)}` : 'bundled'
+    switch (v.status) {
+        case 'verified':
+            return { text: 'Signed', tone: 'success', title: `Signed by ${v.key} (${from})` }
+        case 'unsigned':
+            return { text: 'Unsigned', tone: 'warning', title: `Not signed (${from}); its steps run as root on your server` }
+        case 'untrusted':
+            return { text: 'Untrusted', tone: 'warning', title: `Signed by a key you don't trust (${from}): ${v.message ?? ''}` }
+        case 'invalid':
+            return { text: 'Bad signature', tone: 'danger', title: `The signature doesn't match (${from}): ${v.message ?? ''}` }
+        default:
+            return null
+    }
+}
 
 interface StepApplicationProps {
     apps: App[]
+    brokenApps?: BrokenApp[]
+    categories?: string[]
     state: WizardState
     actions: WizardActions
     getAppLogo: (name: string) => string | undefined
 }
 
-export function StepApplication({ apps, state, actions, getAppLogo }: StepApplicationProps) {
-    const questions = state.selectedApp?.wizard?.application?.custom_questions || []
+// matchesSearch mirrors the API's ?q= search: every word must appear in the name, display name,
+// description, category or tags.
+function matchesSearch(app: App, search: string): boolean {
+    const haystack = [app.name, app.display_name, app.description, app.category ?? '', ...(app.tags ?? [])]
+        .join('\n')
+        .toLowerCase()
+    return search.toLowerCase().split(/\s+/).filter(Boolean).every(word => haystack.includes(word))
+}
+
+export function StepApplication({ apps, brokenApps = [], categories = [], state, actions, getAppLogo }: StepApplicationProps) {
+    const [search, setSearch] = useState('')
+    const [category, setCategory] = useState('')
+    // The selected app stays visible while filtering.
+    const shownApps = apps.filter(app =>
+        app.name === state.appName || ((!category || app.category === category) && matchesSearch(app, search)))
+
+    // Questions whose show_if doesn't hold for the current answers are hidden.
+    const questions = (state.selectedApp?.wizard?.application?.custom_questions || [])
+        .filter((q: WizardQuestion) => questionVisible(q, state.appWizardAnswers))
+
+    const inputTypes: Record<string, string> = {
+        text: 'text',
+        password: 'password',
+        email: 'email',
+        url: 'url',
+        domain: 'text',
+        number: 'number',
+    }
+
+    const renderHelp = (q: WizardQuestion) => {
+        const error = state.appWizardErrors[q.id]
+        return (
+            <>
+                {q.help && <div className="text-xs text-zinc-500 mt-1">{q.help}</div>}
+                {error && <div className="text-xs text-red-600 mt-1">{q.name} {error}</div>}
+            </>
+        )
+    }
 
     const renderQuestion = (q: WizardQuestion) => {
         const value = state.appWizardAnswers[q.id]
@@ -27,31 +87,44 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
                     />
                     <div className="flex-1">
                         <div className="text-sm font-medium text-zinc-900">{q.name}</div>
-                        <div className="text-xs text-zinc-500">Used to auto-answer interactive setup.</div>
+                        <div className="text-xs text-zinc-500">{q.help || 'Used to auto-answer interactive setup.'}</div>
+                        {state.appWizardErrors[q.id] && <div className="text-xs text-red-600 mt-1">{q.name} {state.appWizardErrors[q.id]}</div>}
                     </div>
                 </label>
             )
         }
 
-        if (q.type === 'text') {
+        if (q.type in inputTypes) {
+            const isNumber = q.type === 'number'
             return (
                 <div className="p-3 rounded-lg border border-zinc-200 bg-white">
                     <label className="block text-sm font-medium text-zinc-900 mb-2">{q.name}</label>
                     <input
-                        type="text"
+                        type={inputTypes[q.type]}
                         className="w-full bg-white border border-zinc-200 rounded-lg px-3 py-2 text-zinc-900 focus:ring-2 focus:ring-[#F38020]/20 focus:border-[#F38020] outline-none transition-all placeholder:text-zinc-400"
-                        value={typeof value === 'string' ? value : ''}
-                        onChange={(e) => actions.setAppWizardAnswer(q.id, e.target.value)}
-                        placeholder="Optional"
+                        value={typeof value === 'string' || typeof value === 'number' ? value : ''}
+                        onChange={(e) => {
+                            const raw = e.target.value
+                            actions.setAppWizardAnswer(q.id, isNumber && raw !== '' ? Number(raw) : raw)
+                        }}
+                        placeholder={q.placeholder || (hasComputedDefault(q) ? `Default: ${defaultPreview(q, state.appWizardAnswers)}` : (q.required ? '' : 'Optional'))}
+                        required={q.required}
+                        pattern={q.pattern}
+                        min={q.min}
+                        max={q.max}
+                        minLength={q.min_length}
+                        maxLength={q.max_length}
+                        autoComplete={q.type === 'password' ? 'new-password' : undefined}
                     />
+                    {renderHelp(q)}
                 </div>
             )
         }
 
-        if (q.type === 'choice') {
+        if (q.type === 'choice' || q.type === 'select' || q.type === 'multiselect') {
             const choices = q.choices || []
             const defaults = new Set(choices.filter(c => c.default === true).map(c => c.name))
-            const isMulti = defaults.size > 1
+            const isMulti = q.type === 'multiselect' || (q.type === 'choice' && defaults.size > 1)
 
             if (isMulti) {
                 const selected = new Set<string>(Array.isArray(value) ? value : Array.from(defaults))
@@ -76,11 +149,12 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
                                 </label>
                             ))}
                         </div>
+                        {renderHelp(q)}
                     </div>
                 )
             }
 
-            const current = typeof value === 'string' ? value : (choices.find(c => c.default === true)?.name ?? choices[0]?.name ?? '')
+            const current = typeof value === 'string' ? value : (choices.find(c => c.default === true)?.name ?? (q.type === 'choice' ? choices[0]?.name : '') ?? '')
             return (
                 <div className="p-3 rounded-lg border border-zinc-200 bg-white">
                     <div className="text-sm font-medium text-zinc-900 mb-2">{q.name}</div>
@@ -98,6 +172,7 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
                             </label>
                         ))}
                     </div>
+                    {renderHelp(q)}
                 </div>
             )
         }
@@ -110,16 +185,47 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
         <div className="space-y-6 animate-in fade-in slide-in-from-bottom-4 duration-500">
             <div>
                 <h2 className="text-lg font-medium text-zinc-900 mb-4">Select Application</h2>
+                <div className="flex flex-col sm:flex-row gap-3 mb-4">
+                    <div className="relative flex-1">
+                        <Search size={16} className="absolute left-3 top-1/2 -translate-y-1/2 text-zinc-400" />
+                        <input
+                            type="search"
+                            className="w-full bg-white border border-zinc-200 rounded-lg pl-9 pr-3 py-2 text-sm text-zinc-900 focus:ring-2 focus:ring-[#F38020]/20 focus:border-[#F38020] outline-none transition-all placeholder:text-zinc-400"
+                            placeholder="Search apps"
+                            value={search}
+                            onChange={e => setSearch(e.target.value)}
+                        />
+                    </div>
+                    {categories.length > 1 && (
+                        <select
+                            className="bg-white border border-zinc-200 rounded-lg px-3 py-2 text-sm text-zinc-900 focus:ring-2 focus:ring-[#F38020]/20 focus:border-[#F38020] outline-none"
+                            value={category}
+                            onChange={e => setCategory(e.target.value)}
+                        >
+                            <option value="">All categories</option>
+                            {categories.map(c => (
+                                <option key={c} value={c}>{c.replace(/-/g, ' ')}</option>
+                            ))}
+                        </select>
+                    )}
+                </div>
+                {shownApps.length === 0 && (
+                    <p className="text-sm text-zinc-500">No apps match your search.</p>
+                )}
                 <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
-                    {apps.map(app => {
+                    {shownApps.map(app => {
                         const appLogo = getAppLogo(app.name)
+                        const badge = verificationBadge(app)
                         return (
                             <SelectCard
                                 key={app.name}
-                                title={app.name}
+                                title={app.display_name || app.name}
                                 description={app.description}
                                 selected={state.appName === app.name}
                                 onClick={() => actions.setAppName(app.name)}
+                                badge={badge?.text}
+                                badgeTone={badge?.tone}
+                                badgeTitle={badge?.title}
                                 icon={appLogo ? (
                                     <img src={appLogo} alt={app.name} className="w-8 h-8 object-contain" />
                                 ) : (
@@ -129,6 +235,25 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
                         )
                     })}
                 </div>
+                {brokenApps.length > 0 && (
+                    <details className="mt-4 text-xs text-zinc-500">
+                        <summary className="cursor-pointer">
+                            {brokenApps.length} app file(s) failed to load
+                        </summary>
+                        <ul className="mt-2 space-y-2">
+                            {brokenApps.map(b => (
+                                <li key={b.file} className="p-2 rounded border border-red-200 bg-red-50">
+                                    <div className="font-medium text-red-700">{b.name || b.file}</div>
+                                    <div className="text-zinc-500">{b.file}</div>
+                                    {b.kept_previous && (
+                                        <div className="text-zinc-500">Still serving the last good version.</div>
+                                    )}
+                                    <pre className="mt-1 whitespace-pre-wrap text-red-600">{b.error}</pre>
+                                </li>
+                            ))}
+                        </ul>
+                    </details>
+                )}
             </div>
 
             {state.appName && (
@@ -144,6 +269,23 @@ export function StepApplication({ apps, state, actions, getAppLogo }: StepApplic
                 </div>
             )}
 
+            {state.appName && (state.selectedApp?.versions?.length ?? 0) > 1 && (
+                <div className="animate-in fade-in slide-in-from-bottom-2 duration-300">
+                    <label className="block text-sm font-medium text-zinc-500 mb-2">Version</label>
+                    <select
+                        className="w-full bg-white border border-zinc-200 rounded-lg px-4 py-3 text-zinc-900 focus:ring-2 focus:ring-blue-500/20 focus:border-blue-500 outline-none transition-all"
+                        value={state.appVersion || state.selectedApp?.default_version || ''}
+                        onChange={e => actions.setAppVersion(e.target.value)}
+                    >
+                        {state.selectedApp!.versions!.map(v => (
+                            <option key={v} value={v}>
+                                {`${v}${v === state.selectedApp?.default_version ? ' (default)' : v === state.selectedApp?.latest_version ? ' (latest)' : ''}`}
+                            </option>
+                        ))}
+                    </select>
+                </div>
+            )}
+
             {state.appName && questions.length > 0 && (
                 <div className="animate-in fade-in slide-in-from-bottom-2 duration-300">
                     <h3 className="text-sm font-medium text-zinc-900 mb-2">Setup options</h3>
@@ -1,12 +1,14 @@
 import { Server, Shield } from 'lucide-react'
-import { useEffect } from 'react'
+import { useEffect, useState } from 'react'
 import { TerminalView } from '../../components/TerminalView'
 import { TRexGame } from '../../components/TRexGame'
 import { InteractiveTerminal } from '../../components/InteractiveTerminal'
+import { getApiBaseUrl } from '../../utils/api'
 
 interface StepInstallationProps {
     logs: string[]
     ptySessionId: string
+    ptyToken: string
     ptyChunksB64: string[]
     hasTTYAutomation: boolean
     ttyAutoAnswering: boolean
@@ -14,6 +16,8 @@ interface StepInstallationProps {
     deployError: string | null
     deployComplete: boolean
     deployedUrl: string
+    // deploymentId is the saved deployment record, sent once the deploy finishes.
+    deploymentId: string
     showFullLogs: boolean
     setShowFullLogs: (show: boolean) => void
     setDeployError: (error: string | null) => void
@@ -25,6 +29,7 @@ interface StepInstallationProps {
 export function StepInstallation({
     logs,
     ptySessionId,
+    ptyToken,
     ptyChunksB64,
     hasTTYAutomation,
     ttyAutoAnswering,
@@ -32,6 +37,7 @@ export function StepInstallation({
     deployError,
     deployComplete,
     deployedUrl,
+    deploymentId,
     showFullLogs,
     setShowFullLogs,
     setDeployError,
@@ -39,9 +45,30 @@ export function StepInstallation({
     size,
     region
 }: StepInstallationProps) {
+    const [shell, setShell] = useState<{ sessionId: string; token: string } | null>(null)
+    const [shellError, setShellError] = useState<string | null>(null)
+
     useEffect(() => {
-        if (ptySessionId) setShowFullLogs(true)
-    }, [ptySessionId, setShowFullLogs])
+        if (ptySessionId || shell) setShowFullLogs(true)
+    }, [ptySessionId, shell, setShowFullLogs])
+
+    // openShell starts a root shell on the server; the API only allows it from localhost.
+    const openShell = async () => {
+        if (!window.confirm('Open a root shell on the deployed server? Commands you type run on the server directly.')) return
+        setShellError(null)
+        try {
+            const res = await fetch(`${await getApiBaseUrl()}/api/deployments/${encodeURIComponent(deploymentId)}/shell`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ confirm: true })
+            })
+            if (!res.ok) throw new Error((await res.text()).trim() || res.statusText)
+            const data = await res.json()
+            setShell({ sessionId: data.sessionId, token: data.token })
+        } catch (e) {
+            setShellError(e instanceof Error ? e.message : String(e))
+        }
+    }
 
     return (
         <div className="h-full flex flex-col animate-in fade-in zoom-in-95 duration-500">
@@ -85,6 +112,9 @@ export function StepInstallation({
                                 </a>
                             </p>
                         ) : null}
+                        {shellError ? (
+                            <p className="text-sm text-red-600 mt-1">Shell: {shellError}</p>
+                        ) : null}
                     </div>
                 </div>
 
@@ -110,6 +140,14 @@ export function StepInstallation({
                                 Open App
                             </a>
                         ) : null}
+                        {deployComplete && deploymentId && !shell ? (
+                            <button
+                                onClick={openShell}
+                                className="px-4 py-2 bg-white hover:bg-zinc-50 text-zinc-700 text-sm font-medium rounded-lg transition-colors border border-zinc-200 shadow-sm"
+                            >
+                                Open Shell
+                            </button>
+                        ) : null}
                         <button
                             onClick={() => setShowFullLogs(!showFullLogs)}
                             className="text-sm text-zinc-500 hover:text-zinc-900 transition-colors flex items-center gap-2"
@@ -125,7 +163,11 @@ export function StepInstallation({
                 ${showFullLogs ? 'flex-1 opacity-100' : 'h-24 opacity-100 ring-1 ring-zinc-900/5'}
             `}>
                 {showFullLogs ? (
-                    ptySessionId ? (
+                    shell ? (
+                        <div className="h-full p-2 bg-[#0b0b0f]">
+                            <InteractiveTerminal sessionId={shell.sessionId} token={shell.token} chunksB64={[]} />
+                        </div>
+                    ) : ptySessionId ? (
                         <div className="h-full p-2 bg-[#0b0b0f]">
                             {hasTTYAutomation && (
                                 <div className="mb-2 flex items-center justify-between rounded-lg border border-zinc-700/40 bg-zinc-900/40 px-3 py-2">
@@ -145,7 +187,7 @@ export function StepInstallation({
                                     </button>
                                 </div>
                             )}
-                            <InteractiveTerminal sessionId={ptySessionId} chunksB64={ptyChunksB64} />
+                            <InteractiveTerminal sessionId={ptySessionId} token={ptyToken} chunksB64={ptyChunksB64} />
                         </div>
                     ) : (
                         <TerminalView logs={logs} />
@@ -163,7 +205,7 @@ export function StepInstallation({
                 )}
             </div>
 
-            {!showFullLogs && !ptySessionId && (
+            {!showFullLogs && !ptySessionId && !shell && (
                 <>
                     <div className="mt-8 grid grid-cols-1 md:grid-cols-2 gap-4 animate-in slide-in-from-bottom-2 duration-700 delay-200">
                         <div className="p-4 rounded-xl bg-white border border-zinc-200 shadow-sm flex gap-3">
@@ -21,7 +21,12 @@ export function StepReview({ state, regions, sizes }: StepReviewProps) {
                 <dl className="grid grid-cols-2 gap-x-4 gap-y-4 text-sm">
                     <div className="col-span-1">
                         <dt className="text-zinc-500">Application</dt>
-                        <dd className="text-zinc-900 font-medium">{state.appName}</dd>
+                        <dd className="text-zinc-900 font-medium">
+                            {state.appName}
+                            {(state.appVersion || state.selectedApp?.default_version) && (
+                                <span className="text-zinc-500 font-normal">@{state.appVersion || state.selectedApp?.default_version}</span>
+                            )}
+                        </dd>
                     </div>
                     <div className="col-span-1">
                         <dt className="text-zinc-500">Service Name</dt>
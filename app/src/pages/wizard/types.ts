@@ -1,6 +1,6 @@
 // Import and re-export shared types
-import type { App, Provider, Region, Size, WizardQuestion, WizardChoice } from '../../types'
-export type { App, Provider, Region, Size, WizardQuestion, WizardChoice }
+import type { App, Provider, Region, Size, WizardQuestion, WizardChoice, WizardConditionTerm } from '../../types'
+export type { App, Provider, Region, Size, WizardQuestion, WizardChoice, WizardConditionTerm }
 
 export interface GCPBillingAccount {
     name: string;
@@ -17,6 +17,8 @@ export interface GCPProject {
 export interface WizardState {
     // Form State
     appName: string;
+    // Version to deploy; empty deploys the app's default version.
+    appVersion: string;
     serverName: string;
     providerName: string;
     configToken: string;
@@ -58,10 +60,13 @@ export interface WizardState {
 
     // App wizard (interactive installer config)
     appWizardAnswers: Record<string, any>;
+    // Server-side validation errors, keyed by question ID
+    appWizardErrors: Record<string, string>;
 }
 
 export interface WizardActions {
     setAppName: (name: string) => void;
+    setAppVersion: (version: string) => void;
     setServerName: (name: string) => void;
     setProviderName: (name: string) => void;
     setConfigToken: (token: string) => void;
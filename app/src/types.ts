@@ -1,9 +1,28 @@
 export interface App {
   name: string
   description: string
+  // Metadata from the app spec (see `metadata:` in the DSL).
+  display_name: string
+  category?: string
+  tags?: string[]
+  homepage?: string
+  repository?: string
+  license?: string
+  version?: string
+  // Versions a deploy can pick (see `versions:` in the DSL), newest first.
+  versions?: string[]
+  default_version?: string
+  latest_version?: string
+  // Where to load the icon from: an https URL or /api/apps/<name>/icon.
+  icon_url?: string
   min_cpus: number
   min_memory: number
+  min_disk?: number
+  os?: string
   domain_hint?: string
+  providers?: string[]
+  // Signature check of a marketplace or catalog app; see `selfhost app sign`.
+  verification?: AppVerification
   wizard?: {
     application?: {
       custom_questions?: WizardQuestion[]
@@ -11,13 +30,73 @@ export interface App {
   }
 }
 
+// BrokenApp is a marketplace or catalog file that failed to load.
+export interface BrokenApp {
+  name?: string
+  file: string
+  source?: string
+  error: string
+  // kept_previous is set when the version loaded before the failed reload is still offered.
+  kept_previous?: boolean
+}
+
+// MarketplaceStatus is what /api/marketplace/status reports about the loaded marketplace.
+export interface MarketplaceStatus {
+  layers: string[]
+  apps: string[]
+  catalogs?: string[]
+  signature_policy: string
+  warnings?: string[]
+  broken?: BrokenApp[]
+  loaded_at: string
+  checked_at: string
+  error?: string
+}
+
+export interface AppVerification {
+  status: 'verified' | 'unsigned' | 'untrusted' | 'invalid' | 'bundled' | 'unchecked'
+  source: string
+  key?: string
+  message?: string
+}
+
+export type WizardQuestionType =
+  | 'boolean'
+  | 'text'
+  | 'number'
+  | 'password'
+  | 'select'
+  | 'multiselect'
+  | 'email'
+  | 'url'
+  | 'domain'
+  | 'choice'
+
 export interface WizardQuestion {
   id: string
   name: string
-  type: 'boolean' | 'text' | 'choice' | string
+  type: WizardQuestionType | string
   required: boolean
   default?: any
   choices?: WizardChoice[]
+  help?: string
+  placeholder?: string
+  pattern?: string
+  min?: number
+  max?: number
+  min_length?: number
+  max_length?: number
+  allowed_values?: string[]
+  show_if?: string
+  show_if_terms?: WizardConditionTerm[][]
+}
+
+// One operand of a parsed show_if expression; the question shows when every term of any group holds.
+export interface WizardConditionTerm {
+  var: string
+  op?: '==' | '!='
+  value?: string
+  negate?: boolean
 }
 
 export interface WizardChoice {
@@ -1,2 +1,3 @@
 export * from './crypto'
 export * from './api'
+export * from './wizard'
@@ -0,0 +1,58 @@
+import type { WizardQuestion } from '../types'
+
+// Evaluation of show_if and computed defaults; mirrors pkg/apps and pkg/dsl/condition.go.
+
+function truthy(v: any): boolean {
+  if (v === null || v === undefined) return false
+  if (typeof v === 'boolean') return v
+  if (typeof v === 'number') return v !== 0
+  if (typeof v === 'string') return !['', '0', 'false', 'no'].includes(v.trim().toLowerCase())
+  if (Array.isArray(v)) return v.length > 0
+  return true
+}
+
+function equals(v: any, want: string): boolean {
+  if (v === null || v === undefined) return want === ''
+  if (Array.isArray(v)) return v.some(x => equals(x, want))
+  return String(v) === want
+}
+
+// questionVisible reports whether q's show_if holds. Options (opts.*) that the web wizard
+// doesn't know yet read as unset.
+export function questionVisible(q: WizardQuestion, answers: Record<string, any>, opts: Record<string, any> = {}): boolean {
+  const groups = q.show_if_terms
+  if (!groups || groups.length === 0) return true
+  const lookup = (name: string) => name.startsWith('answers.') ? answers[name.slice('answers.'.length)] : opts[name]
+  return groups.some(group => group.every(term => {
+    const v = lookup(term.var)
+    if (term.op === '==') return equals(v, term.value ?? '')
+    if (term.op === '!=') return !equals(v, term.value ?? '')
+    return truthy(v) !== Boolean(term.negate)
+  }))
+}
+
+// hasComputedDefault reports whether q's default is a template like "{opts.Email}", computed by the server.
+export function hasComputedDefault(q: WizardQuestion): boolean {
+  return typeof q.default === 'string' && q.default.includes('{')
+}
+
+// defaultPreview renders a computed default with the answers given so far; {opts.*} is left as is.
+export function defaultPreview(q: WizardQuestion, answers: Record<string, any>): string {
+  if (typeof q.default !== 'string') return ''
+  return q.default.replace(/\{answers\.([A-Za-z0-9_.-]+)\}/g, (_, id: string) => {
+    const v = answers[id]
+    return Array.isArray(v) ? v.join(',') : v === undefined || v === null ? '' : String(v)
+  })
+}
+
+// visibleAnswers drops answers to hidden questions and blank answers to questions with a
+// computed default, so the server fills those in.
+export function visibleAnswers(questions: WizardQuestion[], answers: Record<string, any>): Record<string, any> {
+  const out: Record<string, any> = { ...answers }
+  for (const q of questions) {
+    if (!questionVisible(q, out) || (hasComputedDefault(q) && (out[q.id] === '' || out[q.id] === undefined))) {
+      delete out[q.id]
+    }
+  }
+  return out
+}
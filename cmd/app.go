@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zdunecki/selfhosted/pkg/apps"
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+)
+
+// appCmd groups tooling for marketplace app authors.
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Tools for authoring marketplace apps",
+}
+
+var appLintCmd = &cobra.Command{
+	Use:   "lint <file> [file...]",
+	Short: "Validate marketplace app YAML files",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed := 0
+		for _, path := range args {
+			_, err := apps.LintFile(path)
+			if err == nil {
+				fmt.Printf("✅ %s\n", path)
+				if outdatedAPIVersion(path) {
+					fmt.Printf("⚠️  %s: not at apiVersion %s; run `selfhost app migrate %s`\n", path, dsl.LatestAPIVersion, path)
+				}
+				continue
+			}
+			failed++
+			var lintErr *dsl.LintError
+			if errors.As(err, &lintErr) {
+				for _, issue := range lintErr.Issues {
+					fmt.Printf("❌ %s\n", issue)
+				}
+			} else {
+				fmt.Printf("❌ %s: %v\n", path, err)
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d of %d file(s) failed validation", failed, len(args))
+		}
+		return nil
+	},
+}
+
+var appSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for marketplace app YAML",
+	Long: `Prints the JSON Schema for marketplace app YAML files.
+
+Point yaml-language-server at it for completion and validation, e.g.:
+  selfhost app schema > app.schema.json
+  # yaml-language-server: $schema=./app.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := dsl.JSONSchemaBytes(apps.LintOptions())
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var appMigrateDryRun bool
+
+var appMigrateCmd = &cobra.Command{
+	Use:   "migrate <file> [file...]",
+	Short: "Upgrade marketplace app YAML files to the latest apiVersion",
+	Long: `Rewrites app YAML files to the latest DSL apiVersion.
+
+Older specs still load (they are migrated in memory), but migrating keeps
+the file in sync with the schema and the linter. Use --dry-run to print the
+result instead of writing it.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			out, applied, err := dsl.MigrateYAML(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if appMigrateDryRun {
+				fmt.Print(string(out))
+				continue
+			}
+			if len(applied) == 0 && string(out) == string(data) {
+				fmt.Printf("✅ %s is already %s\n", path, dsl.LatestAPIVersion)
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+				return err
+			}
+			fmt.Printf("✅ %s migrated to %s\n", path, dsl.LatestAPIVersion)
+			for _, m := range applied {
+				fmt.Printf("   %s -> %s: %s\n", m.From, m.To, m.Description)
+			}
+		}
+		return nil
+	},
+}
+
+var appSignKey string
+
+var appSignCmd = &cobra.Command{
+	Use:   "sign --key <file.key> <file|catalog dir> [...]",
+	Short: "Sign app files and catalog indexes",
+	Long: `Writes a detached ed25519 signature (<file>.sig) for each file.
+
+For a catalog directory, its apps.yaml and every app it lists are signed.
+Publish the .sig files next to the signed files, and give users your .pub
+key to add with ` + "`selfhost app trust`" + `.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if appSignKey == "" {
+			return fmt.Errorf("--key is required (create one with `selfhost app keygen`)")
+		}
+		data, err := os.ReadFile(appSignKey)
+		if err != nil {
+			return err
+		}
+		key, err := apps.ParsePrivateKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", appSignKey, err)
+		}
+		for _, arg := range args {
+			files := []string{arg}
+			if info, err := os.Stat(arg); err == nil && info.IsDir() {
+				if files, err = apps.CatalogFiles(arg); err != nil {
+					return err
+				}
+			}
+			for _, path := range files {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(path+apps.SigExt, apps.Sign(key, content), 0o644); err != nil {
+					return err
+				}
+				fmt.Printf("✅ %s%s\n", path, apps.SigExt)
+			}
+		}
+		return nil
+	},
+}
+
+var appKeygenCmd = &cobra.Command{
+	Use:   "keygen <name>",
+	Short: "Create a key pair for signing apps",
+	Long:  `Writes <name>.key (keep it secret) and <name>.pub (give it to users).`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		private, public, id, err := apps.GenerateSigningKey()
+		if err != nil {
+			return err
+		}
+		for _, path := range []string{args[0] + ".key", args[0] + ".pub"} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists", path)
+			}
+		}
+		if err := os.WriteFile(args[0]+".key", private, 0o600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(args[0]+".pub", public, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Wrote %s.key and %s.pub (key id %s)\n", args[0], args[0], id)
+		return nil
+	},
+}
+
+var appTrustName string
+
+var appTrustCmd = &cobra.Command{
+	Use:   "trust <file.pub>",
+	Short: "Trust a public key for app signatures",
+	Long:  `Adds a public key to ~/.selfhosted/trust/, so apps signed with it verify.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		pub, err := apps.ParsePublicKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		name := appTrustName
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(args[0]), ".pub")
+		}
+		if err := apps.ValidateCatalogName(name); err != nil {
+			return fmt.Errorf("invalid key name %q: use lowercase letters, digits, '-' and '_'", name)
+		}
+		dir, err := apps.TrustDir()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".pub"), data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Trusted %s (key id %s)\n", name, apps.KeyID(pub))
+		return nil
+	},
+}
+
+// outdatedAPIVersion reports whether `app migrate` would rewrite the file.
+func outdatedAPIVersion(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	out, _, err := dsl.MigrateYAML(data)
+	return err == nil && string(out) != string(data)
+}
+
+func init() {
+	appMigrateCmd.Flags().BoolVar(&appMigrateDryRun, "dry-run", false, "Print the migrated YAML instead of writing the file")
+	appSignCmd.Flags().StringVar(&appSignKey, "key", "", "Private key file (from `selfhost app keygen`)")
+	appTrustCmd.Flags().StringVar(&appTrustName, "name", "", "Name for the key (default: the file name)")
+
+	appCmd.AddCommand(appLintCmd)
+	appCmd.AddCommand(appSchemaCmd)
+	appCmd.AddCommand(appMigrateCmd)
+	appCmd.AddCommand(appSignCmd)
+	appCmd.AddCommand(appKeygenCmd)
+	appCmd.AddCommand(appTrustCmd)
+	rootCmd.AddCommand(appCmd)
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zdunecki/selfhosted/pkg/apps"
+)
+
+// catalogCmd manages remote app catalogs (see pkg/apps/catalog.go).
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage remote app catalogs",
+	Long: `Remote catalogs add apps from outside the bundled marketplace. They are
+configured in ~/.selfhosted/config and cached in ~/.selfhosted/catalogs/,
+so their apps stay available offline until the next update.
+
+A catalog URL either serves apps.yaml and apps/<file> over HTTPS, or is a
+.tar.gz of a catalog directory (e.g. a git host's archive of a tag). Use
+{revision} in the URL to pin the revision with --revision.`,
+}
+
+var (
+	catalogType     string
+	catalogRevision string
+	catalogChecksum string
+	catalogUnpin    bool
+)
+
+var catalogAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a catalog and fetch it",
+	Example: `  selfhost catalog add community https://catalog.example.com/selfhosted
+  selfhost catalog add acme 'https://github.com/acme/apps/archive/{revision}.tar.gz' --revision v1.2.0`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := apps.LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		if cfg.Catalog(args[0]) != nil {
+			return fmt.Errorf("catalog %s already exists", args[0])
+		}
+		c := apps.CatalogConfig{
+			Name:     args[0],
+			URL:      args[1],
+			Type:     catalogType,
+			Revision: catalogRevision,
+			Checksum: catalogChecksum,
+		}
+		cache, _, err := apps.FetchCatalog(context.Background(), c)
+		if err != nil {
+			return err
+		}
+		cfg.Catalogs = append(cfg.Catalogs, c)
+		if err := apps.SaveUserConfig(cfg); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Added catalog %s: %d app(s), %s\n", c.Name, len(cache.Apps), cache.Checksum)
+		return nil
+	},
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured catalogs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := apps.LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		if len(cfg.Catalogs) == 0 {
+			fmt.Println("No catalogs configured; add one with `selfhost catalog add <name> <url>`.")
+			return nil
+		}
+		for _, c := range cfg.Catalogs {
+			fmt.Printf("%s  %s\n", c.Name, c.URL)
+			if c.Revision != "" {
+				fmt.Printf("   revision: %s\n", c.Revision)
+			}
+			if c.Checksum != "" {
+				fmt.Printf("   pinned:   %s\n", c.Checksum)
+			}
+			cache, err := apps.ReadCatalogCache(c.Name)
+			switch {
+			case err != nil:
+				fmt.Printf("   ⚠️  %v\n", err)
+			case cache == nil:
+				fmt.Println("   not fetched (run `selfhost catalog update`)")
+			default:
+				fmt.Printf("   fetched:  %s, %s\n", cache.FetchedAt.Local().Format("2006-01-02 15:04:05"), cache.Checksum)
+				fmt.Printf("   apps:     %v\n", cache.Apps)
+			}
+		}
+		return nil
+	},
+}
+
+var catalogUpdateCmd = &cobra.Command{
+	Use:   "update [name...]",
+	Short: "Fetch catalogs again (all of them by default)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := apps.LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		names := args
+		if len(names) == 0 {
+			for _, c := range cfg.Catalogs {
+				names = append(names, c.Name)
+			}
+		}
+		failed := 0
+		for _, name := range names {
+			c := cfg.Catalog(name)
+			if c == nil {
+				failed++
+				fmt.Printf("❌ %s: no such catalog\n", name)
+				continue
+			}
+			cache, changed, err := apps.FetchCatalog(context.Background(), *c)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("❌ %v\n", err)
+			case changed:
+				fmt.Printf("✅ %s updated: %d app(s), %s\n", name, len(cache.Apps), cache.Checksum)
+			default:
+				fmt.Printf("✅ %s is up to date\n", name)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d catalog(s) failed to update; their cached copies are kept", failed, len(names))
+		}
+		return nil
+	},
+}
+
+var catalogRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a catalog and its cached apps",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := apps.LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		kept := cfg.Catalogs[:0]
+		for _, c := range cfg.Catalogs {
+			if c.Name != args[0] {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == len(cfg.Catalogs) {
+			return fmt.Errorf("no such catalog: %s", args[0])
+		}
+		cfg.Catalogs = kept
+		if err := apps.SaveUserConfig(cfg); err != nil {
+			return err
+		}
+		if err := apps.RemoveCatalogCache(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed catalog %s\n", args[0])
+		return nil
+	},
+}
+
+var catalogPinCmd = &cobra.Command{
+	Use:   "pin <name>",
+	Short: "Pin a catalog to a revision and checksum",
+	Long: `Pins a catalog so updates only accept the pinned content.
+
+Without flags the checksum of the cached copy is pinned. --revision switches
+to another revision (for URLs with {revision}) and pins what it fetches,
+unless --checksum gives the expected checksum. --unpin removes the checksum
+pin (a URL with {revision} still needs its revision).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := apps.LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		c := cfg.Catalog(args[0])
+		if c == nil {
+			return fmt.Errorf("no such catalog: %s", args[0])
+		}
+
+		switch {
+		case catalogUnpin:
+			c.Checksum = ""
+			if err := apps.SaveUserConfig(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Unpinned catalog %s\n", c.Name)
+			return nil
+		case cmd.Flags().Changed("revision") || cmd.Flags().Changed("checksum"):
+			next := *c
+			if cmd.Flags().Changed("revision") {
+				next.Revision = catalogRevision
+			}
+			next.Checksum = catalogChecksum
+			cache, _, err := apps.FetchCatalog(context.Background(), next)
+			if err != nil {
+				return err
+			}
+			next.Checksum = cache.Checksum
+			*c = next
+		default:
+			cache, err := apps.ReadCatalogCache(c.Name)
+			if err != nil {
+				return err
+			}
+			if cache == nil {
+				return fmt.Errorf("catalog %s has not been fetched; run `selfhost catalog update %s`", c.Name, c.Name)
+			}
+			c.Checksum = cache.Checksum
+		}
+		if err := apps.SaveUserConfig(cfg); err != nil {
+			return err
+		}
+		if c.Revision != "" {
+			fmt.Printf("✅ Pinned catalog %s to %s (%s)\n", c.Name, c.Revision, c.Checksum)
+		} else {
+			fmt.Printf("✅ Pinned catalog %s to %s\n", c.Name, c.Checksum)
+		}
+		return nil
+	},
+}
+
+func init() {
+	catalogAddCmd.Flags().StringVar(&catalogType, "type", "", "Catalog type: http or tarball (default: detected from the URL)")
+	catalogAddCmd.Flags().StringVar(&catalogRevision, "revision", "", "Revision substituted for {revision} in the URL")
+	catalogAddCmd.Flags().StringVar(&catalogChecksum, "checksum", "", "Expected content checksum (sha256:...)")
+	catalogPinCmd.Flags().StringVar(&catalogRevision, "revision", "", "Revision to switch to and pin")
+	catalogPinCmd.Flags().StringVar(&catalogChecksum, "checksum", "", "Expected content checksum (sha256:...)")
+	catalogPinCmd.Flags().BoolVar(&catalogUnpin, "unpin", false, "Remove the checksum pin")
+
+	catalogCmd.AddCommand(catalogAddCmd)
+	catalogCmd.AddCommand(catalogListCmd)
+	catalogCmd.AddCommand(catalogUpdateCmd)
+	catalogCmd.AddCommand(catalogRemoveCmd)
+	catalogCmd.AddCommand(catalogPinCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zdunecki/selfhosted/pkg/apps"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
+)
+
+var deploymentsOutdated bool
+
+var deploymentsCmd = &cobra.Command{
+	Use:   "deployments",
+	Short: "List deployments and the app versions they run",
+	Long: `Lists the local deployment records, newest first, with the app version each
+one installed and the latest version the app's spec offers.
+
+Deployments made before an app listed versions show no version and are
+never reported as outdated. New deployments pick a version with
+--app <app>@<version>.`,
+	Example: `  selfhost deployments --outdated`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		records, err := deployments.List()
+		if err != nil {
+			return err
+		}
+		// Apps that fail to load are reported by `selfhost apps`; their deployments show no latest version.
+		_ = apps.LoadRegistry()
+
+		shown := 0
+		for _, d := range records {
+			installed, latest, outdated := d.Version, "", false
+			if app, err := apps.Get(d.App); err == nil {
+				latest, outdated = apps.UpdateAvailable(app, installed)
+			}
+			if deploymentsOutdated && !outdated {
+				continue
+			}
+			shown++
+
+			version := installed
+			if version == "" {
+				version = "-"
+			}
+			status := ""
+			if outdated {
+				status = fmt.Sprintf(" ⬆️  %s available", latest)
+			}
+			fmt.Printf("  - %s: %s %s on %s%s\n", d.ID, d.App, version, d.Provider, status)
+			var where []string
+			if d.Domain != "" {
+				where = append(where, "domain: "+d.Domain)
+			}
+			if d.ServerIP != "" {
+				where = append(where, "server: "+d.ServerIP)
+			}
+			if len(where) > 0 {
+				fmt.Printf("      %s\n", strings.Join(where, ", "))
+			}
+		}
+		switch {
+		case shown > 0:
+		case deploymentsOutdated:
+			fmt.Println("All deployments run the latest app version.")
+		default:
+			fmt.Println("No deployments yet.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	deploymentsCmd.Flags().BoolVar(&deploymentsOutdated, "outdated", false, "Only list deployments with a newer app version available")
+	rootCmd.AddCommand(deploymentsCmd)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+var (
+	replayList      bool
+	replaySpeed     float64
+	replayIdleLimit time.Duration
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <deployment> [session]",
+	Short: "Replay a recorded terminal session of a deployment",
+	Long: `Plays back an interactive install step recorded during a deployment.
+
+The deployment may be given by id, domain, server name or IP. Without a
+session the most recent recording is played; use --list to see them all.
+Recordings are asciicast v2 files, so they also play in asciinema:
+  ~/.selfhosted/deployments/<id>/pty/<session>.cast`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		d, err := deployments.Find(args[0])
+		if err != nil {
+			return err
+		}
+		recordings, err := deployments.Recordings(d.ID)
+		if err != nil {
+			return err
+		}
+		if len(recordings) == 0 {
+			return fmt.Errorf("no terminal sessions recorded for %s", d.ID)
+		}
+
+		if replayList {
+			for _, rec := range recordings {
+				fmt.Printf("%s  %s  %s\n", rec.Session, rec.StartedAt.Local().Format("2006-01-02 15:04:05"), rec.Title)
+			}
+			return nil
+		}
+
+		session := recordings[len(recordings)-1].Session
+		if len(args) > 1 {
+			session = args[1]
+		}
+		path, err := deployments.RecordingPath(d.ID, session)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("session %s not found (see `selfhost replay %s --list`)", session, args[0])
+		}
+		defer f.Close()
+		_, events, err := utils.ReadCast(f)
+		if err != nil {
+			return err
+		}
+		if replaySpeed <= 0 {
+			return fmt.Errorf("--speed must be positive")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		playCast(ctx, os.Stdout, events, replaySpeed, replayIdleLimit)
+		return nil
+	},
+}
+
+// playCast writes the output events to w in real time, divided by speed. Pauses longer than
+// idleLimit are shortened to it (0 keeps them).
+func playCast(ctx context.Context, w io.Writer, events []utils.CastEvent, speed float64, idleLimit time.Duration) {
+	var last float64
+	for _, e := range events {
+		if e.Type != utils.CastOutput {
+			continue
+		}
+		delay := time.Duration((e.Time - last) * float64(time.Second))
+		last = e.Time
+		if idleLimit > 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		select {
+		case <-ctx.Done():
+			// Leave the terminal usable when playback stops inside a full-screen program.
+			fmt.Fprint(w, "\x1b[0m\x1b[?25h\x1b[?1049l\n")
+			return
+		case <-time.After(time.Duration(float64(delay) / speed)):
+		}
+		io.WriteString(w, e.Data)
+	}
+	fmt.Fprintln(w)
+}
+
+func init() {
+	replayCmd.Flags().BoolVar(&replayList, "list", false, "List the recorded sessions instead of playing one")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Playback speed multiplier")
+	replayCmd.Flags().DurationVar(&replayIdleLimit, "idle-limit", 2*time.Second, "Shorten pauses longer than this (0 keeps them)")
+	rootCmd.AddCommand(replayCmd)
+}
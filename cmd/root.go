@@ -9,6 +9,7 @@ import (
 
 	"github.com/zdunecki/selfhosted/pkg/apps"
 	"github.com/zdunecki/selfhosted/pkg/cli"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
 	"github.com/zdunecki/selfhosted/pkg/providers"
 )
 
@@ -30,6 +31,8 @@ var (
 	configFile             string
 	dnsSetupMode           string
 	desktopMode            bool
+	ignoreCompatibility    bool
+	wizardAnswers          []string
 )
 
 var rootCmd = &cobra.Command{
@@ -66,19 +69,114 @@ var listProvidersCmd = &cobra.Command{
 	},
 }
 
+var (
+	appsCategory string
+	appsSearch   string
+	appsSort     string
+)
+
 var listAppsCmd = &cobra.Command{
 	Use:   "apps",
 	Short: "List available applications",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Available applications:")
-		for name, a := range apps.Registry {
+	Example: `  selfhost apps --category analytics
+  selfhost apps --search "session replay" --sort display_name`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Broken apps are listed below; the rest of the registry still loads.
+		_ = apps.LoadRegistry()
+		found, err := apps.FindApps(apps.AppQuery{Text: appsSearch, Category: appsCategory, Sort: appsSort})
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			fmt.Printf("No applications match (categories: %s).\n", strings.Join(apps.Categories(), ", "))
+		} else {
+			fmt.Println("Available applications:")
+		}
+		for _, a := range found {
+			name := a.Name()
+			meta := apps.MetadataFor(a)
+			title := name
+			if meta.DisplayName != "" && meta.DisplayName != name {
+				title = fmt.Sprintf("%s (%s)", meta.DisplayName, name)
+			}
 			specs := a.MinSpecs()
-			fmt.Printf("  - %s: %s (min: %d vCPUs, %dMB RAM)\n",
-				name, a.Description(), specs.CPUs, specs.MemoryMB)
+			fmt.Printf("  - %s: %s (min: %d vCPUs, %dMB RAM, %dGB disk)\n",
+				title, a.Description(), specs.CPUs, specs.MemoryMB, specs.DiskGB)
+			printAppMetadata(meta)
+			if supported := apps.SupportedProviders(a); supported != nil {
+				fmt.Printf("      providers: %s\n", strings.Join(supported, ", "))
+			}
+			if names := apps.VersionNames(a); len(names) > 0 {
+				def := apps.DefaultVersion(a)
+				for i, v := range names {
+					if v == def {
+						names[i] += " (default)"
+					}
+				}
+				fmt.Printf("      versions: %s (deploy one with --app %s@<version>)\n", strings.Join(names, ", "), name)
+			}
+			if v, ok := apps.AppVerification(name); ok {
+				signature := v.Status
+				if v.Key != "" {
+					signature += " by " + v.Key
+				}
+				fmt.Printf("      source: %s, signature: %s\n", v.Source, signature)
+			}
+		}
+		status := apps.GetMarketplaceStatus()
+		for _, b := range status.Broken {
+			name := b.Name
+			if name == "" {
+				name = b.File
+			}
+			loaded := "not loaded"
+			if b.KeptPrevious {
+				loaded = "serving the last good version"
+			}
+			fmt.Printf("  - %s: ❌ invalid, %s\n", name, loaded)
+			fmt.Printf("      file: %s\n", b.File)
+			fmt.Printf("      error: %s\n", strings.ReplaceAll(b.Error, "\n", "\n        "))
 		}
+		if status.Error != "" {
+			fmt.Printf("❌ %s\n", status.Error)
+		}
+		for _, w := range status.Warnings {
+			fmt.Printf("⚠️  %s\n", w)
+		}
+		return nil
 	},
 }
 
+// printAppMetadata prints the metadata lines of an app in `selfhost apps`.
+func printAppMetadata(m apps.Metadata) {
+	var about []string
+	if m.Category != "" {
+		about = append(about, "category: "+m.Category)
+	}
+	if m.Version != "" {
+		about = append(about, "version: "+m.Version)
+	}
+	if m.License != "" {
+		about = append(about, "license: "+m.License)
+	}
+	if len(m.Tags) > 0 {
+		about = append(about, "tags: "+strings.Join(m.Tags, ", "))
+	}
+	if len(about) > 0 {
+		fmt.Printf("      %s\n", strings.Join(about, ", "))
+	}
+	if m.Homepage != "" {
+		fmt.Printf("      homepage: %s\n", m.Homepage)
+	}
+	if m.Repository != "" {
+		fmt.Printf("      repository: %s\n", m.Repository)
+	}
+	if m.Icon != "" {
+		fmt.Printf("      icon: %s\n", m.Icon)
+	}
+}
+
 var listRegionsCmd = &cobra.Command{
 	Use:   "regions [provider]",
 	Short: "List available regions for a provider",
@@ -154,7 +252,7 @@ func init() {
 
 	// Deploy command flags
 	deployCmd.Flags().StringVarP(&providerName, "provider", "p", "", "Cloud provider (digitalocean, scaleway, ovh)")
-	deployCmd.Flags().StringVarP(&appName, "app", "a", "", "Application to deploy (openreplay, openpanel, plausible)")
+	deployCmd.Flags().StringVarP(&appName, "app", "a", "", "Application to deploy, optionally pinned to a version (e.g. plausible, umami@2.13)")
 	deployCmd.Flags().StringVarP(&region, "region", "r", "", "Region/datacenter")
 	deployCmd.Flags().StringVarP(&size, "size", "s", "", "VM size (optional, will use app minimum)")
 	deployCmd.Flags().StringVarP(&domain, "domain", "d", "", "Domain name for the app")
@@ -168,6 +266,8 @@ func init() {
 	deployCmd.Flags().BoolVar(&httpToHttpsRedirection, "http-to-https", false, "Enable HTTP to HTTPS redirection in the app")
 	deployCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path")
 	deployCmd.Flags().StringVar(&dnsSetupMode, "dns-setup", "auto", "DNS setup mode for openreplay (auto, skip, force)")
+	deployCmd.Flags().StringArrayVar(&wizardAnswers, "answer", nil, "Answer to an app wizard question as id=value (repeatable; comma-separate multiselect values)")
+	deployCmd.Flags().BoolVar(&ignoreCompatibility, "ignore-compatibility", false, "Deploy even if the app doesn't list the provider or the size is below the app's minimum specs")
 
 	deployCmd.MarkFlagRequired("provider")
 	deployCmd.MarkFlagRequired("app")
@@ -183,6 +283,7 @@ func init() {
 	setupSSLCmd.Flags().StringVar(&email, "email", "", "Email for Let's Encrypt")
 	setupSSLCmd.Flags().StringVar(&sshKeyPath, "ssh-key", "", "Path to SSH private key")
 	setupSSLCmd.Flags().String("server-ip", "", "Server IP address")
+	setupSSLCmd.Flags().StringArrayVar(&wizardAnswers, "answer", nil, "Answer to an app wizard question as id=value, as given to deploy (repeatable)")
 	setupSSLCmd.MarkFlagRequired("app")
 	setupSSLCmd.MarkFlagRequired("domain")
 	setupSSLCmd.MarkFlagRequired("email")
@@ -191,6 +292,9 @@ func init() {
 	// Add commands
 	rootCmd.AddCommand(deployCmd)
 	rootCmd.AddCommand(listProvidersCmd)
+	listAppsCmd.Flags().StringVar(&appsCategory, "category", "", "Only list apps in this category (e.g. analytics)")
+	listAppsCmd.Flags().StringVarP(&appsSearch, "search", "q", "", "Only list apps whose name, description, category or tags match")
+	listAppsCmd.Flags().StringVar(&appsSort, "sort", apps.SortByName, "Sort by name, display_name or category")
 	rootCmd.AddCommand(listAppsCmd)
 	rootCmd.AddCommand(listRegionsCmd)
 	rootCmd.AddCommand(listSizesCmd)
@@ -218,10 +322,34 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		SSLCertificateCrt:      sslCertificateCrt,
 		HttpToHttpsRedirection: httpToHttpsRedirection,
 		DNSSetupMode:           dnsSetupMode,
+		IgnoreCompatibility:    ignoreCompatibility,
+	}
+	answers, err := parseWizardAnswers(wizardAnswers)
+	if err != nil {
+		return err
 	}
+	opts.WizardAnswers = answers
 	return deployWithOptions(opts)
 }
 
+// parseWizardAnswers turns --answer id=value flags into wizard answers.
+// Values stay strings; cli.Deploy converts them to each question's type.
+func parseWizardAnswers(flags []string) (map[string]interface{}, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	answers := make(map[string]interface{}, len(flags))
+	for _, f := range flags {
+		id, value, ok := strings.Cut(f, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid --answer %q (expected id=value)", f)
+		}
+		answers[id] = value
+	}
+	return answers, nil
+}
+
 // deployWithOptions executes a deployment with the given options
 func deployWithOptions(opts cli.DeployOptions) error {
 	return cli.Deploy(opts, func(format string, a ...interface{}) {
@@ -245,24 +373,49 @@ func runSetupSSL(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("SSH key error: %w", err)
 	}
 
+	// Reuse the secrets generated at deploy time; never generate new ones for an existing server.
+	record, err := cli.LoadOrCreateDeployment(appName, domain)
+	if err != nil {
+		return fmt.Errorf("deployment record error: %w", err)
+	}
+	if _, err := cli.EnsureSecrets(app, record); err != nil {
+		return fmt.Errorf("secret generation failed: %w", err)
+	}
+
 	fmt.Printf("🔐 Setting up SSL for %s\n", domain)
 	fmt.Printf("   Server: %s\n", serverIP)
 	fmt.Printf("   Email: %s\n", email)
 	fmt.Println()
 
 	// Create install config for SSL setup
+	recordDir, err := deployments.PTYDir(record.ID)
+	if err != nil {
+		fmt.Printf("⚠️  Terminal sessions won't be recorded: %v\n", err)
+	}
 	installConfig := &apps.InstallConfig{
 		Domain:                 domain,
 		ServerIP:               serverIP,
 		SSHKey:                 sshPrivate,
-		SSHUser:                "root",
+		SSHUser:                cli.SSHUser(record),
 		EnableSSL:              true,
 		Email:                  email,
 		SSL:                    true,
 		SSLPrivateKeyFile:      sslPrivateKeyFile,
 		SSLCertificateCrt:      sslCertificateCrt,
 		HttpToHttpsRedirection: httpToHttpsRedirection,
+		Version:                record.Version,
+		Secrets:                record.Secrets,
+		RecordDir:              recordDir,
+	}
+	// Steps gated on wizard answers see the same answers as at deploy time; unanswered questions get their defaults.
+	answers, err := parseWizardAnswers(wizardAnswers)
+	if err != nil {
+		return err
+	}
+	if installConfig.WizardAnswers, err = apps.ValidateWizardAnswers(app, answers, apps.NewWizardEnv(installConfig)); err != nil {
+		return err
 	}
+	installConfig.SensitiveValues = apps.SensitiveAnswers(app, installConfig.WizardAnswers)
 
 	// Setup SSL
 	fmt.Println("⏳ Configuring SSL certificate...")
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	"github.com/zdunecki/selfhosted/pkg/cli"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh <deployment> [command...]",
+	Short: "Open a shell on a deployed server",
+	Long: `Opens an interactive SSH session on a deployment's server, using the SSH
+user and key stored with the deployment (override the key with --ssh-key).
+
+The deployment may be given by id, domain, server name or IP. Extra
+arguments run as a command instead of the login shell.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		d, err := deployments.Find(args[0])
+		if err != nil {
+			return err
+		}
+		runner, err := cli.ConnectDeployment(d, sshKeyPath)
+		if err != nil {
+			return err
+		}
+		defer runner.Close()
+
+		opts := utils.PTYOptions{Term: os.Getenv("TERM")}
+		fd := int(os.Stdin.Fd())
+		restore := func() {}
+		if term.IsTerminal(fd) {
+			if cols, rows, err := term.GetSize(fd); err == nil {
+				opts.Rows, opts.Cols = rows, cols
+			}
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return fmt.Errorf("terminal: %w", err)
+			}
+			restore = func() { _ = term.Restore(fd, state) }
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stdin, wait, err := runner.RunPTYContext(ctx, strings.Join(args[1:], " "), opts, func(b []byte) {
+			os.Stdout.Write(b)
+		})
+		if err != nil {
+			restore()
+			return err
+		}
+		go io.Copy(stdin, os.Stdin)
+		if opts.Rows > 0 {
+			go followTerminalSize(ctx, fd, opts.Rows, opts.Cols, runner)
+		}
+
+		err = wait()
+		restore()
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			// The remote command's status is ours, like ssh(1).
+			os.Exit(exitErr.ExitStatus())
+		}
+		return err
+	},
+}
+
+// followTerminalSize resizes the remote PTY when the local terminal changes size. It polls, so
+// it works the same on every platform.
+func followTerminalSize(ctx context.Context, fd, rows, cols int, runner *utils.SSHRunner) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c, r, err := term.GetSize(fd)
+		if err != nil || (r == rows && c == cols) {
+			continue
+		}
+		if runner.WindowChange(r, c) == nil {
+			rows, cols = r, c
+		}
+	}
+}
+
+func init() {
+	sshCmd.Flags().StringVar(&sshKeyPath, "ssh-key", "", "Path to SSH private key (defaults to the deployment's key)")
+	rootCmd.AddCommand(sshCmd)
+}
@@ -14,6 +14,7 @@ require (
 	github.com/charmbracelet/bubbletea v0.26.6
 	github.com/charmbracelet/lipgloss v0.10.0
 	github.com/digitalocean/godo v1.130.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/hashicorp/terraform-exec v0.19.0
 	github.com/joho/godotenv v1.5.1
 	github.com/scaleway/scaleway-sdk-go v1.0.0-beta.36
@@ -21,6 +22,7 @@ require (
 	github.com/vultr/govultr/v3 v3.26.1
 	golang.org/x/crypto v0.46.0
 	golang.org/x/oauth2 v0.34.0
+	golang.org/x/term v0.38.0
 	google.golang.org/api v0.259.0
 	google.golang.org/genproto v0.0.0-20251213004720-97cd9d5aeac2
 	google.golang.org/genproto/googleapis/api/serviceusage v0.0.0-20251222181119-0a764e51fe1b
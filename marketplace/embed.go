@@ -0,0 +1,71 @@
+// Package marketplace holds the built-in app definitions, icons, step libraries and Terraform modules.
+// They are embedded in the binary, so an installed selfhost works without this directory.
+//
+// Local directories layer on top of the built-in copy, each taking precedence per app name (and
+// per library or Terraform module) over the ones after it:
+//
+//  1. the directories in $SELFHOSTED_MARKETPLACE_PATH, in order
+//  2. marketplace/ in the working directory or next to the executable (a source checkout)
+//  3. the built-in copy
+package marketplace
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed apps.yaml apps/*.yaml icons lib terraform
+var files embed.FS
+
+// FS is the built-in marketplace, rooted at this directory.
+var FS fs.FS = files
+
+// PathEnv lists override marketplace directories, separated like PATH.
+const PathEnv = "SELFHOSTED_MARKETPLACE_PATH"
+
+// OverridePaths returns the directories in $SELFHOSTED_MARKETPLACE_PATH, in order of precedence.
+func OverridePaths() []string {
+	var paths []string
+	for _, p := range filepath.SplitList(os.Getenv(PathEnv)) {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// LocalDir returns marketplace/ in the working directory (for development) or next to the
+// executable, if there is one.
+func LocalDir() (string, bool) {
+	var candidates []string
+	if cwd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(cwd, "marketplace"))
+	}
+	if exe, err := os.Executable(); err == nil {
+		exeDir := filepath.Dir(exe)
+		candidates = append(candidates,
+			filepath.Join(exeDir, "marketplace"),
+			// One level up, common for Go builds.
+			filepath.Join(exeDir, "..", "marketplace"),
+		)
+	}
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "apps.yaml")); err == nil {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// Dirs returns the local directories layered over the built-in marketplace, in order of
+// precedence.
+func Dirs() []string {
+	dirs := OverridePaths()
+	if dir, ok := LocalDir(); ok {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
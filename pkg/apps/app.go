@@ -3,6 +3,7 @@ package apps
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/zdunecki/selfhosted/pkg/providers"
 )
@@ -48,27 +49,75 @@ type InstallConfig struct {
 	SSLPrivateKeyFile      string
 	SSLCertificateCrt      string
 	HttpToHttpsRedirection bool
+	Version                string // App version picked from the spec's versions; its vars are exposed as {version.NAME}
 	ExtraVars              map[string]string
+	WizardAnswers          map[string]interface{}       // Validated wizard answers, exposed to DSL steps as {wizard.ID} and wizard.ID
+	Secrets                map[string]string            // Generated app secrets, exposed to templates as {secrets.NAME}
+	SensitiveValues        []string                     // Other values redacted from logs (e.g. password answers)
+	RecordDir              string                       // Optional directory where TTY steps are recorded as <session>.cast (asciicast v2)
 	Logger                 func(string, ...interface{}) // Optional logger for streaming logs
 }
 
-// Registry holds all registered apps
-var Registry = make(map[string]App)
+var (
+	registryMu sync.RWMutex
+	// registry holds all registered apps. The marketplace replaces it as a whole on reload.
+	registry = make(map[string]App)
+	// goApps are apps registered from Go code; they survive marketplace reloads and take
+	// precedence over YAML apps with the same name.
+	goApps = make(map[string]App)
+)
 
-// Register adds an app to the registry
+// Register adds an app implemented in Go to the registry
 func Register(a App) {
-	Registry[a.Name()] = a
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	goApps[a.Name()] = a
+	registry[a.Name()] = a
 }
 
 // Get retrieves an app by name
 func Get(name string) (App, error) {
-	a, ok := Registry[name]
+	LoadRegistry()
+	registryMu.RLock()
+	a, ok := registry[name]
+	registryMu.RUnlock()
 	if !ok {
+		for _, b := range BrokenApps() {
+			if b.Name == name {
+				return nil, fmt.Errorf("app %s failed to load (%s): %s", name, b.File, b.Error)
+			}
+		}
 		return nil, fmt.Errorf("unknown app: %s", name)
 	}
 	return a, nil
 }
 
+// All returns a snapshot of the registered apps, keyed by name.
+func All() map[string]App {
+	LoadRegistry()
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make(map[string]App, len(registry))
+	for name, a := range registry {
+		out[name] = a
+	}
+	return out
+}
+
+// replaceMarketplaceApps atomically swaps the YAML apps for marketplace; Go apps are kept.
+func replaceMarketplaceApps(marketplace map[string]App) {
+	next := make(map[string]App, len(marketplace)+len(goApps))
+	for name, a := range marketplace {
+		next[name] = a
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name, a := range goApps {
+		next[name] = a
+	}
+	registry = next
+}
+
 // ShouldSetupDNS is a helper function that handles the generic DNS setup mode logic
 // and delegates to the app-specific logic when needed
 func ShouldSetupDNS(app App, dnsSetupMode, providerName, detectedDNSProvider string) bool {
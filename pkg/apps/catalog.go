@@ -0,0 +1,702 @@
+package apps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"gopkg.in/yaml.v3"
+)
+
+// Remote catalogs add apps from outside the local marketplace. They are configured in
+// ~/.selfhosted/config:
+//
+//	catalogs:
+//	  - name: community
+//	    url: https://catalog.example.com/selfhosted # serves apps.yaml and apps/<file>
+//	  - name: acme
+//	    url: https://github.com/acme/apps/archive/{revision}.tar.gz
+//	    revision: v1.2.0
+//	    checksum: sha256:3f1c...
+//
+// A catalog has the marketplace layout: apps.yaml lists files in apps/ (a tarball may also carry
+// lib/). `selfhost catalog update` fetches it into ~/.selfhosted/catalogs/<name>/, revalidating
+// with ETags; the registry only reads that cache, so catalogs work offline. A fetch that fails
+// validation or doesn't match the pinned checksum leaves the cache as it was.
+//
+// Apps from the local marketplace win over catalog apps of the same name, and earlier catalogs
+// win over later ones.
+
+const (
+	// CatalogHTTP is a catalog served as files: <url>/apps.yaml and <url>/apps/<file>.
+	CatalogHTTP = "http"
+	// CatalogTarball is a .tar.gz of a catalog directory, e.g. a git host's archive of a revision.
+	CatalogTarball = "tarball"
+
+	catalogCacheFile   = ".catalog.json"
+	catalogMaxDownload = 64 << 20
+	catalogMaxExtract  = 256 << 20
+	catalogTimeout     = 2 * time.Minute
+)
+
+var errCatalogNotFound = errors.New("not found")
+
+// UserConfig is ~/.selfhosted/config.
+type UserConfig struct {
+	// SignaturePolicy is strict, warn (the default) or off; see signing.go.
+	SignaturePolicy string          `yaml:"signature_policy,omitempty"`
+	Catalogs        []CatalogConfig `yaml:"catalogs,omitempty"`
+}
+
+// CatalogConfig configures a remote catalog.
+type CatalogConfig struct {
+	Name string `yaml:"name"`
+	// URL may contain {revision}, replaced by Revision.
+	URL string `yaml:"url"`
+	// Type is CatalogHTTP or CatalogTarball; empty picks tarball for .tar.gz/.tgz URLs.
+	Type string `yaml:"type,omitempty"`
+	// Revision pins the revision substituted into URL (a git tag or commit).
+	Revision string `yaml:"revision,omitempty"`
+	// Checksum pins the catalog content (see CatalogChecksum).
+	Checksum string `yaml:"checksum,omitempty"`
+}
+
+// CatalogCache describes a fetched catalog; it is stored next to the cached files.
+type CatalogCache struct {
+	URL       string    `json:"url"`
+	Revision  string    `json:"revision,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Checksum  string    `json:"checksum"`
+	Apps      []string  `json:"apps"`
+	// ETags of the fetched URLs, keyed by path in the catalog ("archive" for a tarball).
+	ETags map[string]string `json:"etags,omitempty"`
+}
+
+func selfhostedDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".selfhosted"), nil
+}
+
+// UserConfigPath returns the path of ~/.selfhosted/config.
+func UserConfigPath() (string, error) {
+	dir, err := selfhostedDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config"), nil
+}
+
+// LoadUserConfig reads ~/.selfhosted/config; a missing file is an empty config.
+func LoadUserConfig() (*UserConfig, error) {
+	path, err := UserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &UserConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg UserConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// SaveUserConfig writes ~/.selfhosted/config.
+func SaveUserConfig(cfg *UserConfig) error {
+	path, err := UserConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Catalog returns the catalog with the given name, or nil.
+func (c *UserConfig) Catalog(name string) *CatalogConfig {
+	for i := range c.Catalogs {
+		if c.Catalogs[i].Name == name {
+			return &c.Catalogs[i]
+		}
+	}
+	return nil
+}
+
+// ValidateCatalogName checks that name can be used as a catalog (and cache directory) name.
+func ValidateCatalogName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid catalog name %q", name)
+	}
+	for _, r := range name {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
+			return fmt.Errorf("invalid catalog name %q: use lowercase letters, digits, '-' and '_'", name)
+		}
+	}
+	return nil
+}
+
+// ResolvedURL returns the URL to fetch, with the revision substituted.
+func (c CatalogConfig) ResolvedURL() (string, error) {
+	raw := c.URL
+	if strings.Contains(raw, "{revision}") {
+		if c.Revision == "" {
+			return "", fmt.Errorf("catalog %s: url needs a revision", c.Name)
+		}
+		raw = strings.ReplaceAll(raw, "{revision}", url.PathEscape(c.Revision))
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("catalog %s: %w", c.Name, err)
+	}
+	switch {
+	case u.Scheme == "https" && u.Host != "":
+	case u.Scheme == "http" && isLoopbackHost(u.Hostname()):
+		// Plain HTTP only for catalogs served from this machine, e.g. while developing one.
+	default:
+		return "", fmt.Errorf("catalog %s: url must be https: %s", c.Name, raw)
+	}
+	return raw, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (c CatalogConfig) kind() (string, error) {
+	switch c.Type {
+	case CatalogHTTP, CatalogTarball:
+		return c.Type, nil
+	case "":
+		p := c.URL
+		if u, err := url.Parse(c.URL); err == nil {
+			p = u.Path
+		}
+		if strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz") {
+			return CatalogTarball, nil
+		}
+		return CatalogHTTP, nil
+	default:
+		return "", fmt.Errorf("catalog %s: unknown type %q (want %s or %s)", c.Name, c.Type, CatalogHTTP, CatalogTarball)
+	}
+}
+
+// CatalogsDir returns the directory holding cached catalogs.
+func CatalogsDir() (string, error) {
+	dir, err := selfhostedDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "catalogs"), nil
+}
+
+// CatalogDir returns the cache directory of a catalog.
+func CatalogDir(name string) (string, error) {
+	if err := ValidateCatalogName(name); err != nil {
+		return "", err
+	}
+	root, err := CatalogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, name), nil
+}
+
+// ReadCatalogCache returns what was fetched for a catalog, or nil if it hasn't been fetched.
+func ReadCatalogCache(name string) (*CatalogCache, error) {
+	dir, err := CatalogDir(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, catalogCacheFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cache CatalogCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("catalog %s: read cache: %w", name, err)
+	}
+	return &cache, nil
+}
+
+// RemoveCatalogCache deletes a catalog's cached files.
+func RemoveCatalogCache(name string) error {
+	dir, err := CatalogDir(name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// CatalogChecksum hashes the files of a catalog directory: "sha256:" and the SHA-256 of one
+// "<path> <sha256 of file>" line per file, sorted by path. It depends only on content, so it is
+// the same for an HTTP catalog and a tarball of the same files.
+func CatalogChecksum(dir string) (string, error) {
+	var lines []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == catalogCacheFile {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		lines = append(lines, filepath.ToSlash(rel)+" "+hex.EncodeToString(sum[:]))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func normalizeChecksum(sum string) string {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if sum != "" && !strings.HasPrefix(sum, "sha256:") {
+		sum = "sha256:" + sum
+	}
+	return sum
+}
+
+// FetchCatalog downloads a catalog, validates it and replaces its cache. changed is false when the
+// content is the same as what was cached.
+func FetchCatalog(ctx context.Context, c CatalogConfig) (cache *CatalogCache, changed bool, err error) {
+	if err := ValidateCatalogName(c.Name); err != nil {
+		return nil, false, err
+	}
+	kind, err := c.kind()
+	if err != nil {
+		return nil, false, err
+	}
+	src, err := c.ResolvedURL()
+	if err != nil {
+		return nil, false, err
+	}
+	final, err := CatalogDir(c.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	prev, err := ReadCatalogCache(c.Name)
+	if err != nil {
+		prev = nil // an unreadable cache is refetched from scratch
+	}
+	if err := os.MkdirAll(filepath.Dir(final), 0o755); err != nil {
+		return nil, false, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(final), "."+c.Name+".tmp-")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(ctx, catalogTimeout)
+	defer cancel()
+	f := &catalogFetch{ctx: ctx, prevDir: final, dst: tmp, etags: map[string]string{}}
+	if prev != nil && prev.URL == src {
+		f.prevETags = prev.ETags
+	}
+	if kind == CatalogTarball {
+		err = f.tarball(src)
+	} else {
+		err = f.index(strings.TrimSuffix(src, "/"))
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("catalog %s: %w", c.Name, err)
+	}
+
+	loaded, err := loadMarketplace(os.DirFS(tmp), tmp, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("catalog %s: %w", c.Name, err)
+	}
+	// Keep the previous copy rather than caching apps that won't load.
+	if len(loaded.broken) > 0 {
+		b := loaded.broken[0]
+		return nil, false, fmt.Errorf("catalog %s: %s: %s", c.Name, path.Base(b.File), b.Error)
+	}
+	sum, err := CatalogChecksum(tmp)
+	if err != nil {
+		return nil, false, err
+	}
+	if pin := normalizeChecksum(c.Checksum); pin != "" && pin != sum {
+		return nil, false, fmt.Errorf("catalog %s: checksum mismatch: pinned %s, fetched %s", c.Name, pin, sum)
+	}
+
+	cache = &CatalogCache{
+		URL:       src,
+		Revision:  c.Revision,
+		FetchedAt: time.Now(),
+		Checksum:  sum,
+		ETags:     f.etags,
+	}
+	for name := range loaded.apps {
+		cache.Apps = append(cache.Apps, name)
+	}
+	sort.Strings(cache.Apps)
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, catalogCacheFile), data, 0o644); err != nil {
+		return nil, false, err
+	}
+
+	// Swap the new copy in; the old one is only removed once the new one is in place.
+	old := final + ".old"
+	_ = os.RemoveAll(old)
+	if err := os.Rename(final, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		_ = os.Rename(old, final)
+		return nil, false, err
+	}
+	_ = os.RemoveAll(old)
+	return cache, prev == nil || prev.Checksum != sum, nil
+}
+
+// catalogFetch downloads catalog files into dst. A file whose ETag still matches is copied
+// from the previous cache in prevDir instead.
+type catalogFetch struct {
+	ctx       context.Context
+	prevDir   string
+	dst       string
+	prevETags map[string]string
+	etags     map[string]string
+}
+
+// get downloads src. key names the file in the ETag map.
+func (f *catalogFetch) get(src, key string) (data []byte, notModified bool, err error) {
+	req, err := http.NewRequestWithContext(f.ctx, "GET", src, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if etag := f.prevETags[key]; etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		f.etags[key] = f.prevETags[key]
+		return nil, true, nil
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, fmt.Errorf("GET %s: %w", src, errCatalogNotFound)
+	default:
+		return nil, false, fmt.Errorf("GET %s: %s", src, resp.Status)
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, catalogMaxDownload+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("GET %s: %w", src, err)
+	}
+	if len(data) > catalogMaxDownload {
+		return nil, false, fmt.Errorf("GET %s: larger than %d MiB", src, catalogMaxDownload>>20)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		f.etags[key] = etag
+	}
+	return data, false, nil
+}
+
+// file fetches one catalog file to dst/rel.
+func (f *catalogFetch) file(src, rel string) ([]byte, error) {
+	data, notModified, err := f.get(src, rel)
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		if data, err = os.ReadFile(filepath.Join(f.prevDir, filepath.FromSlash(rel))); err != nil {
+			// The server says it's unchanged but the cached copy is gone; fetch it again.
+			delete(f.prevETags, rel)
+			return f.file(src, rel)
+		}
+	}
+	return data, writeCatalogFile(f.dst, rel, data)
+}
+
+// signature fetches the detached signature of a catalog file, if the catalog has one.
+func (f *catalogFetch) signature(src, rel string) error {
+	_, err := f.file(src+SigExt, rel+SigExt)
+	if errors.Is(err, errCatalogNotFound) {
+		return nil
+	}
+	return err
+}
+
+// index fetches a catalog served as apps.yaml plus the app files it lists, with their signatures.
+func (f *catalogFetch) index(base string) error {
+	data, err := f.file(base+"/apps.yaml", "apps.yaml")
+	if err != nil {
+		return err
+	}
+	if err := f.signature(base+"/apps.yaml", "apps.yaml"); err != nil {
+		return err
+	}
+	var list appsList
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("parse apps.yaml: %w", err)
+	}
+	for _, name := range list.Apps {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name != path.Base(name) || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("apps.yaml: invalid file name %q", name)
+		}
+		src := base + "/apps/" + url.PathEscape(name)
+		data, err := f.file(src, "apps/"+name)
+		if err != nil {
+			return err
+		}
+		if err := f.signature(src, "apps/"+name); err != nil {
+			return err
+		}
+		if err := f.icon(base, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// icon fetches the icon an app file refers to with a relative metadata.icon. A missing icon is
+// skipped: the app still loads, without it.
+func (f *catalogFetch) icon(base string, app []byte) error {
+	var head struct {
+		Metadata struct {
+			Icon string `yaml:"icon"`
+		} `yaml:"metadata"`
+	}
+	if yaml.Unmarshal(app, &head) != nil {
+		return nil // reported when the catalog is validated
+	}
+	rel := path.Clean(strings.TrimSpace(head.Metadata.Icon))
+	if rel == "." || strings.Contains(rel, "://") || !fs.ValidPath(rel) || !isIconFile(rel) {
+		return nil
+	}
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	_, err := f.file(base+"/"+strings.Join(segments, "/"), rel)
+	if errors.Is(err, errCatalogNotFound) {
+		return nil
+	}
+	return err
+}
+
+// isIconFile reports whether a catalog file is an image that metadata.icon may refer to.
+func isIconFile(rel string) bool {
+	return slices.Contains(dsl.IconExtensions, strings.ToLower(path.Ext(rel)))
+}
+
+// tarball fetches a .tar.gz and extracts the catalog in it: the shallowest directory with an
+// apps.yaml (archives from git hosts wrap everything in a <repo>-<revision>/ directory).
+func (f *catalogFetch) tarball(src string) error {
+	data, notModified, err := f.get(src, "archive")
+	if err != nil {
+		return err
+	}
+	if notModified {
+		if err := copyCatalog(f.prevDir, f.dst); err == nil {
+			return nil
+		}
+		delete(f.prevETags, "archive")
+		return f.tarball(src)
+	}
+
+	root, err := tarballCatalogRoot(data)
+	if err != nil {
+		return err
+	}
+	var total int64
+	return walkTarball(data, func(name string, hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg || !strings.HasPrefix(name, root) {
+			return nil
+		}
+		rel := strings.TrimPrefix(name, root)
+		if rel != "apps.yaml" && rel != "apps.yaml"+SigExt && !strings.HasPrefix(rel, "apps/") && !strings.HasPrefix(rel, "lib/") && !isIconFile(rel) {
+			return nil
+		}
+		total += hdr.Size
+		if total > catalogMaxExtract {
+			return fmt.Errorf("archive expands to more than %d MiB", catalogMaxExtract>>20)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return writeCatalogFile(f.dst, rel, content)
+	})
+}
+
+func tarballCatalogRoot(data []byte) (string, error) {
+	root, depth := "", -1
+	err := walkTarball(data, func(name string, hdr *tar.Header, _ io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg || path.Base(name) != "apps.yaml" {
+			return nil
+		}
+		dir := strings.TrimSuffix(name, "apps.yaml")
+		if d := strings.Count(dir, "/"); depth < 0 || d < depth {
+			root, depth = dir, d
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if depth < 0 {
+		return "", fmt.Errorf("archive has no apps.yaml")
+	}
+	return root, nil
+}
+
+// walkTarball calls fn for every entry of a .tar.gz with a clean, relative name.
+func walkTarball(data []byte, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		if err := fn(name, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func writeCatalogFile(dir, rel string, data []byte) error {
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+// copyCatalog copies the cached catalog files (not the cache description) from src to dst.
+func copyCatalog(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == catalogCacheFile {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writeCatalogFile(dst, filepath.ToSlash(rel), data)
+	})
+}
+
+// loadCatalogApps adds the apps of every fetched catalog to loaded, keeping apps already there.
+// Signatures are verified under policy; a catalog whose index the policy refuses is skipped with
+// a warning. It returns the names of the catalogs it read.
+func loadCatalogApps(loaded *loadedMarketplace, policy string, keys map[string]TrustedKey) ([]string, error) {
+	cfg, err := LoadUserConfig()
+	if err != nil {
+		return nil, fmt.Errorf("apps registry: %w", err)
+	}
+	var names []string
+	for _, c := range cfg.Catalogs {
+		dir, err := CatalogDir(c.Name)
+		if err != nil {
+			return nil, fmt.Errorf("apps registry: %w", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "apps.yaml")); err != nil {
+			continue // not fetched yet
+		}
+		check := &signatureChecker{policy: policy, keys: keys, source: "catalog:" + c.Name}
+		warning, err := check.index(os.DirFS(dir))
+		if err != nil {
+			loaded.warnings = append(loaded.warnings, err.Error()+"; catalog not loaded")
+			continue
+		}
+		if warning != "" {
+			loaded.warnings = append(loaded.warnings, warning)
+		}
+		catalog, err := loadMarketplace(os.DirFS(dir), dir, check)
+		if err != nil {
+			loaded.broken = append(loaded.broken, BrokenApp{
+				File:   filepath.Join(dir, "apps.yaml"),
+				Source: check.source,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		loaded.merge(catalog)
+		names = append(names, c.Name)
+	}
+	return names, nil
+}
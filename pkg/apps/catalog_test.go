@@ -0,0 +1,224 @@
+package apps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zdunecki/selfhosted/marketplace"
+)
+
+const catalogTestApp = `apiVersion: selfhosted/v2
+app: catalog-demo
+description: Catalog demo app
+steps:
+  - name: Install
+    in: machine
+    run: echo installed
+`
+
+// catalogServer serves files with ETags and counts full (200) responses per path.
+type catalogServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string]string
+	sent  map[string]int
+}
+
+func newCatalogServer(t *testing.T, files map[string]string) *catalogServer {
+	s := &catalogServer{files: files, sent: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		data, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(data)))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.sent[r.URL.Path]++
+		w.Write([]byte(data))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *catalogServer) set(path, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+}
+
+func (s *catalogServer) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent[path]
+}
+
+func TestFetchCatalog(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(marketplace.PathEnv, "")
+	srv := newCatalogServer(t, map[string]string{
+		"/apps.yaml":      "apps:\n - demo.yaml\n",
+		"/apps/demo.yaml": catalogTestApp,
+	})
+	cfg := CatalogConfig{Name: "demo", URL: srv.URL}
+
+	cache, changed, err := FetchCatalog(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(cache.Apps) != 1 || cache.Apps[0] != "catalog-demo" {
+		t.Fatalf("first fetch: changed = %v, apps = %v", changed, cache.Apps)
+	}
+
+	// Unchanged files are revalidated with their ETag and reused from the cache.
+	cache2, changed, err := FetchCatalog(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || cache2.Checksum != cache.Checksum {
+		t.Errorf("second fetch: changed = %v, checksum %s -> %s", changed, cache.Checksum, cache2.Checksum)
+	}
+	if n := srv.count("/apps/demo.yaml"); n != 1 {
+		t.Errorf("app file sent %d times, want once (then 304)", n)
+	}
+
+	// A pinned checksum that doesn't match keeps the cached copy.
+	srv.set("/apps/demo.yaml", strings.Replace(catalogTestApp, "Catalog demo app", "Tampered app", 1))
+	pinned := cfg
+	pinned.Checksum = cache.Checksum
+	if _, _, err := FetchCatalog(context.Background(), pinned); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("pinned fetch of changed content: err = %v, want a checksum mismatch", err)
+	}
+	if got, err := ReadCatalogCache("demo"); err != nil || got.Checksum != cache.Checksum {
+		t.Fatalf("cache after a rejected fetch = %+v, %v; want the previous copy", got, err)
+	}
+
+	// The registry only reads the cache, so the catalog loads with the server gone.
+	srv.Close()
+	if err := SaveUserConfig(&UserConfig{Catalogs: []CatalogConfig{pinned}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadMarketplace(); err != nil {
+		t.Fatal(err)
+	}
+	app, err := Get("catalog-demo")
+	if err != nil {
+		t.Fatalf("offline catalog app: %v", err)
+	}
+	if app.Description() != "Catalog demo app" {
+		t.Errorf("Description() = %q, want the cached copy", app.Description())
+	}
+	if v, ok := AppVerification("catalog-demo"); !ok || v.Source != "catalog:demo" || v.Status != SigUnsigned {
+		t.Errorf("AppVerification = %+v, %v", v, ok)
+	}
+}
+
+func TestFetchCatalogTarball(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	archive := tarGz(t, map[string]string{
+		"repo-v1/README.md":      "not a catalog file",
+		"repo-v1/apps.yaml":      "apps:\n - demo.yaml\n",
+		"repo-v1/apps/demo.yaml": catalogTestApp,
+	})
+	srv := newCatalogServer(t, map[string]string{"/repo/v1.tar.gz": archive})
+	cfg := CatalogConfig{Name: "demo-tar", URL: srv.URL + "/repo/{revision}.tar.gz", Revision: "v1"}
+
+	cache, _, err := FetchCatalog(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := CatalogDir(cfg.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "apps", "demo.yaml")); err != nil {
+		t.Errorf("extracted app: %v", err)
+	}
+
+	// The checksum depends only on content, so it matches the same files served over HTTP.
+	files := newCatalogServer(t, map[string]string{
+		"/apps.yaml":      "apps:\n - demo.yaml\n",
+		"/apps/demo.yaml": catalogTestApp,
+	})
+	plain, _, err := FetchCatalog(context.Background(), CatalogConfig{Name: "demo-http", URL: files.URL, Checksum: cache.Checksum})
+	if err != nil {
+		t.Fatalf("HTTP catalog pinned to the tarball checksum: %v", err)
+	}
+	if plain.Checksum != cache.Checksum {
+		t.Errorf("checksums differ: tarball %s, http %s", cache.Checksum, plain.Checksum)
+	}
+
+	if _, _, err := FetchCatalog(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.count("/repo/v1.tar.gz"); n != 1 {
+		t.Errorf("archive sent %d times, want once (then 304)", n)
+	}
+}
+
+func TestCatalogURL(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  CatalogConfig
+		want string
+	}{
+		{CatalogConfig{Name: "a", URL: "https://example.com/c/{revision}.tar.gz", Revision: "v1.0"}, "https://example.com/c/v1.0.tar.gz"},
+		{CatalogConfig{Name: "a", URL: "http://127.0.0.1:8080/catalog"}, "http://127.0.0.1:8080/catalog"},
+		{CatalogConfig{Name: "a", URL: "http://example.com/catalog"}, ""},
+		{CatalogConfig{Name: "a", URL: "https://example.com/{revision}"}, ""},
+	} {
+		got, err := tt.cfg.ResolvedURL()
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ResolvedURL(%q) = %q, want an error", tt.cfg.URL, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolvedURL(%q) = %q, %v; want %q", tt.cfg.URL, got, err, tt.want)
+		}
+	}
+	for _, name := range []string{"", "-x", "Upper", "a/b", "../x"} {
+		if ValidateCatalogName(name) == nil {
+			t.Errorf("ValidateCatalogName(%q) accepted", name)
+		}
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
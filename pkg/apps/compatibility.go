@@ -0,0 +1,73 @@
+package apps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zdunecki/selfhosted/pkg/providers"
+)
+
+// ProviderRestricted is an optional interface apps can implement to limit the providers they can be deployed to.
+// Apps that don't implement it (or return an empty list) support every provider.
+type ProviderRestricted interface {
+	SupportedProviders() []string
+}
+
+// SupportedProviders returns the providers an app declares, or nil when it supports all of them.
+func SupportedProviders(app App) []string {
+	if pr, ok := app.(ProviderRestricted); ok {
+		if list := pr.SupportedProviders(); len(list) > 0 {
+			return list
+		}
+	}
+	return nil
+}
+
+// SupportsProvider reports whether the app can be deployed to the named provider.
+func SupportsProvider(app App, providerName string) bool {
+	list := SupportedProviders(app)
+	if list == nil {
+		return true
+	}
+	for _, p := range list {
+		if strings.EqualFold(p, providerName) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckProvider returns an error when the app doesn't declare support for the provider.
+func CheckProvider(app App, providerName string) error {
+	if SupportsProvider(app, providerName) {
+		return nil
+	}
+	return fmt.Errorf("%s does not support provider %s (supported: %s)",
+		app.Name(), providerName, strings.Join(SupportedProviders(app), ", "))
+}
+
+// CheckSize returns an error when a size doesn't meet the app's minimum CPU, RAM or disk.
+func CheckSize(app App, size providers.Size) error {
+	specs := app.MinSpecs()
+	if providers.SizeMeetsSpecs(size, specs) {
+		return nil
+	}
+	return fmt.Errorf("size %s (%d vCPU, %dMB RAM, %dGB disk) is below %s minimum (%d vCPU, %dMB RAM, %dGB disk)",
+		size.Slug, size.VCPUs, size.MemoryMB, size.DiskGB,
+		app.Name(), specs.CPUs, specs.MemoryMB, specs.DiskGB)
+}
+
+// OSRequirer is an optional interface apps can implement to request a canonical OS (see providers.OSImages).
+type OSRequirer interface {
+	OS() string
+}
+
+// OSFor returns the canonical OS the app should be deployed on, falling back to providers.DefaultOS.
+func OSFor(app App) string {
+	if r, ok := app.(OSRequirer); ok {
+		if id := strings.TrimSpace(r.OS()); id != "" {
+			return id
+		}
+	}
+	return providers.DefaultOS
+}
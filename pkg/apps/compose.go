@@ -0,0 +1,232 @@
+package apps
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// composePollInterval is how often `docker compose ps` is polled while waiting for services.
+const composePollInterval = 5 * time.Second
+
+// composeContainer is one entry of `docker compose ps --format json`.
+type composeContainer struct {
+	Service  string `json:"Service"`
+	State    string `json:"State"`
+	Health   string `json:"Health"`
+	ExitCode int    `json:"ExitCode"`
+}
+
+// runComposeStep writes the compose project, starts it and waits for the wait_healthy services.
+// On failure the services' recent logs are streamed into the deploy log.
+func runComposeStep(runner *utils.SSHRunner, spec dsl.ComposeSpec, vars map[string]string, logFunc func(string)) error {
+	dir := strings.TrimSpace(dsl.RenderTemplate(spec.Dir, vars))
+	if dir == "" {
+		return fmt.Errorf("compose: dir is required")
+	}
+	file := strings.TrimSpace(dsl.RenderTemplate(spec.File, vars))
+
+	timeout := dsl.DefaultComposeTimeout
+	if strings.TrimSpace(spec.Timeout) != "" {
+		d, err := dsl.ParseDuration(spec.Timeout)
+		if err != nil {
+			return fmt.Errorf("compose: %w", err)
+		}
+		timeout = d
+	}
+
+	// Files are written with RunSilent so their content (which may include secrets) never reaches the logs.
+	if _, err := runner.RunSilent(dsl.BuildRunCommand("mkdir -p " + dsl.ShellQuote(dir))); err != nil {
+		return fmt.Errorf("compose: create %s: %w", dir, err)
+	}
+	if spec.Content != "" {
+		name := file
+		if name == "" {
+			name = "compose.yml"
+		}
+		if err := writeRemoteFile(runner, path.Join(dir, name), dsl.RenderTemplate(spec.Content, vars), "0644"); err != nil {
+			return err
+		}
+		logFunc(fmt.Sprintf("📝 Wrote %s", path.Join(dir, name)))
+	}
+	if len(spec.Env) > 0 {
+		env, err := renderEnvFile(spec.Env, vars)
+		if err != nil {
+			return err
+		}
+		if err := writeRemoteFile(runner, path.Join(dir, ".env"), env, "0600"); err != nil {
+			return err
+		}
+		logFunc(fmt.Sprintf("📝 Wrote %s", path.Join(dir, ".env")))
+	}
+
+	project := composeProject{dir: dir, file: file}
+	if err := runner.Run(project.command("up -d")); err != nil {
+		showComposeLogs(runner, project, spec.WaitHealthy, logFunc)
+		return fmt.Errorf("compose: up failed: %w", err)
+	}
+
+	if len(spec.WaitHealthy) == 0 {
+		return nil
+	}
+
+	logFunc(fmt.Sprintf("⏳ Waiting for %s to become healthy (timeout %s)...", strings.Join(spec.WaitHealthy, ", "), timeout))
+	if err := waitComposeHealthy(runner, project, spec.WaitHealthy, timeout); err != nil {
+		showComposeLogs(runner, project, spec.WaitHealthy, logFunc)
+		return fmt.Errorf("compose: %w", err)
+	}
+	logFunc(fmt.Sprintf("✅ Services ready: %s", strings.Join(spec.WaitHealthy, ", ")))
+	return nil
+}
+
+// composeProject runs `docker compose` commands in a project directory.
+type composeProject struct {
+	dir  string
+	file string // empty means Compose's default file lookup
+}
+
+// command builds a shell command running `docker compose <args>` for each args, in order.
+func (p composeProject) command(args ...string) string {
+	base := "docker compose"
+	if p.file != "" {
+		base += " -f " + dsl.ShellQuote(p.file)
+	}
+	lines := []string{"cd " + dsl.ShellQuote(p.dir)}
+	for _, a := range args {
+		lines = append(lines, base+" "+a)
+	}
+	return dsl.BuildRunCommand(strings.Join(lines, "\n"))
+}
+
+// waitComposeHealthy polls `docker compose ps` until every service is healthy (or running when it has no
+// healthcheck). It fails early when a service's container has exited.
+func waitComposeHealthy(runner *utils.SSHRunner, project composeProject, services []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	pending := services
+	for {
+		// Compose prints warnings (e.g. about an obsolete `version`) on stderr; only stdout is JSON.
+		out, err := runner.RunSilentStdout(project.command("ps --all --format json"))
+		if err == nil {
+			containers, err := parseComposePS(out)
+			if err != nil {
+				return err
+			}
+			pending, err = composePending(containers, services)
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for services: %s", timeout, strings.Join(pending, ", "))
+		}
+		time.Sleep(composePollInterval)
+	}
+}
+
+// composePending returns the services that aren't ready yet, with their state, or an error when one has exited.
+func composePending(containers []composeContainer, services []string) ([]string, error) {
+	byService := map[string][]composeContainer{}
+	for _, c := range containers {
+		byService[c.Service] = append(byService[c.Service], c)
+	}
+
+	var pending []string
+	for _, svc := range services {
+		list := byService[svc]
+		if len(list) == 0 {
+			pending = append(pending, svc+" (not created)")
+			continue
+		}
+		for _, c := range list {
+			state := strings.ToLower(c.State)
+			health := strings.ToLower(c.Health)
+			switch {
+			case state == "exited" || state == "dead":
+				return nil, fmt.Errorf("service %s %s (exit code %d)", svc, state, c.ExitCode)
+			case health == "healthy", health == "" && state == "running":
+				continue
+			case health != "":
+				pending = append(pending, fmt.Sprintf("%s (%s)", svc, health))
+			default:
+				pending = append(pending, fmt.Sprintf("%s (%s)", svc, state))
+			}
+		}
+	}
+	return pending, nil
+}
+
+// parseComposePS accepts both output formats of `docker compose ps --format json`:
+// a JSON array (Compose < 2.21) and one JSON object per line (newer releases).
+func parseComposePS(out string) ([]composeContainer, error) {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil, nil
+	}
+	var containers []composeContainer
+	if strings.HasPrefix(out, "[") {
+		if err := json.Unmarshal([]byte(out), &containers); err != nil {
+			return nil, fmt.Errorf("parse docker compose ps: %w", err)
+		}
+		return containers, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var c composeContainer
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("parse docker compose ps: %w", err)
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// showComposeLogs streams the services' recent logs (all services when none are listed) into the deploy log.
+func showComposeLogs(runner *utils.SSHRunner, project composeProject, services []string, logFunc func(string)) {
+	logFunc("📋 Recent container logs:")
+	logs := "logs --no-color --tail 100"
+	for _, svc := range services {
+		logs += " " + dsl.ShellQuote(svc)
+	}
+	_ = runner.Run(project.command("ps --all", logs))
+}
+
+func renderEnvFile(env map[string]string, vars map[string]string) (string, error) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		v := dsl.RenderTemplate(env[k], vars)
+		if strings.ContainsAny(v, "\r\n") {
+			return "", fmt.Errorf("compose: env %s must be a single line", k)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, v)
+	}
+	return b.String(), nil
+}
+
+func writeRemoteFile(runner *utils.SSHRunner, dst, content, mode string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	script := fmt.Sprintf("printf '%%s' %s | base64 -d > %s\nchmod %s %s",
+		dsl.ShellQuote(encoded), dsl.ShellQuote(dst), mode, dsl.ShellQuote(dst))
+	if _, err := runner.RunSilent(dsl.BuildRunCommand(script)); err != nil {
+		return fmt.Errorf("compose: write %s: %w", dst, err)
+	}
+	return nil
+}
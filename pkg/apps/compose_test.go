@@ -0,0 +1,41 @@
+package apps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseComposePS(t *testing.T) {
+	want := []composeContainer{
+		{Service: "db", State: "running", Health: "healthy"},
+		{Service: "web", State: "running", Health: "starting"},
+	}
+	for name, out := range map[string]string{
+		"array":  `[{"Service":"db","State":"running","Health":"healthy"},{"Service":"web","State":"running","Health":"starting"}]`,
+		"ndjson": "{\"Service\":\"db\",\"State\":\"running\",\"Health\":\"healthy\"}\n{\"Service\":\"web\",\"State\":\"running\",\"Health\":\"starting\"}\n",
+	} {
+		got, err := parseComposePS(out)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	// Compose warnings go to stderr; mixed into the output they aren't JSON.
+	if _, err := parseComposePS("WARN[0000] the attribute `version` is obsolete\n[]"); err == nil {
+		t.Error("parseComposePS accepted a stderr warning")
+	}
+
+	pending, err := composePending(want, []string{"db", "web", "cache"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pending, []string{"web (starting)", "cache (not created)"}) {
+		t.Errorf("pending = %q", pending)
+	}
+	if _, err := composePending([]composeContainer{{Service: "db", State: "exited", ExitCode: 1}}, []string{"db"}); err == nil {
+		t.Error("composePending ignored an exited service")
+	}
+}
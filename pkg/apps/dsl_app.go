@@ -1,12 +1,15 @@
 package apps
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"regexp"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/zdunecki/selfhosted/pkg/dsl"
@@ -14,17 +17,14 @@ import (
 	"github.com/zdunecki/selfhosted/pkg/utils"
 )
 
-var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
-var ansiCSI = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
-var ansiOSC = regexp.MustCompile(`\x1b\][^\x07]*(\x07|\x1b\\)`)
-var controlChars = regexp.MustCompile(`[\x00-\x08\x0b-\x1f\x7f]`)
-
 // DSLApp is a generic App implementation backed by a YAML DSL spec.
 // This is intended to cover the common case where adding a new app only requires:
 // 1) adding <app>.yaml
 // 2) listing it in pkg/apps/apps.yaml
 type DSLApp struct {
 	spec dsl.Spec
+	// files is the marketplace or catalog the spec was loaded from; metadata.icon is relative to it.
+	files fs.FS
 }
 
 func NewDSLApp(spec dsl.Spec) *DSLApp {
@@ -46,8 +46,8 @@ func (a *DSLApp) Description() string {
 }
 
 func (a *DSLApp) DomainHint() string {
-	if strings.TrimSpace(a.spec.DomainHint) != "" {
-		return strings.TrimSpace(a.spec.DomainHint)
+	if strings.TrimSpace(a.spec.Wizard.DomainHint) != "" {
+		return strings.TrimSpace(a.spec.Wizard.DomainHint)
 	}
 	return "Example: app.your-domain.com"
 }
@@ -75,6 +75,50 @@ func (a *DSLApp) MinSpecs() providers.Specs {
 	}
 }
 
+func (a *DSLApp) SupportedProviders() []string {
+	out := make([]string, 0, len(a.spec.Providers))
+	for _, p := range a.spec.Providers {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Metadata returns the spec's `metadata` section.
+func (a *DSLApp) Metadata() Metadata {
+	m := a.spec.Metadata
+	return Metadata{
+		DisplayName: strings.TrimSpace(m.DisplayName),
+		Category:    strings.TrimSpace(m.Category),
+		Tags:        m.Tags,
+		Homepage:    strings.TrimSpace(m.Homepage),
+		Repository:  strings.TrimSpace(m.Repository),
+		License:     strings.TrimSpace(m.License),
+		Icon:        strings.TrimSpace(m.Icon),
+		Version:     strings.TrimSpace(m.Version),
+	}
+}
+
+// Versions returns the spec's `versions` section.
+func (a *DSLApp) Versions() []dsl.VersionSpec {
+	return a.spec.Versions
+}
+
+// ReadIcon reads metadata.icon from the marketplace or catalog the app was loaded from.
+func (a *DSLApp) ReadIcon() ([]byte, error) {
+	icon := strings.TrimSpace(a.spec.Metadata.Icon)
+	if a.files == nil || icon == "" {
+		return nil, fmt.Errorf("app %s has no icon file", a.Name())
+	}
+	return fs.ReadFile(a.files, path.Clean(icon))
+}
+
+// OS returns the canonical OS the app requests via `os`.
+func (a *DSLApp) OS() string {
+	return a.spec.OS
+}
+
 func (a *DSLApp) Install(config *InstallConfig) error {
 	return a.runSteps(config, false)
 }
@@ -84,9 +128,22 @@ func (a *DSLApp) SetupSSL(config *InstallConfig) error {
 }
 
 func (a *DSLApp) runSteps(config *InstallConfig, conditional bool) error {
+	// Secrets (and password answers) are redacted from every log line and from the raw PTY stream (which bypasses the line logger).
+	secretValues := make([]string, 0, len(config.Secrets))
+	for _, v := range config.Secrets {
+		secretValues = append(secretValues, v)
+	}
+	redactor := utils.NewRedactor(append(secretValues, config.SensitiveValues...)...)
+	if config.Logger != nil {
+		cfg := *config
+		cfg.Logger = redactor.Logger(config.Logger)
+		config = &cfg
+	}
+
 	runner := utils.NewSSHRunner(config.ServerIP, config.SSHUser, config.SSHKey)
 	defer runner.Close()
 
+	runner.SetRedactor(redactor)
 	if config.Logger != nil {
 		runner.SetLogger(config.Logger)
 	}
@@ -104,31 +161,46 @@ func (a *DSLApp) runSteps(config *InstallConfig, conditional bool) error {
 
 	// We implement the step loop here (instead of dsl.RunStepsWithConfig) so we can support interactive PTY steps.
 	vars := dsl.BuildVarsFromStruct(config)
-	// Merge ExtraVars (used for wizard answers and any app-specific template keys)
+	// Merge ExtraVars (any app-specific template keys)
 	if config.ExtraVars != nil {
 		for k, v := range config.ExtraVars {
 			vars[k] = v
 		}
 	}
+	for name, v := range config.Secrets {
+		vars[fmt.Sprintf("{secrets.%s}", name)] = v
+	}
+	version, err := dsl.FindVersion(a.spec.Versions, config.Version)
+	if err != nil {
+		return fmt.Errorf("app %s: %w", a.Name(), err)
+	}
+	if version != nil {
+		for name, v := range version.Vars {
+			vars[fmt.Sprintf("{version.%s}", name)] = v
+		}
+	}
 	bools := dsl.BuildBoolsFromStruct(config)
+	wizardVars, wizardBools := dsl.WizardVars(a.spec.Wizard.Steps.Application.CustomQuestions, config.WizardAnswers)
+	for k, v := range wizardVars {
+		vars[k] = v
+	}
+	for k, v := range wizardBools {
+		bools[k] = v
+	}
 
 	for _, step := range a.spec.Steps {
-		hasCondition := strings.TrimSpace(step.If) != ""
-		if conditional && !hasCondition {
+		if conditional != step.SSLPhase() {
 			continue
 		}
-		if !conditional && hasCondition {
-			continue
-		}
-		if hasCondition && !dsl.EvaluateCondition(step.If, bools) {
+		if strings.TrimSpace(step.If) != "" && !dsl.EvaluateCondition(step.If, bools) {
 			continue
 		}
 
 		if step.Name != "" && logFunc != nil {
-			logFunc("⏳ " + step.Name)
+			logFunc(redactor.Redact("⏳ " + step.Title()))
 		}
 		if strings.TrimSpace(step.Log) != "" && logFunc != nil {
-			logFunc(dsl.RenderTemplate(step.Log, vars))
+			logFunc(redactor.Redact(dsl.RenderTemplate(step.Log, vars)))
 		}
 		if step.Sleep != "" {
 			dur, err := dsl.ParseDuration(step.Sleep)
@@ -138,6 +210,19 @@ func (a *DSLApp) runSteps(config *InstallConfig, conditional bool) error {
 			time.Sleep(dur)
 		}
 
+		if step.Compose != nil {
+			if err := runComposeStep(runner, *step.Compose, vars, logFunc); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Parallel != nil {
+			if err := runParallelStep(runner, *step.Parallel, vars, bools, config.Logger); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if strings.TrimSpace(step.Run) == "" {
 			continue
 		}
@@ -145,124 +230,98 @@ func (a *DSLApp) runSteps(config *InstallConfig, conditional bool) error {
 
 		if step.TTY.Enabled {
 			// Interactive/TUI step: allocate a PTY and stream raw output to the installer UI.
+			// Expect rules and fail_on patterns are matched against a screen emulating the PTY.
+			pty := utils.PTYOptions{Term: step.TTY.Term, Rows: step.TTY.Rows, Cols: step.TTY.Cols}.WithDefaults()
+			expect, err := newExpectSession(step.TTY, pty.Rows, pty.Cols, vars, logFunc)
+			if err != nil {
+				return err
+			}
 			sessionID := randomID()
+			// Clients watch the session over /api/pty/{id}/ws; only the deploying client gets the token to type.
+			session := utils.RegisterPTY(sessionID, pty.Rows, pty.Cols)
 			if config.Logger != nil {
-				config.Logger("[SELFHOSTED::PTY_SESSION] %s\n", sessionID)
+				config.Logger("[SELFHOSTED::PTY_SESSION] %s %s\n", sessionID, session.Token)
 			}
 
-			// Keep a rolling text buffer of PTY output for wait_for matching (best-effort; ANSI junk may exist).
-			var outMu sync.Mutex
-			var outBuf strings.Builder
-			outChanged := make(chan struct{}, 1)
+			ctx, cancelCause := context.WithCancelCause(context.Background())
+			cancel := func(err error) { cancelCause(err) }
+			if strings.TrimSpace(step.TTY.Timeout) != "" {
+				timeout, err := dsl.ParseDuration(step.TTY.Timeout)
+				if err != nil {
+					cancel(nil)
+					utils.ClosePTY(sessionID)
+					return err
+				}
+				var cancelTimeout context.CancelFunc
+				ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("tty step timed out after %s", timeout))
+				cancel = func(err error) {
+					cancelCause(err)
+					cancelTimeout()
+				}
+			}
+
+			rec := recordPTY(config.RecordDir, sessionID, step.Title(), pty, redactor, logFunc)
 
-			stdin, wait, err := runner.RunPTY(cmd, func(chunk []byte) {
-				if config.Logger == nil || len(chunk) == 0 {
+			// Secrets can be split across chunks, so the output is redacted as a stream.
+			output := redactor.Stream()
+			emit := func(redacted []byte) {
+				if len(redacted) == 0 {
 					return
 				}
-				// Send raw bytes via SSE as base64 to preserve ANSI + cursor movements.
-				b64 := base64.StdEncoding.EncodeToString(chunk)
-				config.Logger("[SELFHOSTED::PTY] %s\n", b64)
-
-				// Append to rolling buffer for auto-answer prompt matching
-				outMu.Lock()
-				outBuf.Write(chunk)
-				// cap memory (keep last ~64KB)
-				s := outBuf.String()
-				if len(s) > 65536 {
-					outBuf.Reset()
-					outBuf.WriteString(s[len(s)-65536:])
+				session.Output(redacted)
+				if config.Logger != nil {
+					// Also send raw bytes via SSE as base64 (ANSI + cursor movements preserved), for clients without the socket.
+					config.Logger("[SELFHOSTED::PTY] %s\n", base64.StdEncoding.EncodeToString(redacted))
+				}
+			}
+			stdin, wait, err := runner.RunPTYContext(ctx, cmd, pty, func(chunk []byte) {
+				if len(chunk) == 0 {
+					return
 				}
-				outMu.Unlock()
-				select {
-				case outChanged <- struct{}{}:
-				default:
+				emit(output.Redact(chunk))
+				if rec != nil {
+					rec.Output(chunk)
 				}
+				expect.Write(chunk)
 			})
 			if err != nil {
+				cancel(nil)
+				utils.ClosePTY(sessionID)
+				if rec != nil {
+					rec.Close()
+				}
 				if config.Logger != nil {
 					config.Logger("[SELFHOSTED::PTY_END] %s\n", sessionID)
 				}
 				return err
 			}
-
-			utils.RegisterPTY(sessionID, stdin)
-
-			// Optional: backend-driven auto-answer from YAML (best-effort).
-			if len(step.TTY.AutoAnswer) > 0 {
-				go func() {
-					// Give the TUI a moment to render the first prompt.
-					time.Sleep(800 * time.Millisecond)
-					for _, a := range step.TTY.AutoAnswer {
-						// Wait for prompt match if configured
-						if strings.TrimSpace(a.WaitFor) != "" {
-							timeout := a.TimeoutMS
-							if timeout <= 0 {
-								timeout = 10 * 60 * 1000
-							}
-							deadline := time.Now().Add(time.Duration(timeout) * time.Millisecond)
-
-							var re *regexp.Regexp
-							if a.WaitForRegex {
-								if compiled, err := regexp.Compile(a.WaitFor); err == nil {
-									re = compiled
-								}
-							}
-
-							for {
-								outMu.Lock()
-								cur := stripANSI(outBuf.String())
-								outMu.Unlock()
-
-								matched := false
-								if re != nil {
-									matched = re.MatchString(cur)
-								} else {
-									matched = strings.Contains(cur, a.WaitFor)
-								}
-								if matched {
-									break
-								}
-								if time.Now().After(deadline) {
-									// Give up on this answer
-									break
-								}
-								// Wait for more output or timeout tick
-								select {
-								case <-outChanged:
-								case <-time.After(250 * time.Millisecond):
-								}
-							}
-						}
-
-						if a.DelayMS > 0 {
-							time.Sleep(time.Duration(a.DelayMS) * time.Millisecond)
-						} else {
-							time.Sleep(350 * time.Millisecond)
-						}
-						// IMPORTANT: preserve raw control sequences like "\r" or "\t\r".
-						// Some prompts (inquirer/whiptail) require Enter/Tab+Enter and trimming would drop them.
-						renderedRaw := dsl.RenderTemplate(a.Value, vars)
-						val := renderedRaw
-						if !strings.Contains(a.Value, "\n") && !strings.Contains(a.Value, "\r") {
-							// For normal string answers, trim trailing newlines and send + Enter.
-							val = strings.TrimRight(val, "\r\n")
-						}
-						// Convenience: treat "true/false" as y/n
-						if strings.EqualFold(strings.TrimSpace(val), "true") {
-							val = "y"
-						} else if strings.EqualFold(strings.TrimSpace(val), "false") {
-							val = "n"
-						}
-						// Ensure enter unless caller provided explicit CR/LF in the YAML value.
-						if !strings.Contains(a.Value, "\n") && !strings.Contains(a.Value, "\r") {
-							val = val + "\r"
-						}
-						_, _ = stdin.Write([]byte(val))
-					}
-				}()
+			if rec != nil {
+				// Record keystrokes from the UI and from expect rules alike.
+				stdin = rec.RecordInput(stdin)
 			}
+
+			session.Attach(stdin, func(rows, cols int) error {
+				if err := runner.WindowChange(rows, cols); err != nil {
+					return err
+				}
+				expect.Resize(rows, cols)
+				if rec != nil {
+					rec.Resize(rows, cols)
+				}
+				return nil
+			})
+			go func() {
+				if err := expect.Run(ctx, stdin); err != nil {
+					cancel(err)
+				}
+			}()
 			err = wait()
+			cancel(nil)
+			emit(output.Flush())
 			utils.ClosePTY(sessionID)
+			if rec != nil {
+				rec.Close()
+			}
 			if config.Logger != nil {
 				config.Logger("[SELFHOSTED::PTY_END] %s\n", sessionID)
 			}
@@ -270,24 +329,60 @@ func (a *DSLApp) runSteps(config *InstallConfig, conditional bool) error {
 				return err
 			}
 		} else {
-			if err := runner.Run(cmd); err != nil {
+			value, err := runCommandStep(context.Background(), runner, step, cmd)
+			if err != nil {
 				return err
 			}
+			registerStepValue(step, value, vars, bools)
 		}
 	}
 
 	return nil
 }
 
-func stripANSI(s string) string {
-	if s == "" {
-		return s
+// runCommandStep runs a non-interactive step's command and returns the value to register, if any.
+func runCommandStep(ctx context.Context, runner *utils.SSHRunner, step dsl.Step, cmd string) (string, error) {
+	if step.Register.Name == "" {
+		return "", runner.RunContext(ctx, cmd)
+	}
+	out, err := runner.RunWithOutputContext(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+	return step.Register.Extract(out)
+}
+
+// registerStepValue exposes a registered step output to later templates and conditions.
+func registerStepValue(step dsl.Step, value string, vars map[string]string, bools map[string]bool) {
+	if step.Register.Name == "" {
+		return
 	}
-	// Remove OSC first, then CSI, then remaining control chars.
-	s = ansiOSC.ReplaceAllString(s, "")
-	s = ansiCSI.ReplaceAllString(s, "")
-	s = controlChars.ReplaceAllString(s, "")
-	return s
+	vars["{steps."+step.Register.Name+"}"] = value
+	bools["steps."+step.Register.Name] = dsl.Truthy(value)
+}
+
+// recordPTY starts recording a PTY session to dir/<session>.cast, with secrets redacted. It returns
+// nil when dir is empty; a recording that cannot be created is logged and skipped.
+func recordPTY(dir, sessionID, title string, pty utils.PTYOptions, redactor *utils.Redactor, logf func(string)) *utils.CastRecorder {
+	if dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0700)
+	var rec *utils.CastRecorder
+	if err == nil {
+		rec, err = utils.NewCastRecorder(filepath.Join(dir, sessionID+".cast"), utils.CastHeader{
+			Width:  pty.Cols,
+			Height: pty.Rows,
+			Title:  title,
+			Env:    map[string]string{"TERM": pty.Term},
+		})
+	}
+	if err != nil {
+		logf(fmt.Sprintf("⚠️  Not recording the terminal session: %v", err))
+		return nil
+	}
+	rec.Redactor = redactor
+	return rec
 }
 
 func (a *DSLApp) PrintSummary(ip, domain string) {
@@ -354,6 +449,22 @@ func (a *DSLApp) DNSRecords(domain string, serverIP string) []DNSRecord {
 	return out
 }
 
+func (a *DSLApp) SecretSpecs() []SecretSpec {
+	if len(a.spec.Secrets) == 0 {
+		return nil
+	}
+	out := make([]SecretSpec, 0, len(a.spec.Secrets))
+	for _, s := range a.spec.Secrets {
+		out = append(out, SecretSpec{
+			Name:     strings.TrimSpace(s.Name),
+			Length:   s.Length,
+			Charset:  s.Charset,
+			Encoding: s.Encoding,
+		})
+	}
+	return out
+}
+
 func (a *DSLApp) WizardQuestions() []WizardQuestion {
 	qs := a.spec.Wizard.Steps.Application.CustomQuestions
 	if len(qs) == 0 {
@@ -362,16 +473,25 @@ func (a *DSLApp) WizardQuestions() []WizardQuestion {
 
 	out := make([]WizardQuestion, 0, len(qs))
 	for _, q := range qs {
-		id := strings.TrimSpace(q.ID)
-		if id == "" {
-			id = slugify(q.Name)
-		}
 		wq := WizardQuestion{
-			ID:       id,
-			Name:     q.Name,
-			Type:     strings.ToLower(strings.TrimSpace(q.Type)),
-			Required: q.Required,
-			Default:  q.Default,
+			ID:            dsl.QuestionID(q),
+			Name:          q.Name,
+			Type:          NormalizeQuestionType(q.Type),
+			Required:      q.Required,
+			Default:       q.Default,
+			Help:          q.Help,
+			Placeholder:   q.Placeholder,
+			Pattern:       q.Pattern,
+			Min:           q.Min,
+			Max:           q.Max,
+			MinLength:     q.MinLength,
+			MaxLength:     q.MaxLength,
+			AllowedValues: q.AllowedValues,
+			ShowIf:        strings.TrimSpace(q.ShowIf),
+		}
+		if wq.ShowIf != "" {
+			// Specs are linted when loaded, so the expression parses.
+			wq.ShowIfTerms, _ = dsl.ParseCondition(wq.ShowIf)
 		}
 		if len(q.Choices) > 0 {
 			wq.Choices = make([]WizardChoice, 0, len(q.Choices))
@@ -393,13 +513,3 @@ func randomID() string {
 	// URL-safe-ish without extra deps
 	return base64.RawURLEncoding.EncodeToString(b)
 }
-
-func slugify(s string) string {
-	s = strings.ToLower(strings.TrimSpace(s))
-	s = nonAlnum.ReplaceAllString(s, "-")
-	s = strings.Trim(s, "-")
-	if s == "" {
-		return "q"
-	}
-	return s
-}
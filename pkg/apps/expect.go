@@ -0,0 +1,239 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// expectPattern is a compiled `pattern` of an expect rule or fail_on entry.
+type expectPattern struct {
+	text string
+	re   *regexp.Regexp
+}
+
+func compileExpectPattern(pattern string, regex bool) (expectPattern, error) {
+	p := expectPattern{text: pattern}
+	if regex && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		p.re = re
+	}
+	return p, nil
+}
+
+// screenText is the screen as patterns see it: soft-wrapped rows are joined, so a prompt matches
+// at any terminal width.
+type screenText struct {
+	text     string
+	starts   []int // the row each line of text starts on
+	scrolled int
+}
+
+func newScreenText(screen *utils.Screen) screenText {
+	text, starts := screen.Text()
+	return screenText{text: text, starts: starts, scrolled: screen.Scrolled()}
+}
+
+// line returns the text line starting on row (counted across scrolling).
+func (s screenText) line(row int) string {
+	for i, start := range s.starts {
+		if s.scrolled+start == row {
+			return strings.TrimSpace(strings.Split(s.text, "\n")[i])
+		}
+	}
+	return ""
+}
+
+// find returns the row (counted across scrolling) where the line with the last match starts.
+func (p expectPattern) find(screen screenText) (int, bool) {
+	if p.text == "" {
+		return -1, true
+	}
+	idx := -1
+	if p.re != nil {
+		if all := p.re.FindAllStringIndex(screen.text, -1); len(all) > 0 {
+			idx = all[len(all)-1][0]
+		}
+	} else {
+		idx = strings.LastIndex(screen.text, p.text)
+	}
+	if idx < 0 {
+		return 0, false
+	}
+	return screen.scrolled + screen.starts[strings.Count(screen.text[:idx], "\n")], true
+}
+
+// expectRuleState tracks when a rule may fire: once, or for repeatable rules again when the
+// pattern shows up on another line or after it left the screen.
+type expectRuleState struct {
+	rule    dsl.ExpectRule
+	pattern expectPattern
+	fired   bool
+	line    int
+	gone    bool
+	// after is the rule that must fire first (auto_answer order), or nil.
+	after *expectRuleState
+}
+
+// ready reports whether an ordered rule's turn has come: the rule before it fired, and the
+// pattern showed up below the prompt that rule answered, or after that prompt left the screen.
+// So two identical prompts are answered one at a time.
+func (s *expectRuleState) ready(line int) bool {
+	if s.after == nil {
+		return true
+	}
+	if !s.after.fired {
+		return false
+	}
+	return s.pattern.text == "" || s.gone || line > s.after.line
+}
+
+type expectFailure struct {
+	pattern expectPattern
+	message string
+}
+
+// expectSession drives a TTY step: PTY output is applied to a Screen, and rules answer the prompts
+// they see on it. Failure patterns end the session with an error.
+type expectSession struct {
+	mu      sync.Mutex
+	screen  *utils.Screen
+	changed chan struct{}
+
+	rules  []*expectRuleState
+	failOn []expectFailure
+	vars   map[string]string
+	logf   func(string)
+}
+
+func newExpectSession(tty dsl.TTYSpec, rows, cols int, vars map[string]string, logf func(string)) (*expectSession, error) {
+	e := &expectSession{
+		screen:  utils.NewScreen(rows, cols),
+		changed: make(chan struct{}, 1),
+		vars:    vars,
+		logf:    logf,
+	}
+	var prev *expectRuleState
+	for _, rule := range tty.Rules() {
+		p, err := compileExpectPattern(rule.Pattern, rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("expect: %w", err)
+		}
+		s := &expectRuleState{rule: rule, pattern: p}
+		if rule.AfterPrevious() {
+			s.after, prev = prev, s
+		}
+		e.rules = append(e.rules, s)
+	}
+	for _, f := range tty.FailOn {
+		p, err := compileExpectPattern(f.Pattern, f.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("fail_on: %w", err)
+		}
+		msg := strings.TrimSpace(f.Message)
+		if msg == "" {
+			msg = fmt.Sprintf("output matched fail_on pattern %q", f.Pattern)
+		}
+		e.failOn = append(e.failOn, expectFailure{pattern: p, message: msg})
+	}
+	return e, nil
+}
+
+// Write feeds PTY output to the screen.
+func (e *expectSession) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	e.screen.Write(p)
+	e.mu.Unlock()
+	select {
+	case e.changed <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// Resize follows a terminal size change, so the screen wraps like the PTY.
+func (e *expectSession) Resize(rows, cols int) {
+	e.mu.Lock()
+	e.screen.Resize(rows, cols)
+	e.mu.Unlock()
+}
+
+// Run answers prompts until ctx is done. It returns an error when a fail_on pattern shows up.
+func (e *expectSession) Run(ctx context.Context, stdin io.Writer) error {
+	if len(e.rules) == 0 && len(e.failOn) == 0 {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-e.changed:
+		case <-time.After(250 * time.Millisecond):
+		}
+
+		e.mu.Lock()
+		screen := newScreenText(e.screen)
+		e.mu.Unlock()
+
+		for _, f := range e.failOn {
+			if row, ok := f.pattern.find(screen); ok {
+				return fmt.Errorf("%s (screen line: %q)", f.message, screen.line(row))
+			}
+		}
+
+		rule := e.next(screen)
+		if rule == nil {
+			continue
+		}
+		if rule.Pattern != "" && e.logf != nil {
+			e.logf(fmt.Sprintf("↳ expect: answering %q", rule.Pattern))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(rule.Delay()):
+		}
+		if _, err := stdin.Write([]byte(rule.Input(e.vars))); err != nil {
+			return nil
+		}
+	}
+}
+
+// next returns the first rule that should fire for the screen, and records that it fired.
+func (e *expectSession) next(screen screenText) *dsl.ExpectRule {
+	var fire *expectRuleState
+	for _, s := range e.rules {
+		line, ok := s.pattern.find(screen)
+		if !ok {
+			s.gone = true
+			continue
+		}
+		if fire != nil || !s.ready(line) {
+			continue
+		}
+		if !s.fired || (s.rule.Repeat && (s.gone || line != s.line)) {
+			fire = s
+			s.fired, s.gone, s.line = true, false, line
+		}
+	}
+	if fire == nil {
+		return nil
+	}
+	for _, s := range e.rules {
+		if s.after == fire {
+			// Only a disappearance after this answer counts as the next prompt showing up anew.
+			s.gone = false
+		}
+	}
+	return &fire.rule
+}
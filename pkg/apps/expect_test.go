@@ -0,0 +1,170 @@
+package apps
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"gopkg.in/yaml.v3"
+)
+
+const caddyPrompt = "? Do you already have a web service setup on your server (nginx, apache, ...)? If not, we will install Caddy with SSL? (Y/n)"
+
+func newTestSession(t *testing.T, tty dsl.TTYSpec, rows, cols int) *expectSession {
+	t.Helper()
+	e, err := newExpectSession(tty, rows, cols, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// Prompts match whatever the terminal width, including when they soft-wrap.
+func TestExpectMatchesWrappedPrompt(t *testing.T) {
+	tty := dsl.TTYSpec{Expect: []dsl.ExpectRule{{
+		Regex:    true,
+		Pattern:  `Do you already have a web service setup.*install Caddy with SSL\?`,
+		SendKeys: []string{"enter"},
+	}}}
+	for _, cols := range []int{120, 80, 40, 17} {
+		e := newTestSession(t, tty, 40, cols)
+		e.Write([]byte("Installing...\r\n" + caddyPrompt))
+		if rule := e.next(newScreenText(e.screen)); rule == nil {
+			t.Errorf("%d columns: prompt not matched on screen:\n%s", cols, e.screen.String())
+		}
+	}
+}
+
+func TestExpectResizeRewrapsPrompt(t *testing.T) {
+	tty := dsl.TTYSpec{Expect: []dsl.ExpectRule{{Pattern: "install Caddy with SSL?"}}}
+	e := newTestSession(t, tty, 24, 120)
+	e.Resize(24, 40)
+	e.Write([]byte(caddyPrompt))
+	if rule := e.next(newScreenText(e.screen)); rule == nil {
+		t.Fatalf("prompt not matched after resize:\n%s", e.screen.String())
+	}
+}
+
+func TestExpectHardLineBreaksStaySeparate(t *testing.T) {
+	tty := dsl.TTYSpec{Expect: []dsl.ExpectRule{{Pattern: "foobar"}}}
+	e := newTestSession(t, tty, 5, 40)
+	e.Write([]byte("foo\r\nbar"))
+	if rule := e.next(newScreenText(e.screen)); rule != nil {
+		t.Fatal("pattern matched across a hard line break")
+	}
+}
+
+func TestExpectRepeatFiresOnNewLine(t *testing.T) {
+	tty := dsl.TTYSpec{Expect: []dsl.ExpectRule{{Pattern: "Continue?", Repeat: true}}}
+	e := newTestSession(t, tty, 10, 20)
+	e.Write([]byte("Continue? "))
+	if e.next(newScreenText(e.screen)) == nil {
+		t.Fatal("first prompt not answered")
+	}
+	if e.next(newScreenText(e.screen)) != nil {
+		t.Fatal("same prompt answered twice")
+	}
+	e.Write([]byte("y\r\nworking " + strings.Repeat(".", 30) + "\r\nContinue? "))
+	if e.next(newScreenText(e.screen)) == nil {
+		t.Fatal("prompt on a new line not answered")
+	}
+}
+
+func TestExpectFailOnReportsWrappedLine(t *testing.T) {
+	tty := dsl.TTYSpec{FailOn: []dsl.ExpectFailure{{Pattern: "permission denied", Message: "no access"}}}
+	e := newTestSession(t, tty, 10, 20)
+	e.Write([]byte("ok\r\nmkdir: /opt/x: permission denied\r\n"))
+	screen := newScreenText(e.screen)
+	row, ok := e.failOn[0].pattern.find(screen)
+	if !ok {
+		t.Fatalf("fail_on pattern not found on screen:\n%s", e.screen.String())
+	}
+	if got, want := screen.line(row), "mkdir: /opt/x: permission denied"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+}
+
+// promptScript plays a program that asks prompts in order, showing the next one a moment after
+// each answer. Answers typed before their prompt showed up are recorded as early; answers past
+// the last prompt are expected right away. done is called after want answers.
+type promptScript struct {
+	e       *expectSession
+	prompts []string
+	want    int
+	done    func()
+
+	mu      sync.Mutex
+	shown   int
+	answers []string
+	early   []string
+}
+
+func (p *promptScript) show() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.e.Write([]byte(p.prompts[p.shown]))
+	p.shown++
+}
+
+func (p *promptScript) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	answer := strings.TrimSpace(string(b))
+	p.answers = append(p.answers, answer)
+	if n := len(p.answers); n > p.shown && n <= len(p.prompts) {
+		p.early = append(p.early, answer)
+	}
+	p.e.Write([]byte(string(b) + "\n"))
+	if len(p.answers) == p.want {
+		p.done()
+	} else if len(p.answers) == p.shown && p.shown < len(p.prompts) {
+		time.AfterFunc(50*time.Millisecond, p.show)
+	}
+	return len(b), nil
+}
+
+// Converted auto_answer entries answer in order, each once its own prompt shows up, even when two
+// prompts are identical. An entry without wait_for answers right after the one before it.
+func TestAutoAnswerKeepsOrder(t *testing.T) {
+	var step struct {
+		TTY dsl.TTYSpec `yaml:"tty"`
+	}
+	err := yaml.Unmarshal([]byte(`
+tty:
+  auto_answer:
+    - wait_for: "(y/N)"
+      value: "true"
+      delay_ms: 1
+    - wait_for: "(y/N)"
+      value: "false"
+      delay_ms: 1
+    - value: "admin"
+      delay_ms: 1
+`), &step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newTestSession(t, step.TTY, 10, 40)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	script := &promptScript{e: e, prompts: []string{"Remove old data? (y/N) ", "Keep backups? (y/N) "}, want: 3, done: cancel}
+
+	// Nothing may answer before the program asks.
+	if rule := e.next(newScreenText(e.screen)); rule != nil {
+		t.Fatalf("rule %q fired on an empty screen", rule.Pattern)
+	}
+	script.show()
+	if err := e.Run(ctx, script); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"y", "n", "admin"}; !reflect.DeepEqual(script.answers, want) {
+		t.Errorf("answers = %q, want %q\n%s", script.answers, want, e.screen.String())
+	}
+	if len(script.early) > 0 {
+		t.Errorf("answered %q before the prompt showed up", script.early)
+	}
+}
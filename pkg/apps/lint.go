@@ -0,0 +1,42 @@
+package apps
+
+import (
+	"os"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"github.com/zdunecki/selfhosted/pkg/providers"
+)
+
+// LintOptions returns the environment DSL specs are validated against:
+// registered providers, supported OS images and the InstallConfig fields exposed to templates.
+func LintOptions() dsl.LintOptions {
+	opts := dsl.LintOptions{
+		Providers: make([]string, 0, len(providers.Registry)),
+		OSImages:  providers.SupportedOS(),
+	}
+	for name := range providers.Registry {
+		opts.Providers = append(opts.Providers, name)
+	}
+	for key := range dsl.BuildVarsFromStruct(&InstallConfig{}) {
+		opts.Vars = append(opts.Vars, key)
+	}
+	for key := range dsl.BuildBoolsFromStruct(&InstallConfig{}) {
+		opts.Bools = append(opts.Bools, key)
+	}
+	return opts
+}
+
+// LintSpec parses and validates app spec data. file is used for error locations only.
+func LintSpec(file string, data []byte) (dsl.Spec, error) {
+	setupLibraries()
+	return dsl.Lint(file, data, LintOptions())
+}
+
+// LintFile reads and validates an app spec file.
+func LintFile(path string) (dsl.Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dsl.Spec{}, err
+	}
+	return LintSpec(path, data)
+}
@@ -0,0 +1,257 @@
+package apps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdunecki/selfhosted/marketplace"
+)
+
+// MarketplacePollInterval is how often WatchMarketplace checks the marketplace directories for changes.
+const MarketplacePollInterval = 2 * time.Second
+
+// MarketplaceStatus describes the marketplace version being served and the last load attempt.
+type MarketplaceStatus struct {
+	// Layers are the marketplace directories apps were loaded from, in order of precedence,
+	// ending with "built-in".
+	Layers []string `json:"layers"`
+	// Apps are the app names loaded from the marketplace (apps implemented in Go are not listed).
+	Apps []string `json:"apps"`
+	// Catalogs are the fetched remote catalogs whose apps were loaded too (see catalog.go).
+	Catalogs []string `json:"catalogs,omitempty"`
+	// SignaturePolicy is the policy apps were verified under (see signing.go).
+	SignaturePolicy string `json:"signature_policy"`
+	// Warnings are apps and catalogs that failed signature verification, and trust store problems.
+	Warnings []string `json:"warnings,omitempty"`
+	// Broken are the app files and indexes that failed to load; the rest of the marketplace is served.
+	Broken []BrokenApp `json:"broken,omitempty"`
+	// LoadedAt is when the version being served was loaded.
+	LoadedAt time.Time `json:"loaded_at"`
+	// CheckedAt is when the marketplace was last (re)loaded, successfully or not.
+	CheckedAt time.Time `json:"checked_at"`
+	// Error is set when the last load failed; the previous good version is still being served.
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	marketplaceMu     sync.Mutex
+	marketplaceStatus MarketplaceStatus
+	appVerifications  = map[string]Verification{}
+)
+
+// GetMarketplaceStatus returns the current marketplace status.
+func GetMarketplaceStatus() MarketplaceStatus {
+	marketplaceMu.Lock()
+	defer marketplaceMu.Unlock()
+	status := marketplaceStatus
+	status.Layers = append([]string(nil), marketplaceStatus.Layers...)
+	status.Apps = append([]string(nil), marketplaceStatus.Apps...)
+	status.Catalogs = append([]string(nil), marketplaceStatus.Catalogs...)
+	status.Warnings = append([]string(nil), marketplaceStatus.Warnings...)
+	status.Broken = append([]BrokenApp(nil), marketplaceStatus.Broken...)
+	return status
+}
+
+// AppVerification returns the signature verification of a marketplace or catalog app. Apps
+// implemented in Go have none.
+func AppVerification(name string) (Verification, bool) {
+	marketplaceMu.Lock()
+	defer marketplaceMu.Unlock()
+	v, ok := appVerifications[name]
+	return v, ok
+}
+
+// ReloadMarketplace revalidates the marketplace and swaps it into the registry. Apps that fail to
+// load are listed in the status; those that loaded before keep their last good version, the rest
+// are left out. When the marketplace can't be loaded at all (e.g.
+// ~/.selfhosted/config doesn't parse) the registry is left untouched and the error is recorded in the status.
+func ReloadMarketplace() error {
+	return reloadMarketplace()
+}
+
+func reloadMarketplace() error {
+	layers, warnings := marketplaceLayers()
+	policy, err := SignaturePolicy()
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	keys, err := LoadTrustedKeys()
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	loaded, err := loadMarketplaceLayers(layers, policy, keys)
+	var catalogs []string
+	if err == nil {
+		catalogs, err = loadCatalogApps(loaded, policy, keys)
+	}
+	names := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		names = append(names, layer.name)
+	}
+
+	marketplaceMu.Lock()
+	defer marketplaceMu.Unlock()
+	now := time.Now()
+	marketplaceStatus.Layers = names
+	marketplaceStatus.CheckedAt = now
+	if err != nil {
+		marketplaceStatus.Error = err.Error()
+		return err
+	}
+
+	keepPreviousApps(loaded)
+	replaceMarketplaceApps(loaded.apps)
+	appVerifications = loaded.verifications
+	apps := make([]string, 0, len(loaded.apps))
+	for name := range loaded.apps {
+		apps = append(apps, name)
+	}
+	sort.Strings(apps)
+	marketplaceStatus.Apps = apps
+	marketplaceStatus.Catalogs = catalogs
+	marketplaceStatus.SignaturePolicy = policy
+	marketplaceStatus.Warnings = append(warnings, loaded.warnings...)
+	marketplaceStatus.Broken = loaded.broken
+	marketplaceStatus.LoadedAt = now
+	marketplaceStatus.Error = ""
+	return nil
+}
+
+// loadMarketplaceLayers loads every layer; an app from an earlier layer, even a broken one, hides
+// one with the same name in later layers. A layer whose apps.yaml is unusable is skipped and
+// listed as broken.
+func loadMarketplaceLayers(layers []marketplaceLayer, policy string, keys map[string]TrustedKey) (*loadedMarketplace, error) {
+	merged := &loadedMarketplace{apps: map[string]App{}, verifications: map[string]Verification{}}
+	for _, layer := range layers {
+		check := &signatureChecker{policy: policy, keys: keys, source: layer.source, bundled: true}
+		loaded, err := loadMarketplace(layer.fsys, layer.name, check)
+		if err != nil {
+			merged.broken = append(merged.broken, BrokenApp{
+				File:   path.Join(layer.name, "apps.yaml"),
+				Source: layer.source,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		merged.merge(loaded)
+	}
+	return merged, nil
+}
+
+// keepPreviousApps keeps serving the registered version of apps that broke since the last load,
+// so a bad edit doesn't take an app away or swap in a lower layer's copy. The caller holds
+// marketplaceMu.
+func keepPreviousApps(loaded *loadedMarketplace) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for i, b := range loaded.broken {
+		if b.Name == "" {
+			continue
+		}
+		if _, ok := loaded.apps[b.Name]; ok {
+			continue
+		}
+		if _, ok := goApps[b.Name]; ok {
+			continue
+		}
+		prev, ok := registry[b.Name]
+		if !ok {
+			continue
+		}
+		loaded.apps[b.Name] = prev
+		if v, ok := appVerifications[b.Name]; ok {
+			loaded.verifications[b.Name] = v
+		}
+		loaded.broken[i].KeptPrevious = true
+	}
+}
+
+// WatchMarketplace polls the local marketplace directories, the catalog cache and the user config,
+// and reloads the registry whenever a file changes, until ctx is done. Reload results are reported through logf.
+func WatchMarketplace(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	last, _ := marketplaceFingerprint(watchedPaths()...)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := marketplaceFingerprint(watchedPaths()...)
+		if err != nil || current == last {
+			continue
+		}
+		last = current
+
+		if err := reloadMarketplace(); err != nil {
+			logf("⚠️  Marketplace reload failed, keeping the previous version: %v\n", err)
+			continue
+		}
+		status := GetMarketplaceStatus()
+		logf("🔄 Marketplace reloaded: %s\n", strings.Join(status.Apps, ", "))
+		for _, b := range status.Broken {
+			logf("⚠️  %s failed to load: %s\n", b.File, b.Error)
+		}
+	}
+}
+
+// watchedPaths returns the local marketplace directories and, when they can be located, the
+// catalog cache and user config. The built-in marketplace never changes.
+func watchedPaths() []string {
+	paths := marketplace.Dirs()
+	if catalogs, err := CatalogsDir(); err == nil {
+		paths = append(paths, catalogs)
+	}
+	if config, err := UserConfigPath(); err == nil {
+		paths = append(paths, config)
+	}
+	return paths
+}
+
+// marketplaceFingerprint hashes the path, size and modification time of every file under roots.
+// Roots that don't exist are skipped.
+func marketplaceFingerprint(roots ...string) (string, error) {
+	h := sha256.New()
+	for _, root := range roots {
+		if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(h, "%s\x00missing\n", root)
+			continue
+		}
+		if err := fingerprintTree(h, root); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fingerprintTree(h io.Writer, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zdunecki/selfhosted/marketplace"
+)
+
+// writeOverride writes an override marketplace with the given app files into dir.
+func writeOverride(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	var list []string
+	for name, data := range files {
+		list = append(list, " - "+name)
+		if err := os.WriteFile(filepath.Join(dir, "apps", name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "apps.yaml"), []byte("apps:\n"+strings.Join(list, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadKeepsLastGoodApp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "apps"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(marketplace.PathEnv, dir)
+
+	builtin, err := os.ReadFile("../../marketplace/apps/umami.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	umami := strings.Replace(string(builtin), "description: Umami", "description: Layered Umami", 1)
+	custom := strings.Replace(string(builtin), "app: umami", "app: custom-umami", 1)
+	writeOverride(t, dir, map[string]string{"umami.yaml": umami, "custom.yaml": custom})
+	if err := reloadMarketplace(); err != nil {
+		t.Fatal(err)
+	}
+	if app, err := Get("umami"); err != nil || !strings.HasPrefix(app.Description(), "Layered") {
+		t.Fatalf("Get(umami) = %v, %v; want the layered app", app, err)
+	}
+
+	broken := "app: %s\nnot_a_field: true\n"
+	writeOverride(t, dir, map[string]string{
+		"umami.yaml":  strings.ReplaceAll(broken, "%s", "umami"),
+		"custom.yaml": strings.ReplaceAll(broken, "%s", "custom-umami"),
+	})
+	if err := reloadMarketplace(); err != nil {
+		t.Fatal(err)
+	}
+	if app, err := Get("umami"); err != nil || !strings.HasPrefix(app.Description(), "Layered") {
+		t.Errorf("Get(umami) = %v, %v; want the last good layered app, not the built-in one", app, err)
+	}
+	if _, err := Get("custom-umami"); err != nil {
+		t.Errorf("Get(custom-umami): %v; want the last good app", err)
+	}
+	kept := map[string]bool{}
+	for _, b := range BrokenApps() {
+		kept[b.Name] = b.KeptPrevious
+	}
+	if !kept["umami"] || !kept["custom-umami"] {
+		t.Errorf("BrokenApps() kept = %v, want umami and custom-umami listed as kept", kept)
+	}
+
+	// Removing the override brings back the built-in copy and drops the app only it had.
+	t.Setenv(marketplace.PathEnv, "")
+	if err := reloadMarketplace(); err != nil {
+		t.Fatal(err)
+	}
+	if app, err := Get("umami"); err != nil || strings.HasPrefix(app.Description(), "Layered") {
+		t.Errorf("Get(umami) = %v, %v; want the built-in app", app, err)
+	}
+	if _, err := Get("custom-umami"); err == nil {
+		t.Error("Get(custom-umami) succeeded after its override was removed")
+	}
+}
@@ -0,0 +1,172 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metadata describes an app in app lists and catalogs.
+type Metadata struct {
+	DisplayName string   `json:"display_name"`
+	Category    string   `json:"category,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Homepage    string   `json:"homepage,omitempty"`
+	Repository  string   `json:"repository,omitempty"`
+	License     string   `json:"license,omitempty"`
+	// Icon is an https URL, or a path relative to the marketplace or catalog the app came from
+	// (read it with AppIcon).
+	Icon string `json:"icon,omitempty"`
+	// Version is the upstream version the app installs; it defaults to the app's default version.
+	Version string `json:"version,omitempty"`
+}
+
+// MetadataProvider is implemented by apps that describe themselves beyond Name and Description.
+type MetadataProvider interface {
+	Metadata() Metadata
+}
+
+// IconReader is implemented by apps that ship an icon file.
+type IconReader interface {
+	ReadIcon() ([]byte, error)
+}
+
+// MetadataFor returns the app's metadata; DisplayName falls back to the app name.
+func MetadataFor(app App) Metadata {
+	var m Metadata
+	if mp, ok := app.(MetadataProvider); ok {
+		m = mp.Metadata()
+	}
+	if strings.TrimSpace(m.DisplayName) == "" {
+		m.DisplayName = app.Name()
+	}
+	if m.Version == "" {
+		m.Version = DefaultVersion(app)
+	}
+	return m
+}
+
+// IconURL reports whether the app's icon is a URL rather than a file shipped with the app.
+func IconURL(m Metadata) bool {
+	return strings.Contains(m.Icon, "://")
+}
+
+// AppIcon returns the icon file shipped with the app and its file name.
+func AppIcon(app App) ([]byte, string, error) {
+	m := MetadataFor(app)
+	if m.Icon == "" || IconURL(m) {
+		return nil, "", fmt.Errorf("app %s has no icon file", app.Name())
+	}
+	r, ok := app.(IconReader)
+	if !ok {
+		return nil, "", fmt.Errorf("app %s has no icon file", app.Name())
+	}
+	data, err := r.ReadIcon()
+	if err != nil {
+		return nil, "", err
+	}
+	return data, m.Icon, nil
+}
+
+// App sort orders for AppQuery.
+const (
+	SortByName        = "name"
+	SortByDisplayName = "display_name"
+	SortByCategory    = "category"
+)
+
+// AppQuery selects and orders apps for app lists.
+type AppQuery struct {
+	// Text keeps apps whose name, display name, description, category or tags contain every word,
+	// case-insensitively.
+	Text string
+	// Category keeps apps in this category.
+	Category string
+	// Sort is SortByName (the default), SortByDisplayName or SortByCategory (then by name).
+	Sort string
+}
+
+// FindApps returns the registered apps matching q, in q's order.
+func FindApps(q AppQuery) ([]App, error) {
+	less, err := appOrder(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(q.Text))
+	category := strings.ToLower(strings.TrimSpace(q.Category))
+
+	var out []App
+	for _, app := range All() {
+		m := MetadataFor(app)
+		if category != "" && strings.ToLower(m.Category) != category {
+			continue
+		}
+		if !matchesWords(app, m, words) {
+			continue
+		}
+		out = append(out, app)
+	}
+	sort.Slice(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out, nil
+}
+
+// Categories returns the categories of the registered apps, sorted.
+func Categories() []string {
+	seen := map[string]bool{}
+	for _, app := range All() {
+		if c := MetadataFor(app).Category; c != "" {
+			seen[c] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for c := range seen {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func matchesWords(app App, m Metadata, words []string) bool {
+	if len(words) == 0 {
+		return true
+	}
+	haystack := strings.ToLower(strings.Join(append([]string{
+		app.Name(), m.DisplayName, app.Description(), m.Category,
+	}, m.Tags...), "\n"))
+	for _, w := range words {
+		if !strings.Contains(haystack, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func appOrder(by string) (func(a, b App) bool, error) {
+	byName := func(a, b App) bool { return a.Name() < b.Name() }
+	switch by {
+	case "", SortByName:
+		return byName, nil
+	case SortByDisplayName:
+		return func(a, b App) bool {
+			da, db := strings.ToLower(MetadataFor(a).DisplayName), strings.ToLower(MetadataFor(b).DisplayName)
+			if da != db {
+				return da < db
+			}
+			return byName(a, b)
+		}, nil
+	case SortByCategory:
+		return func(a, b App) bool {
+			ca, cb := MetadataFor(a).Category, MetadataFor(b).Category
+			if ca != cb {
+				// Uncategorized apps go last.
+				if ca == "" || cb == "" {
+					return cb == ""
+				}
+				return ca < cb
+			}
+			return byName(a, b)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown sort %q (want %s, %s or %s)", by, SortByName, SortByDisplayName, SortByCategory)
+	}
+}
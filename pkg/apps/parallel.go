@@ -0,0 +1,124 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// runParallelStep runs a `parallel:` group's children concurrently, each over its own SSH session on the
+// shared connection. Output lines are prefixed with the child's name. The first failure stops the other
+// children and is returned; registered values become visible once the whole group succeeds.
+func runParallelStep(runner *utils.SSHRunner, group dsl.ParallelSpec, vars map[string]string, bools map[string]bool, logger func(string, ...interface{})) error {
+	var children []dsl.Step
+	for _, child := range group.Steps {
+		if strings.TrimSpace(child.If) != "" && !dsl.EvaluateCondition(child.If, bools) {
+			continue
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	limit := group.MaxConcurrency
+	if limit <= 0 || limit > len(children) {
+		limit = len(children)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, limit)
+		values   = make([]string, len(children))
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i, child := range children {
+		label := child.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		// Templates are rendered up front: children can't see each other's registered values.
+		cmd := dsl.BuildRunCommand(dsl.RenderTemplate(child.Run, vars))
+		logLine := dsl.RenderTemplate(child.Log, vars)
+
+		wg.Add(1)
+		go func(i int, child dsl.Step, label string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			childRunner := runner
+			logf := func(format string, a ...interface{}) {
+				fmt.Printf("[%s] "+format, append([]interface{}{label}, a...)...)
+			}
+			if logger != nil {
+				logf = func(format string, a ...interface{}) {
+					logger("[%s] "+format, append([]interface{}{label}, a...)...)
+				}
+				childRunner = runner.WithLogger(logf)
+			}
+
+			logf("⏳ %s\n", child.Title())
+			if strings.TrimSpace(logLine) != "" {
+				logf("%s\n", logLine)
+			}
+			if child.Sleep != "" {
+				dur, err := dsl.ParseDuration(child.Sleep)
+				if err != nil {
+					fail(fmt.Errorf("%s: %w", label, err))
+					return
+				}
+				select {
+				case <-time.After(dur):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if strings.TrimSpace(child.Run) == "" {
+				return
+			}
+
+			value, err := runCommandStep(ctx, childRunner, child, cmd)
+			if err != nil {
+				if ctx.Err() != nil {
+					// Stopped because a sibling failed.
+					logf("⏹️  stopped\n")
+					return
+				}
+				fail(fmt.Errorf("%s: %w", label, err))
+				return
+			}
+			values[i] = value
+			logf("✅ done\n")
+		}(i, child, label)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("parallel step failed: %w", firstErr)
+	}
+	for i, child := range children {
+		registerStepValue(child, values[i], vars, bools)
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package apps
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/zdunecki/selfhosted/marketplace"
 	"github.com/zdunecki/selfhosted/pkg/dsl"
 	"gopkg.in/yaml.v3"
 )
@@ -16,100 +21,252 @@ import (
 // - adding <app>.yaml in marketplace/apps/
 // - listing it in marketplace/apps.yaml
 //
-// The marketplace directory is resolved relative to the executable or working directory.
+// The marketplace is embedded in the binary; local directories layer on top of it (see the
+// marketplace package), and remote catalogs come after all of them (see catalog.go).
 
 type appsList struct {
 	Apps []string `yaml:"apps"`
 }
 
-func init() {
-	if err := registerAppsFromYAML(); err != nil {
-		// Keep behavior consistent with other registries: fail fast on bad registry state.
-		// This is a startup/config error.
-		panic(err)
-	}
+var (
+	registryOnce  sync.Once
+	registryErr   error
+	librariesOnce sync.Once
+)
+
+// BrokenApp is an app file, or a marketplace or catalog index, that failed to load. It is left
+// out of the registry; the other apps still load.
+type BrokenApp struct {
+	// Name is the app name when it could be read, else the file name without its extension.
+	// It is empty for an index.
+	Name   string `json:"name,omitempty"`
+	File   string `json:"file"`
+	Source string `json:"source,omitempty"`
+	Error  string `json:"error"`
+	// KeptPrevious is set when a reload failed and the version loaded before is still registered.
+	KeptPrevious bool `json:"kept_previous,omitempty"`
 }
 
-func findMarketplaceDir() (string, error) {
-	// Try 1: Current working directory (for development)
-	if cwd, err := os.Getwd(); err == nil {
-		marketplaceDir := filepath.Join(cwd, "marketplace")
-		if _, err := os.Stat(filepath.Join(marketplaceDir, "apps.yaml")); err == nil {
-			return marketplaceDir, nil
+// LoadRegistry loads the marketplace and catalog apps into the registry. Only the first call
+// loads; later calls return its result, and Get and All call it on first use. Apps that fail to
+// load are skipped and listed in BrokenApps: the error reports them, but the registry still
+// holds every app that loaded.
+func LoadRegistry() error {
+	registryOnce.Do(func() {
+		registryErr = registerAppsFromYAML()
+	})
+	return registryErr
+}
+
+// BrokenApps returns the apps that failed to load in the last (re)load.
+func BrokenApps() []BrokenApp {
+	return GetMarketplaceStatus().Broken
+}
+
+// marketplaceLayer is a local marketplace directory or the built-in copy.
+type marketplaceLayer struct {
+	// name is the directory, or "built-in".
+	name   string
+	fsys   fs.FS
+	source string
+}
+
+// marketplaceLayers returns the marketplace layers in order of precedence. Override paths
+// without an apps.yaml are skipped with a warning.
+func marketplaceLayers() (layers []marketplaceLayer, warnings []string) {
+	for _, dir := range marketplace.OverridePaths() {
+		if _, err := os.Stat(filepath.Join(dir, "apps.yaml")); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %s has no apps.yaml; skipped", marketplace.PathEnv, dir))
+			continue
 		}
+		layers = append(layers, marketplaceLayer{name: dir, fsys: os.DirFS(dir), source: "path:" + dir})
+	}
+	if dir, ok := marketplace.LocalDir(); ok {
+		layers = append(layers, marketplaceLayer{name: dir, fsys: os.DirFS(dir), source: "marketplace"})
 	}
+	layers = append(layers, marketplaceLayer{name: "built-in", fsys: marketplace.FS, source: "marketplace"})
+	return layers, warnings
+}
+
+func registerAppsFromYAML() error {
+	if err := reloadMarketplace(); err != nil {
+		return err
+	}
+	broken := BrokenApps()
+	if len(broken) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(broken))
+	for _, b := range broken {
+		lines = append(lines, fmt.Sprintf("  %s: %s", b.File, b.Error))
+	}
+	return fmt.Errorf("apps registry: %d file(s) failed to load:\n%s", len(broken), strings.Join(lines, "\n"))
+}
+
+// setupLibraries points dsl.Libraries at lib/ of each marketplace layer, so `uses:` steps resolve
+// the same way for the registry, `selfhost app lint` and catalog validation.
+func setupLibraries() {
+	librariesOnce.Do(func() {
+		layers, _ := marketplaceLayers()
+		var libs layeredFS
+		for _, layer := range layers {
+			if lib, err := fs.Sub(layer.fsys, "lib"); err == nil {
+				libs = append(libs, lib)
+			}
+		}
+		dsl.Libraries = libs
+	})
+}
+
+// layeredFS serves each file from the first layer that has it.
+type layeredFS []fs.FS
 
-	// Try 2: Relative to executable (for production binaries)
-	if exe, err := os.Executable(); err == nil {
-		exeDir := filepath.Dir(exe)
-		// Try same directory as executable
-		marketplaceDir := filepath.Join(exeDir, "marketplace")
-		if _, err := os.Stat(filepath.Join(marketplaceDir, "apps.yaml")); err == nil {
-			return marketplaceDir, nil
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		f, err := fsys.Open(name)
+		if err == nil {
+			return f, nil
 		}
-		// Try one level up (common for Go builds)
-		marketplaceDir = filepath.Join(exeDir, "..", "marketplace")
-		if _, err := os.Stat(filepath.Join(marketplaceDir, "apps.yaml")); err == nil {
-			return marketplaceDir, nil
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
 		}
 	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
 
-	return "", fmt.Errorf("marketplace directory not found (looked in cwd/marketplace and exe/marketplace)")
+// loadedMarketplace is the result of loading a marketplace or catalog directory.
+type loadedMarketplace struct {
+	apps          map[string]App
+	verifications map[string]Verification
+	// warnings are apps refused or flagged by signature verification.
+	warnings []string
+	// broken are apps that failed to load.
+	broken []BrokenApp
 }
 
-func registerAppsFromYAML() error {
-	marketplaceDir, err := findMarketplaceDir()
-	if err != nil {
-		return fmt.Errorf("apps registry: %w", err)
+// merge adds the apps of a lower-precedence marketplace or catalog. Apps already loaded win, and
+// a name that is broken in a higher layer stays broken rather than falling back to a lower copy.
+func (m *loadedMarketplace) merge(lower *loadedMarketplace) {
+	broken := make(map[string]bool, len(m.broken))
+	for _, b := range m.broken {
+		if b.Name != "" {
+			broken[b.Name] = true
+		}
 	}
+	m.warnings = append(m.warnings, lower.warnings...)
+	m.broken = append(m.broken, lower.broken...)
+	for name, app := range lower.apps {
+		if _, exists := m.apps[name]; exists || broken[name] {
+			continue
+		}
+		m.apps[name] = app
+		m.verifications[name] = lower.verifications[name]
+	}
+}
 
-	appsYAMLPath := filepath.Join(marketplaceDir, "apps.yaml")
-	data, err := os.ReadFile(appsYAMLPath)
+// loadMarketplace reads apps.yaml and validates every app it lists. Nothing is registered:
+// the caller swaps the result in, so a bad file never leaves the registry half-updated.
+// Apps that fail to validate are left out and listed in broken; an error means apps.yaml itself
+// is unusable. With a checker, app signatures are verified and apps the policy refuses are left out.
+// name labels fsys in errors.
+func loadMarketplace(fsys fs.FS, name string, check *signatureChecker) (*loadedMarketplace, error) {
+	data, err := fs.ReadFile(fsys, "apps.yaml")
 	if err != nil {
-		return fmt.Errorf("apps registry: read %s: %w", appsYAMLPath, err)
+		return nil, fmt.Errorf("read apps.yaml: %w", err)
 	}
 
 	var list appsList
 	dec := yaml.NewDecoder(strings.NewReader(string(data)))
 	dec.KnownFields(true)
 	if err := dec.Decode(&list); err != nil {
-		return fmt.Errorf("apps registry: parse apps.yaml: %w", err)
+		return nil, fmt.Errorf("parse apps.yaml: %w", err)
 	}
 
 	if len(list.Apps) == 0 {
-		return fmt.Errorf("apps registry: apps.yaml has no entries")
+		return nil, fmt.Errorf("apps.yaml has no entries")
 	}
 
-	appsDir := filepath.Join(marketplaceDir, "apps")
+	source := ""
+	if check != nil {
+		source = check.source
+	}
+	loaded := make(map[string]App, len(list.Apps))
+	result := &loadedMarketplace{apps: loaded, verifications: map[string]Verification{}}
 	for _, filename := range list.Apps {
 		filename = strings.TrimSpace(filename)
 		if filename == "" {
 			continue
 		}
 
-		appPath := filepath.Join(appsDir, filename)
-		appData, err := os.ReadFile(appPath)
+		appFile := "apps/" + filename
+		appPath := path.Join(name, appFile)
+		fail := func(appData []byte, err error) {
+			result.broken = append(result.broken, BrokenApp{
+				Name:   brokenAppName(filename, appData),
+				File:   appPath,
+				Source: source,
+				Error:  err.Error(),
+			})
+		}
+		appData, err := fs.ReadFile(fsys, appFile)
 		if err != nil {
-			return fmt.Errorf("apps registry: read %s: %w", appPath, err)
+			fail(nil, err)
+			continue
 		}
 
-		spec, err := dsl.LoadSpec(appData)
+		var verification Verification
+		if check != nil {
+			var ok bool
+			verification, ok = check.app(fsys, appFile, appData)
+			if !ok || verification.Status == SigInvalid {
+				warning := fmt.Sprintf("%s: %s signature is %s", check.source, filename, verification.Status)
+				if verification.Message != "" {
+					warning += " (" + verification.Message + ")"
+				}
+				if !ok {
+					warning += "; not loaded"
+				}
+				result.warnings = append(result.warnings, warning)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		spec, err := LintSpec(filename, appData)
 		if err != nil {
-			return fmt.Errorf("apps registry: parse %s: %w", filename, err)
+			fail(appData, fmt.Errorf("invalid app spec:\n%w", err))
+			continue
 		}
 
 		app := NewDSLApp(spec)
+		app.files = fsys
 		if strings.TrimSpace(app.Name()) == "" || app.Name() == "unknown" {
-			return fmt.Errorf("apps registry: %s has no 'app' name", filename)
+			fail(appData, fmt.Errorf("no 'app' name"))
+			continue
 		}
-
-		// If a custom Go implementation registered the same name earlier, keep it.
-		// If it registers later, it will overwrite this entry via Register().
-		if _, exists := Registry[app.Name()]; exists {
+		if _, exists := loaded[app.Name()]; exists {
+			fail(appData, fmt.Errorf("duplicate app name %q", app.Name()))
 			continue
 		}
-		Register(app)
+		// Apps registered from Go with the same name take precedence (see replaceMarketplaceApps).
+		loaded[app.Name()] = app
+		if check != nil {
+			result.verifications[app.Name()] = verification
+		}
 	}
 
-	return nil
+	return result, nil
+}
+
+// brokenAppName returns the app name declared in data, or the file name without its extension
+// when data doesn't parse.
+func brokenAppName(filename string, data []byte) string {
+	var head struct {
+		App string `yaml:"app"`
+	}
+	if yaml.Unmarshal(data, &head) == nil && strings.TrimSpace(head.App) != "" {
+		return strings.TrimSpace(head.App)
+	}
+	return strings.TrimSuffix(filename, path.Ext(filename))
 }
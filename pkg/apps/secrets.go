@@ -0,0 +1,16 @@
+package apps
+
+// SecretSpec describes a secret the app needs generated locally before installation.
+// Values are generated once per deployment, stored encrypted with the deployment record
+// and passed back via InstallConfig.Secrets on every run.
+type SecretSpec struct {
+	Name     string
+	Length   int
+	Charset  string
+	Encoding string
+}
+
+// SecretsProvider is an optional interface apps can implement to declare generated secrets.
+type SecretsProvider interface {
+	SecretSpecs() []SecretSpec
+}
@@ -0,0 +1,320 @@
+package apps
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// App files and catalog indexes can carry detached ed25519 signatures: apps/<file>.sig next to
+// the app, apps.yaml.sig next to the index. Keys and signatures are one-line text files:
+//
+//	<name>.pub  ed25519 <base64 public key>
+//	<name>.key  ed25519-private <base64 private key>
+//	<file>.sig  ed25519 <key id> <base64 signature of the file's bytes>
+//
+// The key id is the first 8 bytes (hex) of the SHA-256 of the public key. Trusted public keys
+// live in ~/.selfhosted/trust/<name>.pub. The signature policy decides what happens to catalog
+// apps that aren't signed by a trusted key; local marketplace layers (the built-in copy and
+// directories the user chose) are only refused when a signature they carry doesn't match.
+
+// SigExt is the extension of detached signature files.
+const SigExt = ".sig"
+
+// Signature policies.
+const (
+	// PolicyStrict refuses catalogs whose index, and apps whose file, isn't signed by a trusted key.
+	PolicyStrict = "strict"
+	// PolicyWarn loads everything and flags what failed verification. It is the default.
+	PolicyWarn = "warn"
+	// PolicyOff skips verification.
+	PolicyOff = "off"
+)
+
+// Verification statuses.
+const (
+	SigVerified  = "verified"  // signed by a trusted key
+	SigUnsigned  = "unsigned"  // no signature
+	SigUntrusted = "untrusted" // signed by a key that isn't in the trust store
+	SigInvalid   = "invalid"   // the signature doesn't match the file
+	SigBundled   = "bundled"   // unsigned, from a local marketplace layer
+	SigUnchecked = "unchecked" // verification is off
+)
+
+// SignaturePolicyEnv overrides the signature_policy in ~/.selfhosted/config.
+const SignaturePolicyEnv = "SELFHOSTED_SIGNATURE_POLICY"
+
+var (
+	errUntrustedKey = errors.New("signed by an untrusted key")
+	errBadSignature = errors.New("signature does not match")
+)
+
+// Verification is the signature check result for an app.
+type Verification struct {
+	Status string `json:"status"`
+	// Source is "marketplace" (built-in or a source checkout), "path:<dir>" (a
+	// $SELFHOSTED_MARKETPLACE_PATH directory) or "catalog:<name>".
+	Source string `json:"source"`
+	// Key is the name of the trusted key that signed the app.
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Trusted reports whether the app was verified or is bundled with the binary.
+func (v Verification) Trusted() bool {
+	return v.Status == SigVerified || v.Status == SigBundled
+}
+
+// TrustedKey is a public key from the trust store.
+type TrustedKey struct {
+	Name string
+	ID   string
+	Key  ed25519.PublicKey
+}
+
+// SignaturePolicy returns the configured policy: $SELFHOSTED_SIGNATURE_POLICY, else
+// signature_policy in ~/.selfhosted/config, else PolicyWarn.
+func SignaturePolicy() (string, error) {
+	policy := strings.TrimSpace(os.Getenv(SignaturePolicyEnv))
+	if policy == "" {
+		cfg, err := LoadUserConfig()
+		if err != nil {
+			return PolicyWarn, err
+		}
+		policy = cfg.SignaturePolicy
+	}
+	switch policy {
+	case "":
+		return PolicyWarn, nil
+	case PolicyStrict, PolicyWarn, PolicyOff:
+		return policy, nil
+	default:
+		return PolicyWarn, fmt.Errorf("unknown signature policy %q (want %s, %s or %s)", policy, PolicyStrict, PolicyWarn, PolicyOff)
+	}
+}
+
+// KeyID returns the id of a public key.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateSigningKey returns a new key pair as .key and .pub file contents.
+func GenerateSigningKey() (private, public []byte, id string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	private = []byte("ed25519-private " + base64.StdEncoding.EncodeToString(priv) + "\n")
+	public = []byte("ed25519 " + base64.StdEncoding.EncodeToString(pub) + "\n")
+	return private, public, KeyID(pub), nil
+}
+
+// ParsePrivateKey reads a .key file.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	b, err := parseKeyLine(data, "ed25519-private", ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+	return ed25519.PrivateKey(b), nil
+}
+
+// ParsePublicKey reads a .pub file.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	b, err := parseKeyLine(data, "ed25519", ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+func parseKeyLine(data []byte, kind string, size int) ([]byte, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 || fields[0] != kind {
+		return nil, fmt.Errorf("expected %q followed by the base64 key", kind)
+	}
+	b, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(b))
+	}
+	return b, nil
+}
+
+// Sign returns the detached signature file content for data.
+func Sign(priv ed25519.PrivateKey, data []byte) []byte {
+	pub := priv.Public().(ed25519.PublicKey)
+	sig := ed25519.Sign(priv, data)
+	return []byte(fmt.Sprintf("ed25519 %s %s\n", KeyID(pub), base64.StdEncoding.EncodeToString(sig)))
+}
+
+// TrustDir returns the directory of trusted public keys.
+func TrustDir() (string, error) {
+	dir, err := selfhostedDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trust"), nil
+}
+
+// LoadTrustedKeys reads every <name>.pub in the trust store, keyed by key id.
+func LoadTrustedKeys() (map[string]TrustedKey, error) {
+	dir, err := TrustDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]TrustedKey{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]TrustedKey)
+	var bad []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pub" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", e.Name(), err))
+			continue
+		}
+		pub, err := ParsePublicKey(data)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", e.Name(), err))
+			continue
+		}
+		id := KeyID(pub)
+		keys[id] = TrustedKey{Name: strings.TrimSuffix(e.Name(), ".pub"), ID: id, Key: pub}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return keys, fmt.Errorf("trust store: %s", strings.Join(bad, "; "))
+	}
+	return keys, nil
+}
+
+// verifySignature checks a signature file against data and returns the trusted key that made it.
+func verifySignature(keys map[string]TrustedKey, data, sigFile []byte) (TrustedKey, error) {
+	fields := strings.Fields(string(sigFile))
+	if len(fields) != 3 || fields[0] != "ed25519" {
+		return TrustedKey{}, fmt.Errorf("malformed signature file")
+	}
+	key, ok := keys[fields[1]]
+	if !ok {
+		return TrustedKey{}, fmt.Errorf("%w (key id %s)", errUntrustedKey, fields[1])
+	}
+	sig, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil || !ed25519.Verify(key.Key, data, sig) {
+		return key, errBadSignature
+	}
+	return key, nil
+}
+
+// verifyFile checks name in fsys against name.sig.
+func verifyFile(keys map[string]TrustedKey, fsys fs.FS, name string, data []byte, source string) Verification {
+	v := Verification{Source: source}
+	sigFile, err := fs.ReadFile(fsys, name+SigExt)
+	if errors.Is(err, fs.ErrNotExist) {
+		v.Status = SigUnsigned
+		return v
+	}
+	if err != nil {
+		v.Status, v.Message = SigInvalid, err.Error()
+		return v
+	}
+	key, err := verifySignature(keys, data, sigFile)
+	switch {
+	case err == nil:
+		v.Status, v.Key = SigVerified, key.Name
+	case errors.Is(err, errUntrustedKey):
+		v.Status, v.Message = SigUntrusted, err.Error()
+	default:
+		v.Status, v.Message = SigInvalid, err.Error()
+	}
+	return v
+}
+
+// signatureChecker verifies the files of one marketplace layer or catalog under a policy.
+type signatureChecker struct {
+	policy  string
+	keys    map[string]TrustedKey
+	source  string
+	bundled bool
+}
+
+// app verifies an app file and reports whether it may be loaded.
+func (c *signatureChecker) app(fsys fs.FS, name string, data []byte) (Verification, bool) {
+	if c.policy == PolicyOff {
+		return Verification{Status: SigUnchecked, Source: c.source}, true
+	}
+	v := verifyFile(c.keys, fsys, name, data, c.source)
+	if c.bundled && v.Status == SigUnsigned {
+		v.Status = SigBundled
+	}
+	if v.Status == SigInvalid {
+		// A signature that doesn't match means the file was changed after signing.
+		return v, c.policy != PolicyStrict
+	}
+	return v, c.policy != PolicyStrict || v.Trusted()
+}
+
+// index verifies a catalog's apps.yaml. An error means the policy refuses the catalog; warning
+// is set when the index failed verification but the policy lets it load.
+func (c *signatureChecker) index(fsys fs.FS) (warning string, err error) {
+	if c.policy == PolicyOff {
+		return "", nil
+	}
+	data, err := fs.ReadFile(fsys, "apps.yaml")
+	if err != nil {
+		return "", err
+	}
+	v := verifyFile(c.keys, fsys, "apps.yaml", data, c.source)
+	if v.Status == SigVerified {
+		return "", nil
+	}
+	msg := fmt.Sprintf("%s: apps.yaml is %s", c.source, v.Status)
+	if v.Message != "" {
+		msg += " (" + v.Message + ")"
+	}
+	if c.policy == PolicyStrict {
+		return "", errors.New(msg)
+	}
+	return msg, nil
+}
+
+// CatalogFiles returns the files of a marketplace or catalog directory that are signed: apps.yaml
+// and every app it lists.
+func CatalogFiles(dir string) ([]string, error) {
+	index := filepath.Join(dir, "apps.yaml")
+	data, err := os.ReadFile(index)
+	if err != nil {
+		return nil, err
+	}
+	var list appsList
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", index, err)
+	}
+	files := []string{index}
+	for _, name := range list.Apps {
+		if name = strings.TrimSpace(name); name != "" {
+			files = append(files, filepath.Join(dir, "apps", name))
+		}
+	}
+	return files, nil
+}
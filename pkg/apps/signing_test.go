@@ -0,0 +1,134 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSignatureChecker(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	private, public, id, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ParsePrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParsePublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(pub) != id {
+		t.Fatalf("KeyID = %s, want %s", KeyID(pub), id)
+	}
+
+	dir, err := TrustDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "acme.pub"), public, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadTrustedKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPrivate, _, _, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParsePrivateKey(otherPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := []byte(catalogTestApp)
+	fsys := fstest.MapFS{
+		"apps/signed.yaml":        {Data: app},
+		"apps/signed.yaml.sig":    {Data: Sign(priv, app)},
+		"apps/unsigned.yaml":      {Data: app},
+		"apps/untrusted.yaml":     {Data: app},
+		"apps/untrusted.yaml.sig": {Data: Sign(other, app)},
+		"apps/tampered.yaml":      {Data: append([]byte("# edited\n"), app...)},
+		"apps/tampered.yaml.sig":  {Data: Sign(priv, app)},
+	}
+
+	tests := []struct {
+		file       string
+		status     string
+		warnLoads  bool
+		strictLoad bool
+	}{
+		{"apps/signed.yaml", SigVerified, true, true},
+		{"apps/unsigned.yaml", SigUnsigned, true, false},
+		{"apps/untrusted.yaml", SigUntrusted, true, false},
+		{"apps/tampered.yaml", SigInvalid, true, false},
+	}
+	for _, tt := range tests {
+		data := fsys[tt.file].Data
+		warn := &signatureChecker{policy: PolicyWarn, keys: keys, source: "catalog:test"}
+		v, ok := warn.app(fsys, tt.file, data)
+		if v.Status != tt.status || ok != tt.warnLoads {
+			t.Errorf("%s (warn): status %s, load %v; want %s, %v", tt.file, v.Status, ok, tt.status, tt.warnLoads)
+		}
+		if tt.status == SigVerified && v.Key != "acme" {
+			t.Errorf("%s: key = %q, want acme", tt.file, v.Key)
+		}
+		strict := &signatureChecker{policy: PolicyStrict, keys: keys, source: "catalog:test"}
+		if _, ok := strict.app(fsys, tt.file, data); ok != tt.strictLoad {
+			t.Errorf("%s (strict): load %v, want %v", tt.file, ok, tt.strictLoad)
+		}
+		off := &signatureChecker{policy: PolicyOff, keys: keys}
+		if v, ok := off.app(fsys, tt.file, data); !ok || v.Status != SigUnchecked {
+			t.Errorf("%s (off): status %s, load %v", tt.file, v.Status, ok)
+		}
+	}
+
+	// Unsigned files from a local marketplace layer are bundled, and load under strict.
+	bundled := &signatureChecker{policy: PolicyStrict, keys: keys, bundled: true}
+	if v, ok := bundled.app(fsys, "apps/unsigned.yaml", app); !ok || v.Status != SigBundled {
+		t.Errorf("bundled unsigned: status %s, load %v", v.Status, ok)
+	}
+
+	index := []byte("apps:\n - signed.yaml\n")
+	strict := &signatureChecker{policy: PolicyStrict, keys: keys, source: "catalog:test"}
+	if _, err := strict.index(fstest.MapFS{"apps.yaml": {Data: index}}); err == nil {
+		t.Error("strict policy accepted an unsigned index")
+	}
+	if _, err := strict.index(fstest.MapFS{"apps.yaml": {Data: index}, "apps.yaml.sig": {Data: Sign(priv, index)}}); err != nil {
+		t.Errorf("strict policy refused a signed index: %v", err)
+	}
+	warn := &signatureChecker{policy: PolicyWarn, keys: keys, source: "catalog:test"}
+	if warning, err := warn.index(fstest.MapFS{"apps.yaml": {Data: index}}); err != nil || warning == "" {
+		t.Errorf("warn policy: warning %q, err %v; want a warning", warning, err)
+	}
+}
+
+func TestSignaturePolicy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(SignaturePolicyEnv, "")
+	if p, err := SignaturePolicy(); err != nil || p != PolicyWarn {
+		t.Errorf("default policy = %s, %v", p, err)
+	}
+	if err := SaveUserConfig(&UserConfig{SignaturePolicy: PolicyStrict}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := SignaturePolicy(); err != nil || p != PolicyStrict {
+		t.Errorf("configured policy = %s, %v", p, err)
+	}
+	t.Setenv(SignaturePolicyEnv, PolicyOff)
+	if p, err := SignaturePolicy(); err != nil || p != PolicyOff {
+		t.Errorf("env policy = %s, %v", p, err)
+	}
+	t.Setenv(SignaturePolicyEnv, "paranoid")
+	if p, err := SignaturePolicy(); err == nil || p != PolicyWarn {
+		t.Errorf("unknown policy = %s, %v; want warn and an error", p, err)
+	}
+}
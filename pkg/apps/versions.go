@@ -0,0 +1,83 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+)
+
+// VersionsProvider is an optional interface for apps that let deploys pick a version.
+type VersionsProvider interface {
+	Versions() []dsl.VersionSpec
+}
+
+// AppVersions returns the versions the app can be deployed at, or nil if it has none to pick.
+func AppVersions(app App) []dsl.VersionSpec {
+	if vp, ok := app.(VersionsProvider); ok {
+		return vp.Versions()
+	}
+	return nil
+}
+
+// VersionNames returns the app's versions, newest first.
+func VersionNames(app App) []string {
+	versions := AppVersions(app)
+	names := make([]string, 0, len(versions))
+	for _, v := range versions {
+		names = append(names, v.Version)
+	}
+	sort.Slice(names, func(i, j int) bool { return dsl.CompareVersions(names[i], names[j]) > 0 })
+	return names
+}
+
+// DefaultVersion returns the version deployed when none is requested, or "" without versions.
+func DefaultVersion(app App) string {
+	if v := dsl.DefaultVersion(AppVersions(app)); v != nil {
+		return v.Version
+	}
+	return ""
+}
+
+// LatestVersion returns the app's highest version, or "" without versions.
+func LatestVersion(app App) string {
+	if v := dsl.LatestVersion(AppVersions(app)); v != nil {
+		return v.Version
+	}
+	return ""
+}
+
+// ResolveVersion returns the app version to deploy for requested: the default when empty, else
+// the exact version or the highest one requested is a prefix of ("2.13" → "2.13.2").
+// Apps without versions resolve to "" and reject a requested version.
+func ResolveVersion(app App, requested string) (string, error) {
+	versions := AppVersions(app)
+	if len(versions) == 0 && strings.TrimSpace(requested) != "" {
+		return "", fmt.Errorf("app %s has no versions to choose from", app.Name())
+	}
+	v, err := dsl.FindVersion(versions, requested)
+	if err != nil {
+		return "", fmt.Errorf("app %s: %w", app.Name(), err)
+	}
+	if v == nil {
+		return "", nil
+	}
+	return v.Version, nil
+}
+
+// SplitAppRef splits an app reference such as "umami@2.13" into the app name and version.
+func SplitAppRef(ref string) (name, version string) {
+	name, version, _ = strings.Cut(strings.TrimSpace(ref), "@")
+	return strings.TrimSpace(name), strings.TrimSpace(version)
+}
+
+// UpdateAvailable reports whether the app has a newer version than installed. Deployments
+// without a recorded version predate version pinning and are never reported.
+func UpdateAvailable(app App, installed string) (latest string, ok bool) {
+	latest = LatestVersion(app)
+	if installed == "" || latest == "" {
+		return latest, false
+	}
+	return latest, dsl.CompareVersions(latest, installed) > 0
+}
@@ -1,14 +1,56 @@
 package apps
 
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+)
+
+// Wizard question types.
+const (
+	QuestionBoolean     = "boolean"
+	QuestionText        = "text"
+	QuestionNumber      = "number"
+	QuestionPassword    = "password" // "secret" is accepted as an alias
+	QuestionSelect      = "select"
+	QuestionMultiSelect = "multiselect"
+	QuestionEmail       = "email"
+	QuestionURL         = "url"
+	QuestionDomain      = "domain"
+	// QuestionChoice is the original choice type: multi-select when more than one choice is on by default.
+	QuestionChoice = "choice"
+)
+
 // WizardQuestion is a UI-only question definition to help users configure interactive installers.
 // The wizard can render these questions before deployment, and (optionally) auto-answer a PTY step.
 type WizardQuestion struct {
-	ID       string         `json:"id"`
-	Name     string         `json:"name"`
-	Type     string         `json:"type"` // boolean | text | choice
-	Required bool           `json:"required"`
-	Default  interface{}    `json:"default,omitempty"`
-	Choices  []WizardChoice `json:"choices,omitempty"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Type        string         `json:"type"` // see the Question* constants
+	Required    bool           `json:"required"`
+	Default     interface{}    `json:"default,omitempty"`
+	Choices     []WizardChoice `json:"choices,omitempty"`
+	Help        string         `json:"help,omitempty"`
+	Placeholder string         `json:"placeholder,omitempty"`
+
+	// ShowIf is the raw show_if expression; ShowIfTerms is its parsed form, evaluated by the wizards.
+	ShowIf      string        `json:"show_if,omitempty"`
+	ShowIfTerms dsl.Condition `json:"show_if_terms,omitempty"`
+
+	// Validation rules. Pattern, lengths and AllowedValues apply to text-like answers, Min/Max to numbers.
+	Pattern       string   `json:"pattern,omitempty"`
+	Min           *float64 `json:"min,omitempty"`
+	Max           *float64 `json:"max,omitempty"`
+	MinLength     *int     `json:"min_length,omitempty"`
+	MaxLength     *int     `json:"max_length,omitempty"`
+	AllowedValues []string `json:"allowed_values,omitempty"`
 }
 
 type WizardChoice struct {
@@ -20,3 +62,382 @@ type WizardChoice struct {
 type WizardProvider interface {
 	WizardQuestions() []WizardQuestion
 }
+
+// NormalizeQuestionType lowercases a question type and resolves aliases.
+func NormalizeQuestionType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	switch t {
+	case "secret":
+		return QuestionPassword
+	case "multi-select", "multi_select":
+		return QuestionMultiSelect
+	case "single-select", "single_select":
+		return QuestionSelect
+	}
+	return t
+}
+
+// MultiSelect reports whether the answer is a list of choice names.
+func (q WizardQuestion) MultiSelect() bool {
+	switch NormalizeQuestionType(q.Type) {
+	case QuestionMultiSelect:
+		return true
+	case QuestionChoice:
+		on := 0
+		for _, c := range q.Choices {
+			if c.Default == true {
+				on++
+			}
+		}
+		return on > 1
+	}
+	return false
+}
+
+// Sensitive reports whether the answer must be kept out of logs.
+func (q WizardQuestion) Sensitive() bool {
+	return NormalizeQuestionType(q.Type) == QuestionPassword
+}
+
+// WizardValidationError lists invalid answers, keyed by question ID.
+type WizardValidationError struct {
+	Errors map[string]string `json:"errors"`
+}
+
+func (e *WizardValidationError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%s: %s", id, e.Errors[id]))
+	}
+	return "invalid wizard answers: " + strings.Join(parts, "; ")
+}
+
+// WizardEnv holds the deploy options visible to show_if expressions (opts.*) and computed defaults ({opts.*}).
+type WizardEnv struct {
+	Vars  map[string]string
+	Bools map[string]bool
+}
+
+// NewWizardEnv builds a WizardEnv from the install options known before deploying.
+func NewWizardEnv(config *InstallConfig) WizardEnv {
+	return WizardEnv{Vars: dsl.BuildVarsFromStruct(config), Bools: dsl.BuildBoolsFromStruct(config)}
+}
+
+// QuestionVisible reports whether q's show_if holds for the answers given so far.
+func QuestionVisible(q WizardQuestion, answers map[string]interface{}, env WizardEnv) bool {
+	if len(q.ShowIfTerms) == 0 {
+		return true
+	}
+	return q.ShowIfTerms.Eval(func(name string) interface{} {
+		if id, ok := strings.CutPrefix(name, "answers."); ok {
+			return answers[id]
+		}
+		return env.Bools[name]
+	})
+}
+
+// QuestionDefault returns q's default answer. String defaults are templates over {opts.*} and
+// {answers.ID}, e.g. "{opts.Email}" or "admin@{answers.domain}".
+func QuestionDefault(q WizardQuestion, answers map[string]interface{}, env WizardEnv) interface{} {
+	d := questionDefault(q)
+	text, ok := d.(string)
+	if !ok || !strings.Contains(text, "{") {
+		return d
+	}
+	vars := make(map[string]string, len(env.Vars)+len(answers))
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	for id, v := range answers {
+		vars["{answers."+id+"}"] = answerString(v)
+	}
+	return dsl.RenderTemplate(text, vars)
+}
+
+// ValidateWizardAnswers checks answers against the app's questions, in order, and returns them normalized:
+// hidden questions (show_if) get no answer, missing answers get the question's default, and string answers
+// (as given on the command line) are converted to the question's type (bool, number or list of choices).
+// Answers for unknown questions are kept. It returns a *WizardValidationError when any answer is invalid.
+func ValidateWizardAnswers(app App, answers map[string]interface{}, env WizardEnv) (map[string]interface{}, error) {
+	wp, ok := app.(WizardProvider)
+	if !ok {
+		return answers, nil
+	}
+	questions := wp.WizardQuestions()
+	if len(questions) == 0 {
+		return answers, nil
+	}
+
+	out := make(map[string]interface{}, len(answers)+len(questions))
+	for k, v := range answers {
+		out[k] = v
+	}
+	errs := map[string]string{}
+	for _, q := range questions {
+		if !QuestionVisible(q, out, env) {
+			out[q.ID] = nil
+			continue
+		}
+		v, ok := out[q.ID]
+		if !ok || v == nil {
+			v = QuestionDefault(q, out, env)
+		}
+		normalized, err := ValidateWizardAnswer(q, v)
+		if err != nil {
+			errs[q.ID] = err.Error()
+			continue
+		}
+		if normalized != nil {
+			out[q.ID] = normalized
+		}
+	}
+	if len(errs) > 0 {
+		return out, &WizardValidationError{Errors: errs}
+	}
+	return out, nil
+}
+
+// SensitiveAnswers returns the non-empty answers to password questions, for redaction.
+func SensitiveAnswers(app App, answers map[string]interface{}) []string {
+	wp, ok := app.(WizardProvider)
+	if !ok {
+		return nil
+	}
+	var values []string
+	for _, q := range wp.WizardQuestions() {
+		if !q.Sensitive() {
+			continue
+		}
+		if s, ok := answers[q.ID].(string); ok && s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func questionDefault(q WizardQuestion) interface{} {
+	if q.Default != nil {
+		return q.Default
+	}
+	switch NormalizeQuestionType(q.Type) {
+	case QuestionChoice, QuestionSelect, QuestionMultiSelect:
+		var on []interface{}
+		for _, c := range q.Choices {
+			if c.Default == true {
+				on = append(on, c.Name)
+			}
+		}
+		if q.MultiSelect() {
+			return on
+		}
+		if len(on) > 0 {
+			return on[0]
+		}
+	}
+	return nil
+}
+
+var domainPattern = regexp.MustCompile(`^(?i)([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// ValidateWizardAnswer checks a single answer and converts it to the question's type.
+func ValidateWizardAnswer(q WizardQuestion, v interface{}) (interface{}, error) {
+	qType := NormalizeQuestionType(q.Type)
+	empty := v == nil
+	if s, ok := v.(string); ok && strings.TrimSpace(s) == "" {
+		empty = true
+	}
+	if list, ok := v.([]interface{}); ok && len(list) == 0 {
+		empty = true
+	}
+	if empty {
+		if q.Required && qType != QuestionBoolean {
+			return nil, fmt.Errorf("is required")
+		}
+		return v, nil
+	}
+
+	switch qType {
+	case QuestionBoolean:
+		switch t := v.(type) {
+		case bool:
+			return t, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(t))
+			if err != nil {
+				switch strings.ToLower(strings.TrimSpace(t)) {
+				case "y", "yes", "on":
+					return true, nil
+				case "n", "no", "off":
+					return false, nil
+				}
+				return nil, fmt.Errorf("must be true or false")
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("must be true or false")
+
+	case QuestionNumber:
+		var n float64
+		switch t := v.(type) {
+		case float64:
+			n = t
+		case int:
+			n = float64(t)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number")
+			}
+			n = f
+		default:
+			return nil, fmt.Errorf("must be a number")
+		}
+		if q.Min != nil && n < *q.Min {
+			return nil, fmt.Errorf("must be at least %s", formatNumber(*q.Min))
+		}
+		if q.Max != nil && n > *q.Max {
+			return nil, fmt.Errorf("must be at most %s", formatNumber(*q.Max))
+		}
+		if err := checkAllowed(q, formatNumber(n)); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case QuestionChoice, QuestionSelect, QuestionMultiSelect:
+		names := map[string]bool{}
+		for _, c := range q.Choices {
+			names[c.Name] = true
+		}
+		var selected []string
+		switch t := v.(type) {
+		case string:
+			if q.MultiSelect() {
+				for _, part := range strings.Split(t, ",") {
+					if part = strings.TrimSpace(part); part != "" {
+						selected = append(selected, part)
+					}
+				}
+			} else {
+				selected = []string{strings.TrimSpace(t)}
+			}
+		case []interface{}:
+			for _, x := range t {
+				s, ok := x.(string)
+				if !ok {
+					return nil, fmt.Errorf("must be a list of choices")
+				}
+				selected = append(selected, s)
+			}
+		case []string:
+			selected = t
+		default:
+			return nil, fmt.Errorf("must be one of its choices")
+		}
+		for _, s := range selected {
+			if !names[s] {
+				return nil, fmt.Errorf("%q is not one of its choices", s)
+			}
+		}
+		if !q.MultiSelect() {
+			if len(selected) != 1 {
+				return nil, fmt.Errorf("must be a single choice")
+			}
+			return selected[0], nil
+		}
+		list := make([]interface{}, len(selected))
+		for i, s := range selected {
+			list[i] = s
+		}
+		return list, nil
+
+	case QuestionText, QuestionPassword, QuestionEmail, QuestionURL, QuestionDomain:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if err := checkText(q, s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	// Unknown types are rejected by the linter; pass their answers through unchanged.
+	return v, nil
+}
+
+func checkText(q WizardQuestion, s string) error {
+	n := utf8.RuneCountInString(s)
+	if q.MinLength != nil && n < *q.MinLength {
+		return fmt.Errorf("must be at least %d characters", *q.MinLength)
+	}
+	if q.MaxLength != nil && n > *q.MaxLength {
+		return fmt.Errorf("must be at most %d characters", *q.MaxLength)
+	}
+
+	switch NormalizeQuestionType(q.Type) {
+	case QuestionEmail:
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			return fmt.Errorf("must be an email address")
+		}
+	case QuestionURL:
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("must be an http(s) URL")
+		}
+	case QuestionDomain:
+		if len(s) > 253 || !domainPattern.MatchString(s) {
+			return fmt.Errorf("must be a domain name, e.g. app.example.com")
+		}
+	}
+
+	if q.Pattern != "" {
+		re, err := regexp.Compile(q.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", q.Pattern, err)
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("must match %s", q.Pattern)
+		}
+	}
+	return checkAllowed(q, s)
+}
+
+func checkAllowed(q WizardQuestion, s string) error {
+	if len(q.AllowedValues) == 0 {
+		return nil
+	}
+	for _, a := range q.AllowedValues {
+		if a == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of: %s", strings.Join(q.AllowedValues, ", "))
+}
+
+// answerString formats an answer for templates.
+func answerString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return formatNumber(t)
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, x := range t {
+			parts = append(parts, answerString(x))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
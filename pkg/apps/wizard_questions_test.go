@@ -0,0 +1,85 @@
+package apps
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/zdunecki/selfhosted/pkg/dsl"
+)
+
+func TestValidateWizardAnswer(t *testing.T) {
+	three, ten := 3, 10.0
+	tests := []struct {
+		name    string
+		q       WizardQuestion
+		in      interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"required missing", WizardQuestion{Type: QuestionText, Required: true}, "  ", nil, true},
+		{"optional empty", WizardQuestion{Type: QuestionText}, "", "", false},
+		{"boolean from flag", WizardQuestion{Type: QuestionBoolean}, "yes", true, false},
+		{"boolean invalid", WizardQuestion{Type: QuestionBoolean}, "maybe", nil, true},
+		{"number from flag", WizardQuestion{Type: QuestionNumber}, "8080", 8080.0, false},
+		{"number above max", WizardQuestion{Type: QuestionNumber, Max: &ten}, 11.0, nil, true},
+		{"select", WizardQuestion{Type: QuestionSelect, Choices: []WizardChoice{{Name: "a"}, {Name: "b"}}}, "b", "b", false},
+		{"select unknown", WizardQuestion{Type: QuestionSelect, Choices: []WizardChoice{{Name: "a"}}}, "z", nil, true},
+		{"multiselect from flag", WizardQuestion{Type: QuestionMultiSelect, Choices: []WizardChoice{{Name: "a"}, {Name: "b"}}}, "a, b", []interface{}{"a", "b"}, false},
+		{"secret alias", WizardQuestion{Type: "secret", MinLength: &three}, "ab", nil, true},
+		{"email", WizardQuestion{Type: QuestionEmail}, "Admin <admin@example.com>", nil, true},
+		{"url", WizardQuestion{Type: QuestionURL}, "ftp://example.com", nil, true},
+		{"domain", WizardQuestion{Type: QuestionDomain}, "app.example.com", "app.example.com", false},
+		{"pattern", WizardQuestion{Type: QuestionText, Pattern: `^[a-z]+$`}, "Abc", nil, true},
+		{"allowed values", WizardQuestion{Type: QuestionText, AllowedValues: []string{"eu", "us"}}, "eu", "eu", false},
+	}
+	for _, tt := range tests {
+		got, err := ValidateWizardAnswer(tt.q, tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// wizardApp is an app with wizard questions and nothing else.
+type wizardApp struct {
+	App
+	questions []WizardQuestion
+}
+
+func (a wizardApp) WizardQuestions() []WizardQuestion { return a.questions }
+
+func TestValidateWizardAnswers(t *testing.T) {
+	showIf, err := dsl.ParseCondition("answers.smtp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := wizardApp{questions: []WizardQuestion{
+		{ID: "domain", Type: QuestionDomain, Default: "{opts.Domain}"},
+		{ID: "admin_email", Type: QuestionEmail, Default: "admin@{answers.domain}"},
+		{ID: "smtp", Type: QuestionBoolean},
+		{ID: "smtp_password", Type: QuestionPassword, Required: true, ShowIf: "answers.smtp", ShowIfTerms: showIf},
+		{ID: "port", Type: QuestionNumber},
+	}}
+	env := WizardEnv{Vars: map[string]string{"{opts.Domain}": "app.example.com"}}
+
+	got, err := ValidateWizardAnswers(app, map[string]interface{}{"smtp": "false", "port": "abc", "extra": "kept"}, env)
+	var verr *WizardValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors["port"] == "" {
+		t.Fatalf("err = %v, want only port to be invalid", err)
+	}
+	if got["admin_email"] != "admin@app.example.com" || got["smtp"] != false || got["smtp_password"] != nil || got["extra"] != "kept" {
+		t.Errorf("answers = %v", got)
+	}
+
+	if _, err := ValidateWizardAnswers(app, map[string]interface{}{"smtp": true}, env); !errors.As(err, &verr) || verr.Errors["smtp_password"] == "" {
+		t.Errorf("err = %v, want the shown required question reported", err)
+	}
+	if s := SensitiveAnswers(app, map[string]interface{}{"smtp_password": "hunter22"}); !reflect.DeepEqual(s, []string{"hunter22"}) {
+		t.Errorf("SensitiveAnswers = %v", s)
+	}
+}
@@ -3,68 +3,23 @@ package cli
 import (
 	"fmt"
 	"os"
-	"sort"
 	"strings"
 
 	"github.com/zdunecki/selfhosted/pkg/apps"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
 	"github.com/zdunecki/selfhosted/pkg/dns"
 	"github.com/zdunecki/selfhosted/pkg/providers"
+	"github.com/zdunecki/selfhosted/pkg/utils"
 )
 
-func buildWizardExtraVars(answers map[string]interface{}) map[string]string {
-	if len(answers) == 0 {
-		return nil
-	}
-
-	// Stable order is nice for debugging, but map output is ultimately unordered; we only use it for template replacement.
-	keys := make([]string, 0, len(answers))
-	for k := range answers {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	out := map[string]string{}
-	for _, k := range keys {
-		v := answers[k]
-		out[fmt.Sprintf("{wizard.steps.application.values.custom_questions.%s}", k)] = wizardValueToString(v)
-	}
-	return out
-}
-
-func wizardValueToString(v interface{}) string {
-	switch t := v.(type) {
-	case bool:
-		if t {
-			return "y"
-		}
-		return "n"
-	case string:
-		return t
-	case []interface{}:
-		// Default: join with commas (apps can instead use the frontend automation for complex TUIs).
-		parts := make([]string, 0, len(t))
-		for _, x := range t {
-			parts = append(parts, wizardValueToString(x))
-		}
-		return strings.Join(parts, ",")
-	case float64:
-		// JSON numbers decode as float64
-		if float64(int64(t)) == t {
-			return fmt.Sprintf("%d", int64(t))
-		}
-		return fmt.Sprintf("%v", t)
-	default:
-		if v == nil {
-			return ""
-		}
-		return fmt.Sprintf("%v", v)
-	}
-}
+// DefaultSSHUser is the user new servers are set up as; provider images log in as root.
+const DefaultSSHUser = "root"
 
 // DeployOptions holds all deployment configuration
 type DeployOptions struct {
 	ProviderName           string                 `json:"provider"`
 	AppName                string                 `json:"app"`
+	AppVersion             string                 `json:"app_version"` // app version or prefix (e.g. 2.13); empty deploys the default
 	Region                 string                 `json:"region"`
 	Size                   string                 `json:"size"`
 	Domain                 string                 `json:"domain"`
@@ -81,6 +36,20 @@ type DeployOptions struct {
 	CloudflareZoneName     string                 `json:"cloudflare_zone_name"` // Cloudflare zone name if using Cloudflare DNS
 	CloudflareProxied      bool                   `json:"cloudflare_proxied"`   // Whether to enable Cloudflare proxy
 	WizardAnswers          map[string]interface{} `json:"wizard_answers"`       // optional UI answers for interactive installers
+	IgnoreCompatibility    bool                   `json:"ignore_compatibility"` // deploy even if the app doesn't declare the provider or the size is below min_spec
+}
+
+// WizardEnv returns the deploy options that wizard show_if expressions and defaults can reference.
+func (o DeployOptions) WizardEnv() apps.WizardEnv {
+	return apps.NewWizardEnv(&apps.InstallConfig{
+		Domain:                 o.Domain,
+		EnableSSL:              o.EnableSSL,
+		Email:                  o.Email,
+		SSL:                    o.EnableSSL,
+		SSLPrivateKeyFile:      o.SSLPrivateKeyFile,
+		SSLCertificateCrt:      o.SSLCertificateCrt,
+		HttpToHttpsRedirection: o.HttpToHttpsRedirection,
+	})
 }
 
 // Deploy executes a deployment with the given options
@@ -92,12 +61,74 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 		return fmt.Errorf("provider error: %w", err)
 	}
 
-	// Get app
+	// Get app; the name may carry the version, as in umami@2.13.
+	if name, version := apps.SplitAppRef(opts.AppName); version != "" {
+		if opts.AppVersion != "" && opts.AppVersion != version {
+			err := fmt.Errorf("app %s: version %s conflicts with %s", name, version, opts.AppVersion)
+			logf("❌ %v\n", err)
+			return err
+		}
+		opts.AppName, opts.AppVersion = name, version
+	}
 	app, err := apps.Get(opts.AppName)
 	if err != nil {
 		logf("❌ App error: %v\n", err)
 		return fmt.Errorf("app error: %w", err)
 	}
+	appVersion, err := apps.ResolveVersion(app, opts.AppVersion)
+	if err != nil {
+		logf("❌ %v\n", err)
+		return err
+	}
+	if v, ok := apps.AppVerification(app.Name()); ok && !v.Trusted() && v.Status != apps.SigUnchecked {
+		logf("⚠️  %s (%s) is %s: its steps run as root on the server\n", app.Name(), v.Source, v.Status)
+	}
+
+	// Check wizard answers before anything is created; missing answers get their defaults.
+	answers, err := apps.ValidateWizardAnswers(app, opts.WizardAnswers, opts.WizardEnv())
+	if err != nil {
+		logf("❌ %v\n", err)
+		return err
+	}
+	opts.WizardAnswers = answers
+
+	// Enforce the app's declared providers and minimum specs (CPU, RAM, disk).
+	if err := apps.CheckProvider(app, provider.Name()); err != nil {
+		if !opts.IgnoreCompatibility {
+			logf("❌ %v (use --ignore-compatibility to deploy anyway)\n", err)
+			return err
+		}
+		logf("⚠️  %v; continuing because compatibility checks are disabled\n", err)
+	}
+	if opts.Size != "" {
+		if err := checkRequestedSize(app, provider, opts.Region, opts.Size); err != nil {
+			if !opts.IgnoreCompatibility {
+				logf("❌ %v (use --ignore-compatibility to deploy anyway)\n", err)
+				return err
+			}
+			logf("⚠️  %v; continuing because compatibility checks are disabled\n", err)
+		}
+	}
+
+	// Load (or start) the local deployment record and make sure app secrets exist.
+	// Secrets are generated once and reused, so re-running a deploy never rotates them.
+	record, err := LoadOrCreateDeployment(opts.AppName, opts.Domain)
+	if err != nil {
+		logf("❌ Deployment record error: %v\n", err)
+		return fmt.Errorf("deployment record error: %w", err)
+	}
+	generated, err := EnsureSecrets(app, record)
+	if err != nil {
+		logf("❌ Secret generation failed: %v\n", err)
+		return fmt.Errorf("secret generation failed: %w", err)
+	}
+	redactor := SecretsRedactor(record)
+	sensitiveAnswers := apps.SensitiveAnswers(app, opts.WizardAnswers)
+	redactor.Add(sensitiveAnswers...)
+	logf = redactor.Logger(logf)
+	if generated > 0 {
+		logf("🔐 Generated %d secret(s) for %s\n", generated, opts.AppName)
+	}
 
 	// Load SSH keys
 	sshPrivate, sshPublic, err := LoadSSHKeys(opts.SSHKeyPath, opts.SSHPubKey)
@@ -123,6 +154,9 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 	}
 
 	logf("🚀 Deploying %s to %s\n", opts.AppName, opts.ProviderName)
+	if appVersion != "" {
+		logf("   Version: %s\n", appVersion)
+	}
 	logf("   Region: %s\n", vmRegion)
 	logf("   Size: %s\n", vmSize)
 	logf("   Domain: %s\n", opts.Domain)
@@ -137,12 +171,17 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 		Name:          serverName,
 		Region:        vmRegion,
 		Size:          vmSize,
+		Image:         apps.OSFor(app),
 		SSHPublicKey:  sshPublic,
 		SSHPrivateKey: sshPrivate,
 		Domain:        opts.Domain,
 		Tags:          []string{opts.AppName, "selfhost"},
 	}
 
+	if osImage, ok := providers.LookupOS(config.Image); ok {
+		logf("🖥️  OS: %s\n", osImage.Title())
+	}
+
 	// Step 1: Create server
 	logf("⏳ Creating server...\n")
 	server, err := provider.CreateServer(config)
@@ -161,6 +200,20 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 	}
 	logf("✅ Server ready with IP: %s\n", server.IP)
 
+	record.Provider = opts.ProviderName
+	record.Region = vmRegion
+	record.Size = vmSize
+	record.OS = config.Image
+	record.ServerID = server.ID
+	record.ServerName = server.Name
+	record.ServerIP = server.IP
+	record.SSHUser = DefaultSSHUser
+	record.SSHKeyPath = opts.SSHKeyPath
+	record.Version = appVersion
+	if err := deployments.Save(record); err != nil {
+		logf("⚠️  Could not save deployment record: %v\n", err)
+	}
+
 	// Step 3: Setup DNS
 	detectedDNS := dns.DetectDNSProvider(opts.Domain)
 	detectedProvider := string(detectedDNS.Name)
@@ -280,24 +333,34 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 
 	// Step 5: Install app
 	logf("⏳ Installing %s (this may take 10-15 minutes)...\n", opts.AppName)
+	// Interactive steps are recorded with the deployment for `selfhost replay`.
+	recordDir, err := deployments.PTYDir(record.ID)
+	if err != nil {
+		logf("⚠️  Terminal sessions won't be recorded: %v\n", err)
+	}
 	installConfig := &apps.InstallConfig{
 		Domain:                 opts.Domain,
 		ServerIP:               server.IP,
 		SSHKey:                 sshPrivate,
-		SSHUser:                "root",
+		SSHUser:                record.SSHUser,
 		EnableSSL:              opts.EnableSSL,
 		Email:                  opts.Email,
 		SSL:                    opts.EnableSSL,
 		SSLPrivateKeyFile:      opts.SSLPrivateKeyFile,
 		SSLCertificateCrt:      opts.SSLCertificateCrt,
 		HttpToHttpsRedirection: opts.HttpToHttpsRedirection,
+		Version:                appVersion,
 		Logger:                 logf, // Pass logger to capture all installation logs
-		ExtraVars:              buildWizardExtraVars(opts.WizardAnswers),
+		WizardAnswers:          opts.WizardAnswers,
+		Secrets:                record.Secrets,
+		SensitiveValues:        sensitiveAnswers,
+		RecordDir:              recordDir,
 	}
 
 	err = app.Install(installConfig)
 	if err != nil {
-		return fmt.Errorf("installation failed: %w", err)
+		// Errors may embed the rendered command; redact before it reaches the SSE error event.
+		return fmt.Errorf("installation failed: %s", redactor.Redact(err.Error()))
 	}
 	logf("✅ %s installed\n", opts.AppName)
 
@@ -326,6 +389,82 @@ func Deploy(opts DeployOptions, logf func(string, ...interface{})) error {
 	return nil
 }
 
+// checkRequestedSize validates an explicitly requested size against the app's min_spec.
+// Sizes the provider doesn't list (or a failing listing) are not treated as incompatible.
+func checkRequestedSize(app apps.App, provider providers.Provider, region, slug string) error {
+	sizes, err := providers.ListSizesForRegion(provider, region)
+	if err != nil {
+		return nil
+	}
+	for _, s := range sizes {
+		if s.Slug == slug {
+			return apps.CheckSize(app, s)
+		}
+	}
+	return nil
+}
+
+// LoadOrCreateDeployment returns the stored record for app+domain, or a new unsaved one.
+func LoadOrCreateDeployment(appName, domain string) (*deployments.Deployment, error) {
+	id := deployments.IDFor(appName, domain)
+	record, err := deployments.Load(id)
+	if err == nil {
+		return record, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &deployments.Deployment{
+		ID:     id,
+		App:    appName,
+		Domain: domain,
+	}, nil
+}
+
+// EnsureSecrets generates any secrets the app declares that the record doesn't hold yet
+// and persists the record when something new was generated. Returns the number of generated secrets.
+func EnsureSecrets(app apps.App, record *deployments.Deployment) (int, error) {
+	sp, ok := app.(apps.SecretsProvider)
+	if !ok {
+		return 0, nil
+	}
+
+	generated := 0
+	for _, spec := range sp.SecretSpecs() {
+		if spec.Name == "" {
+			continue
+		}
+		if _, exists := record.Secrets[spec.Name]; exists {
+			continue
+		}
+		value, err := utils.GenerateSecret(spec.Length, spec.Charset, spec.Encoding)
+		if err != nil {
+			return generated, fmt.Errorf("secret %s: %w", spec.Name, err)
+		}
+		if record.Secrets == nil {
+			record.Secrets = map[string]string{}
+		}
+		record.Secrets[spec.Name] = value
+		generated++
+	}
+
+	if generated > 0 {
+		if err := deployments.Save(record); err != nil {
+			return generated, err
+		}
+	}
+	return generated, nil
+}
+
+// SecretsRedactor returns a redactor for all secrets stored with the record.
+func SecretsRedactor(record *deployments.Deployment) *utils.Redactor {
+	values := make([]string, 0, len(record.Secrets))
+	for _, v := range record.Secrets {
+		values = append(values, v)
+	}
+	return utils.NewRedactor(values...)
+}
+
 func LoadSSHKeys(privatePath, publicPath string) (privateKey, publicKey string, err error) {
 	// Try to load from flags first
 	if privatePath != "" {
@@ -379,3 +518,51 @@ func LoadSSHKeys(privatePath, publicPath string) (privateKey, publicKey string,
 
 	return privateKey, publicKey, nil
 }
+
+// LoadSSHPrivateKey reads the private key at path, or the first default key (~/.ssh/id_rsa, ~/.ssh/id_ed25519) when path is empty.
+func LoadSSHPrivateKey(path string) (string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read private key: %w", err)
+		}
+		return string(data), nil
+	}
+	home, _ := os.UserHomeDir()
+	for _, p := range []string{home + "/.ssh/id_rsa", home + "/.ssh/id_ed25519"} {
+		if data, err := os.ReadFile(p); err == nil {
+			return string(data), nil
+		}
+	}
+	return "", fmt.Errorf("SSH private key not found. Use --ssh-key")
+}
+
+// SSHUser returns the user to log in to a deployment's server as: the stored one, or DefaultSSHUser
+// for records saved before it was stored.
+func SSHUser(d *deployments.Deployment) string {
+	if d.SSHUser != "" {
+		return d.SSHUser
+	}
+	return DefaultSSHUser
+}
+
+// ConnectDeployment opens an SSH connection to a deployment's server with its stored user and key.
+// keyPath overrides the stored key path. The caller closes the runner.
+func ConnectDeployment(d *deployments.Deployment, keyPath string) (*utils.SSHRunner, error) {
+	if d.ServerIP == "" {
+		return nil, fmt.Errorf("deployment %s has no server IP", d.ID)
+	}
+	if keyPath == "" {
+		keyPath = d.SSHKeyPath
+	}
+	privateKey, err := LoadSSHPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	user := SSHUser(d)
+	runner := utils.NewSSHRunner(d.ServerIP, user, privateKey)
+	if err := runner.Connect(); err != nil {
+		return nil, fmt.Errorf("ssh %s@%s: %w", user, d.ServerIP, err)
+	}
+	return runner, nil
+}
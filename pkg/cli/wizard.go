@@ -22,6 +22,7 @@ type wizardStep int
 const (
 	stepMode wizardStep = iota
 	stepApp
+	stepVersion
 	stepProvider
 	stepRegion
 	stepSize
@@ -36,6 +37,7 @@ const (
 	stepSSHPrivate
 	stepSSHPublic
 	stepDeployName
+	stepQuestion
 	stepConfirm
 	stepDone
 )
@@ -66,6 +68,8 @@ type wizardModel struct {
 	cloudflareProxied  bool                // User's proxy preference
 	detectedDNS        dns.DNSProviderInfo // Detected DNS provider from domain
 	startWebUI         bool
+	questions          []apps.WizardQuestion // The app's wizard questions, asked after the server name
+	questionIdx        int                   // Current question during stepQuestion
 }
 
 var (
@@ -148,7 +152,7 @@ func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.list.SetSize(msg.Width, msg.Height-4)
-		if m.step == stepDomain || m.step == stepEmail || m.step == stepSSHPrivate || m.step == stepSSHPublic || m.step == stepDeployName || m.step == stepCloudflareTokenInput {
+		if m.usesInput() {
 			m.input.Width = msg.Width - 4
 		}
 	case tea.KeyMsg:
@@ -157,25 +161,34 @@ func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cancelled = true
 			return m, tea.Quit
 		case "enter":
-			if m.step != stepDomain && m.step != stepEmail && m.step != stepSSHPrivate && m.step != stepSSHPublic && m.step != stepDeployName && m.step != stepCloudflareTokenInput {
+			if !m.usesInput() {
 				return m.handleSelection()
 			}
 		}
 	}
 
-	switch m.step {
-	case stepDomain, stepEmail, stepSSHPrivate, stepSSHPublic, stepDeployName, stepCloudflareTokenInput:
+	if m.usesInput() {
 		var cmd tea.Cmd
 		m.input, cmd = m.input.Update(msg)
 		if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEnter {
 			return m.handleInputSubmit()
 		}
 		return m, cmd
-	default:
-		var cmd tea.Cmd
-		m.list, cmd = m.list.Update(msg)
-		return m, cmd
 	}
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+// usesInput reports whether the current step reads a text input rather than a list.
+func (m wizardModel) usesInput() bool {
+	switch m.step {
+	case stepDomain, stepEmail, stepSSHPrivate, stepSSHPublic, stepDeployName, stepCloudflareTokenInput:
+		return true
+	case stepQuestion:
+		return !m.questionUsesList()
+	}
+	return false
 }
 
 func (m wizardModel) View() string {
@@ -204,6 +217,8 @@ func (m wizardModel) View() string {
 		return header + styleSubtitle.Render("Optional: path to SSH public key (leave blank to auto-detect):") + "\n\n" + m.input.View() + "\n\n" + stylePrompt.Render("Press Enter to continue.")
 	case stepDeployName:
 		return header + styleSubtitle.Render("Optional: server name (leave blank to use default):") + "\n\n" + m.input.View() + "\n\n" + stylePrompt.Render("Press Enter to continue.")
+	case stepQuestion:
+		return m.questionView(header)
 	case stepConfirm:
 		return header + styleSummary.Render(m.confirmSummary()) + "\n\n" + m.list.View() + "\n\n" + stylePrompt.Render("Use Enter to confirm, q to quit.")
 	default:
@@ -229,7 +244,19 @@ func (m wizardModel) handleSelection() (tea.Model, tea.Cmd) {
 		m.step = stepApp
 	case stepApp:
 		m.opts.AppName = item.value
-		m.list = newList("Select provider", providerItems())
+		m.opts.AppVersion = ""
+		if items := versionItems(m.opts.AppName); len(items) > 1 {
+			m.list = newList("Select version", items)
+			m.applyListSize()
+			m.step = stepVersion
+			break
+		}
+		m.list = newList("Select provider", providerItems(m.opts.AppName))
+		m.applyListSize()
+		m.step = stepProvider
+	case stepVersion:
+		m.opts.AppVersion = item.value
+		m.list = newList("Select provider", providerItems(m.opts.AppName))
 		m.applyListSize()
 		m.step = stepProvider
 	case stepProvider:
@@ -398,6 +425,8 @@ func (m wizardModel) handleSelection() (tea.Model, tea.Cmd) {
 		} else {
 			m.setInput(stepSSHPrivate, "~/.ssh/id_ed25519")
 		}
+	case stepQuestion:
+		m.answerQuestion(item.value)
 	case stepConfirm:
 		if item.value == "deploy" {
 			m.step = stepDone
@@ -469,9 +498,9 @@ func (m wizardModel) handleInputSubmit() (tea.Model, tea.Cmd) {
 		if m.opts.DeployName == "" {
 			m.opts.DeployName = fmt.Sprintf("%s-server", m.opts.AppName)
 		}
-		m.list = newList("Confirm deployment", confirmItems())
-		m.applyListSizeWithOffset(m.confirmSummaryLineCount() + 4)
-		m.step = stepConfirm
+		m.startQuestions()
+	case stepQuestion:
+		m.answerQuestion(value)
 	}
 
 	return m, nil
@@ -523,15 +552,15 @@ func (m wizardModel) loadSizes() ([]providers.Size, error) {
 	if err != nil {
 		return nil, err
 	}
-	type sizesByRegion interface {
-		ListSizesForRegion(region string) ([]providers.Size, error)
+	sizes, err := providers.ListSizesForRegion(provider, m.opts.Region)
+	if err != nil {
+		return nil, err
 	}
-	if m.opts.Region != "" {
-		if sp, ok := provider.(sizesByRegion); ok {
-			return sp.ListSizesForRegion(m.opts.Region)
-		}
+	// Only offer sizes meeting the app's minimum CPU, RAM and disk.
+	if app, err := apps.Get(m.opts.AppName); err == nil {
+		sizes = providers.FilterSizesForSpecs(sizes, app.MinSpecs())
 	}
-	return provider.ListSizes()
+	return sizes, nil
 }
 
 func modeItems() []list.Item {
@@ -542,15 +571,16 @@ func modeItems() []list.Item {
 }
 
 func appItems() []list.Item {
-	names := make([]string, 0, len(apps.Registry))
-	for name := range apps.Registry {
+	all := apps.All()
+	names := make([]string, 0, len(all))
+	for name := range all {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
 	items := make([]list.Item, 0, len(names))
 	for _, name := range names {
-		app := apps.Registry[name]
+		app := all[name]
 		items = append(items, optionItem{
 			title: name,
 			desc:  app.Description(),
@@ -560,9 +590,39 @@ func appItems() []list.Item {
 	return items
 }
 
-func providerItems() []list.Item {
+// versionItems lists the app's versions, newest first, with the default marked.
+func versionItems(appName string) []list.Item {
+	app, err := apps.Get(appName)
+	if err != nil {
+		return nil
+	}
+	names := apps.VersionNames(app)
+	def, latest := apps.DefaultVersion(app), apps.LatestVersion(app)
+	items := make([]list.Item, 0, len(names))
+	for _, name := range names {
+		var notes []string
+		if name == def {
+			notes = append(notes, "default")
+		}
+		if name == latest {
+			notes = append(notes, "latest")
+		}
+		items = append(items, optionItem{
+			title: name,
+			desc:  strings.Join(notes, ", "),
+			value: name,
+		})
+	}
+	return items
+}
+
+func providerItems(appName string) []list.Item {
+	app, _ := apps.Get(appName)
 	names := make([]string, 0, len(providers.Registry))
 	for name := range providers.Registry {
+		if app != nil && !apps.SupportsProvider(app, name) {
+			continue
+		}
 		names = append(names, name)
 	}
 	sort.Strings(names)
@@ -727,7 +787,7 @@ func findSizeForApp(sizes []providers.Size, appName string) (string, bool) {
 	specs := app.MinSpecs()
 	var best *providers.Size
 	for i, size := range sizes {
-		if size.VCPUs >= specs.CPUs && size.MemoryMB >= specs.MemoryMB {
+		if providers.SizeMeetsSpecs(size, specs) {
 			if best == nil || size.PriceMonthly < best.PriceMonthly {
 				best = &sizes[i]
 			}
@@ -755,7 +815,7 @@ func (m wizardModel) confirmSummary() string {
 
 	lines := []string{
 		styleHighlight.Render("Review your selections"),
-		fmt.Sprintf("App:         %s", m.opts.AppName),
+		fmt.Sprintf("App:         %s", m.appLabel()),
 		fmt.Sprintf("Provider:    %s", m.opts.ProviderName),
 		fmt.Sprintf("Region:      %s", m.opts.Region),
 		fmt.Sprintf("Size:        %s", sizeLabel),
@@ -779,9 +839,32 @@ func (m wizardModel) confirmSummary() string {
 	if m.opts.SSHPubKey != "" {
 		lines = append(lines, fmt.Sprintf("SSH public:  %s", m.opts.SSHPubKey))
 	}
+	for _, q := range m.questions {
+		answer, ok := m.opts.WizardAnswers[q.ID]
+		if !ok {
+			continue
+		}
+		value := answerText(answer)
+		if q.Sensitive() && value != "" {
+			value = "********"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", q.Name, value))
+	}
 	return strings.Join(lines, "\n")
 }
 
+// appLabel returns the app with the version it will be deployed at, e.g. umami@2.13.2.
+func (m wizardModel) appLabel() string {
+	app, err := apps.Get(m.opts.AppName)
+	if err != nil {
+		return m.opts.AppName
+	}
+	if version, err := apps.ResolveVersion(app, m.opts.AppVersion); err == nil && version != "" {
+		return m.opts.AppName + "@" + version
+	}
+	return m.opts.AppName
+}
+
 func (m wizardModel) domainHint() string {
 	appName := m.opts.AppName
 	if appName == "" {
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+
+	"github.com/zdunecki/selfhosted/pkg/apps"
+)
+
+// startQuestions asks the app's wizard questions, then moves on to the confirmation.
+func (m *wizardModel) startQuestions() {
+	m.questions = nil
+	if app, err := apps.Get(m.opts.AppName); err == nil {
+		if wp, ok := app.(apps.WizardProvider); ok {
+			m.questions = wp.WizardQuestions()
+		}
+	}
+	m.opts.WizardAnswers = map[string]interface{}{}
+	m.showQuestion(0)
+}
+
+// showQuestion shows the first visible question from index i on. Questions hidden by their
+// show_if are skipped, so they are re-evaluated against the answers given so far.
+func (m *wizardModel) showQuestion(i int) {
+	env := m.opts.WizardEnv()
+	for ; i < len(m.questions); i++ {
+		q := m.questions[i]
+		if !apps.QuestionVisible(q, m.opts.WizardAnswers, env) {
+			delete(m.opts.WizardAnswers, q.ID)
+			continue
+		}
+		m.questionIdx = i
+		def := apps.QuestionDefault(q, m.opts.WizardAnswers, env)
+		if items := questionItems(q); items != nil {
+			m.step = stepQuestion
+			m.validationErr = ""
+			m.list = newList(q.Name, items)
+			for idx, item := range items {
+				if item.(optionItem).value == answerText(def) {
+					m.list.Select(idx)
+				}
+			}
+			m.applyListSizeWithOffset(6)
+			return
+		}
+		m.setInput(stepQuestion, q.Placeholder)
+		if q.Sensitive() {
+			m.input.EchoMode = textinput.EchoPassword
+		}
+		m.input.SetValue(answerText(def))
+		return
+	}
+
+	m.list = newList("Confirm deployment", confirmItems())
+	m.applyListSizeWithOffset(m.confirmSummaryLineCount() + 4)
+	m.step = stepConfirm
+}
+
+// answerQuestion validates the answer to the current question and shows the next one.
+func (m *wizardModel) answerQuestion(value string) {
+	q := m.questions[m.questionIdx]
+	answer, err := apps.ValidateWizardAnswer(q, value)
+	if err != nil {
+		m.validationErr = fmt.Sprintf("%s %v", q.Name, err)
+		return
+	}
+	m.opts.WizardAnswers[q.ID] = answer
+	m.showQuestion(m.questionIdx + 1)
+}
+
+// questionUsesList reports whether the current question is answered from a list rather than a text input.
+func (m wizardModel) questionUsesList() bool {
+	return m.questionIdx < len(m.questions) && questionItems(m.questions[m.questionIdx]) != nil
+}
+
+func (m wizardModel) questionView(header string) string {
+	q := m.questions[m.questionIdx]
+	var help string
+	if q.Help != "" {
+		help = styleSummary.Render(q.Help) + "\n\n"
+	}
+	if m.questionUsesList() {
+		return header + help + m.list.View() + "\n\n" + stylePrompt.Render("Use ↑/↓ to move, Enter to select, q to quit.")
+	}
+	prompt := "Press Enter to continue."
+	if q.MultiSelect() {
+		choices := make([]string, len(q.Choices))
+		for i, c := range q.Choices {
+			choices[i] = c.Name
+		}
+		prompt = "Separate choices with commas (" + strings.Join(choices, ", ") + "). " + prompt
+	}
+	return header + styleSubtitle.Render(q.Name+":") + "\n" + help + "\n" + m.input.View() + "\n\n" + stylePrompt.Render(prompt)
+}
+
+// questionItems returns the list items for yes/no and single-choice questions, nil for the rest.
+func questionItems(q apps.WizardQuestion) []list.Item {
+	switch apps.NormalizeQuestionType(q.Type) {
+	case apps.QuestionBoolean:
+		return []list.Item{
+			optionItem{title: "yes", value: "true"},
+			optionItem{title: "no", value: "false"},
+		}
+	case apps.QuestionSelect, apps.QuestionChoice:
+		if q.MultiSelect() || len(q.Choices) == 0 {
+			return nil
+		}
+		items := make([]list.Item, len(q.Choices))
+		for i, c := range q.Choices {
+			items[i] = optionItem{title: c.Name, value: c.Name}
+		}
+		return items
+	}
+	return nil
+}
+
+// answerText formats an answer for a text input or the summary.
+func answerText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, x := range t {
+			parts[i] = answerText(x)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
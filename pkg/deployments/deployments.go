@@ -0,0 +1,208 @@
+package deployments
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Deployment is the local record of a deployed app.
+// Records live in ~/.selfhosted/deployments/<id>/deployment.json; the directory also holds per-deployment artifacts.
+type Deployment struct {
+	ID         string    `json:"id"`
+	App        string    `json:"app"`
+	Provider   string    `json:"provider"`
+	Region     string    `json:"region"`
+	Size       string    `json:"size"`
+	OS         string    `json:"os,omitempty"`
+	Version    string    `json:"version,omitempty"` // app version installed, when the app has versions
+	Domain     string    `json:"domain"`
+	ServerID   string    `json:"server_id,omitempty"`
+	ServerName string    `json:"server_name,omitempty"`
+	ServerIP   string    `json:"server_ip,omitempty"`
+	SSHUser    string    `json:"ssh_user,omitempty"`
+	SSHKeyPath string    `json:"ssh_key_path,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Secrets holds generated app secrets in memory only; on disk they are stored encrypted in SecretsEnc.
+	Secrets    map[string]string `json:"-"`
+	SecretsEnc string            `json:"secrets_enc,omitempty"`
+}
+
+const recordFile = "deployment.json"
+
+// Root returns the directory holding all deployment records.
+func Root() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".selfhosted", "deployments"), nil
+}
+
+// Dir returns the directory for a single deployment.
+func Dir(id string) (string, error) {
+	root, err := Root()
+	if err != nil {
+		return "", err
+	}
+	safeID := sanitizeID(id)
+	if safeID == "" {
+		return "", fmt.Errorf("invalid deployment id: %q", id)
+	}
+	return filepath.Join(root, safeID), nil
+}
+
+// IDFor returns the stable deployment id for an app deployed to a domain.
+// Re-deploying the same app to the same domain reuses the record (and therefore its secrets).
+func IDFor(app, domain string) string {
+	return sanitizeID(strings.ToLower(app + "-" + strings.ReplaceAll(domain, ".", "-")))
+}
+
+// Load reads a deployment record by id and decrypts its secrets.
+func Load(id string) (*Deployment, error) {
+	dir, err := Dir(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, recordFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var d Deployment
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("deployment %s: %w", id, err)
+	}
+	if d.SecretsEnc != "" {
+		secrets, err := decryptSecrets(d.SecretsEnc)
+		if err != nil {
+			return nil, fmt.Errorf("deployment %s: decrypt secrets: %w", id, err)
+		}
+		d.Secrets = secrets
+	}
+	return &d, nil
+}
+
+// Save writes a deployment record, encrypting its secrets.
+func Save(d *Deployment) error {
+	if d == nil || strings.TrimSpace(d.ID) == "" {
+		return fmt.Errorf("deployment id is required")
+	}
+	dir, err := Dir(d.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+
+	d.SecretsEnc = ""
+	if len(d.Secrets) > 0 {
+		enc, err := encryptSecrets(d.Secrets)
+		if err != nil {
+			return fmt.Errorf("deployment %s: encrypt secrets: %w", d.ID, err)
+		}
+		d.SecretsEnc = enc
+	}
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a crash mid-deploy never leaves a truncated record behind.
+	tmp := filepath.Join(dir, recordFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, recordFile))
+}
+
+// List returns all deployment records, newest first.
+func List() ([]*Deployment, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []*Deployment
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		d, err := Load(entry.Name())
+		if err != nil {
+			continue
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Find resolves a user-supplied reference (id, domain, server name or IP) to a deployment record.
+func Find(ref string) (*Deployment, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("deployment reference is required")
+	}
+	if d, err := Load(ref); err == nil {
+		return d, nil
+	}
+
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Deployment
+	for _, d := range all {
+		if strings.EqualFold(d.Domain, ref) || d.ServerName == ref || d.ServerIP == ref || d.ServerID == ref {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("deployment not found: %s", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, d := range matches {
+			ids = append(ids, d.ID)
+		}
+		return nil, fmt.Errorf("deployment %q is ambiguous (matches: %s)", ref, strings.Join(ids, ", "))
+	}
+}
+
+func sanitizeID(input string) string {
+	var b strings.Builder
+	for _, r := range input {
+		if isIDRune(r) {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func isIDRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
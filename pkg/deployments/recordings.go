@@ -0,0 +1,88 @@
+package deployments
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// CastExt is the file extension of PTY recordings (asciicast v2).
+const CastExt = ".cast"
+
+// Recording describes a recorded PTY session of a deployment.
+type Recording struct {
+	Session   string    `json:"session"`
+	Title     string    `json:"title,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Size      int64     `json:"size"`
+}
+
+// PTYDir returns the directory holding a deployment's PTY recordings.
+func PTYDir(id string) (string, error) {
+	dir, err := Dir(id)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pty"), nil
+}
+
+// RecordingPath returns the recording file of a PTY session. The session may include the .cast extension.
+func RecordingPath(id, session string) (string, error) {
+	dir, err := PTYDir(id)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(session, CastExt)
+	if name == "" || strings.TrimFunc(name, isIDRune) != "" {
+		return "", fmt.Errorf("invalid pty session: %q", session)
+	}
+	return filepath.Join(dir, name+CastExt), nil
+}
+
+// Recordings lists a deployment's PTY recordings, oldest first.
+func Recordings(id string) ([]Recording, error) {
+	dir, err := PTYDir(id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []Recording
+	modified := map[string]time.Time{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), CastExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		rec := Recording{
+			Session:   strings.TrimSuffix(entry.Name(), CastExt),
+			StartedAt: info.ModTime().UTC(),
+			Size:      info.Size(),
+		}
+		if header, err := utils.ReadCastHeader(filepath.Join(dir, entry.Name())); err == nil {
+			rec.Title = header.Title
+			if header.Timestamp > 0 {
+				rec.StartedAt = time.Unix(header.Timestamp, 0).UTC()
+			}
+		}
+		modified[rec.Session] = info.ModTime()
+		out = append(out, rec)
+	}
+	// Headers only have second precision; sessions run one after another, so the last write orders them.
+	sort.Slice(out, func(i, j int) bool { return modified[out[i].Session].Before(modified[out[j].Session]) })
+	return out, nil
+}
@@ -0,0 +1,166 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConditionTerm is one operand of a `show_if` expression: a variable tested for truthiness,
+// or compared with a value using == or !=.
+type ConditionTerm struct {
+	Var    string `json:"var"`
+	Op     string `json:"op,omitempty"` // "", "==" or "!="
+	Value  string `json:"value,omitempty"`
+	Negate bool   `json:"negate,omitempty"`
+}
+
+// Condition is a parsed `show_if` expression: it holds when all terms of any group hold.
+// Like `if:`, it has no parentheses; && binds tighter than ||.
+type Condition [][]ConditionTerm
+
+// ParseCondition parses expressions such as `answers.ssl == "Custom" && !opts.SSLCertificateCrt`.
+// Values may be quoted with single or double quotes and can't contain && or ||.
+func ParseCondition(expr string) (Condition, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+	var cond Condition
+	for _, or := range strings.Split(expr, "||") {
+		var group []ConditionTerm
+		for _, raw := range strings.Split(or, "&&") {
+			term, err := parseConditionTerm(strings.TrimSpace(raw))
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition %q: %w", expr, err)
+			}
+			group = append(group, term)
+		}
+		cond = append(cond, group)
+	}
+	return cond, nil
+}
+
+func parseConditionTerm(raw string) (ConditionTerm, error) {
+	var term ConditionTerm
+	if raw == "" {
+		return term, fmt.Errorf("empty operand")
+	}
+	for _, op := range []string{"==", "!="} {
+		if left, right, ok := strings.Cut(raw, op); ok {
+			term.Var = strings.TrimSpace(left)
+			term.Op = op
+			term.Value = unquote(strings.TrimSpace(right))
+			if !conditionIdent.MatchString(term.Var) {
+				return term, fmt.Errorf("unexpected %q", term.Var)
+			}
+			return term, nil
+		}
+	}
+	if strings.HasPrefix(raw, "!") {
+		term.Negate = true
+		raw = strings.TrimSpace(strings.TrimPrefix(raw, "!"))
+	}
+	if !conditionIdent.MatchString(raw) {
+		return term, fmt.Errorf("unexpected %q", raw)
+	}
+	term.Var = raw
+	return term, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Vars returns the variables the condition references.
+func (c Condition) Vars() []string {
+	var out []string
+	for _, group := range c {
+		for _, term := range group {
+			out = append(out, term.Var)
+		}
+	}
+	return out
+}
+
+// Eval evaluates the condition; lookup returns a variable's value (nil when unset).
+func (c Condition) Eval(lookup func(name string) interface{}) bool {
+	for _, group := range c {
+		ok := true
+		for _, term := range group {
+			if !term.eval(lookup(term.Var)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t ConditionTerm) eval(v interface{}) bool {
+	switch t.Op {
+	case "==":
+		return valueEquals(v, t.Value)
+	case "!=":
+		return !valueEquals(v, t.Value)
+	}
+	return ValueTruthy(v) != t.Negate
+}
+
+// ValueTruthy reports how an answer or option reads in a condition: false, empty strings (and the
+// strings Truthy rejects), zero, empty lists and nil are false.
+func ValueTruthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return Truthy(t)
+	case float64:
+		return t != 0
+	case int:
+		return t != 0
+	case []interface{}:
+		return len(t) > 0
+	case []string:
+		return len(t) > 0
+	}
+	return true
+}
+
+// valueEquals compares an answer with a literal; lists match when they contain it.
+func valueEquals(v interface{}, want string) bool {
+	switch t := v.(type) {
+	case nil:
+		return want == ""
+	case string:
+		return t == want
+	case bool:
+		return strconv.FormatBool(t) == want
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64) == want
+	case int:
+		return strconv.Itoa(t) == want
+	case []interface{}:
+		for _, x := range t {
+			if valueEquals(x, want) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, x := range t {
+			if x == want {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(v) == want
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,6 +14,8 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
+
 type SizeMB int
 type SizeGB int
 
@@ -39,16 +43,47 @@ func (s *SizeGB) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// Spec is an app definition at LatestAPIVersion; LoadSpec migrates older specs first.
 type Spec struct {
-	App         string     `yaml:"app"`
-	Description string     `yaml:"description"`
-	OS          string     `yaml:"os"`
-	DomainHint  string     `yaml:"domain_hint"`
-	MinSpec     SpecHW     `yaml:"min_spec"`
-	Providers   []string   `yaml:"providers"`
-	DNS         DNSSpec    `yaml:"dns"`
-	Wizard      WizardSpec `yaml:"wizard"`
-	Steps       []Step     `yaml:"steps"`
+	APIVersion  string        `yaml:"apiVersion"`
+	App         string        `yaml:"app"`
+	Description string        `yaml:"description"`
+	Metadata    MetadataSpec  `yaml:"metadata"`
+	OS          string        `yaml:"os"`
+	MinSpec     SpecHW        `yaml:"min_spec"`
+	Providers   []string      `yaml:"providers"`
+	Versions    []VersionSpec `yaml:"versions"`
+	DNS         DNSSpec       `yaml:"dns"`
+	Secrets     []SecretSpec  `yaml:"secrets"`
+	Wizard      WizardSpec    `yaml:"wizard"`
+	Steps       []Step        `yaml:"steps"`
+}
+
+// MetadataSpec describes the app in app lists and catalogs; it doesn't change how the app is deployed.
+type MetadataSpec struct {
+	DisplayName string   `yaml:"display_name"`
+	Category    string   `yaml:"category"`
+	Tags        []string `yaml:"tags"`
+	Homepage    string   `yaml:"homepage"`
+	Repository  string   `yaml:"repository"`
+	License     string   `yaml:"license"`
+	// Icon is an https URL or a path relative to the marketplace (or catalog) root, e.g. icons/umami.svg.
+	Icon string `yaml:"icon"`
+	// Version is the upstream version the spec installs, for display.
+	Version string `yaml:"version"`
+}
+
+// SecretSpec declares a value generated locally by selfhosted on the first deploy and reused afterwards.
+// Generated values are stored encrypted with the deployment record and exposed to templates as `{secrets.NAME}`.
+type SecretSpec struct {
+	Name string `yaml:"name"`
+	// Length is the number of characters for charset-based secrets, or the number of random bytes when Encoding is set
+	// (0 => 32).
+	Length int `yaml:"length"`
+	// Charset is one of: alnum (default), alpha, numeric, hex, symbols.
+	Charset string `yaml:"charset"`
+	// Encoding (optional) is one of: hex, base64, base64url. When set, Length random bytes are encoded instead.
+	Encoding string `yaml:"encoding"`
 }
 
 type DNSSpec struct {
@@ -79,12 +114,41 @@ type WizardApplicationStep struct {
 }
 
 type WizardQuestionSpec struct {
-	ID       string             `yaml:"id"`
-	Name     string             `yaml:"name"`
-	Type     string             `yaml:"type"` // boolean | text | choice
-	Default  interface{}        `yaml:"default"`
-	Required bool               `yaml:"required"`
-	Choices  []WizardChoiceSpec `yaml:"choices"`
+	ID          string             `yaml:"id"`
+	Name        string             `yaml:"name"`
+	Type        string             `yaml:"type"` // see QuestionTypes
+	Default     interface{}        `yaml:"default"`
+	Required    bool               `yaml:"required"`
+	Choices     []WizardChoiceSpec `yaml:"choices"`
+	Help        string             `yaml:"help"`
+	Placeholder string             `yaml:"placeholder"`
+	// ShowIf hides the question unless the expression over answers.ID and opts.* holds.
+	ShowIf string `yaml:"show_if"`
+
+	// Validation rules, checked by the wizards and again when deploying.
+	Pattern       string   `yaml:"pattern"`    // Regular expression text answers must match
+	Min           *float64 `yaml:"min"`        // number
+	Max           *float64 `yaml:"max"`        // number
+	MinLength     *int     `yaml:"min_length"` // text-like types
+	MaxLength     *int     `yaml:"max_length"` // text-like types
+	AllowedValues []string `yaml:"allowed_values"`
+}
+
+// QuestionTypes lists the wizard question types. "secret" is accepted as an alias of "password".
+var QuestionTypes = []string{"boolean", "text", "number", "password", "secret", "select", "multiselect", "email", "url", "domain", "choice"}
+
+// QuestionID returns the effective question ID: the explicit `id`, or a slug of the name.
+func QuestionID(q WizardQuestionSpec) string {
+	if id := strings.TrimSpace(q.ID); id != "" {
+		return id
+	}
+	s := strings.ToLower(strings.TrimSpace(q.Name))
+	s = nonAlnum.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	if s == "" {
+		return "q"
+	}
+	return s
 }
 
 type WizardChoiceSpec struct {
@@ -99,17 +163,97 @@ type SpecHW struct {
 }
 
 type Step struct {
-	Name  string  `yaml:"name"`
-	In    string  `yaml:"in"` // Where to run the step (e.g., "machine")
-	If    string  `yaml:"if"`
-	Run   string  `yaml:"run"`
-	TTY   TTYSpec `yaml:"tty"` // Run step in a PTY (interactive/TUI)
-	Sleep string  `yaml:"sleep"`
-	Log   string  `yaml:"log"`
+	Name  string            `yaml:"name"`
+	In    string            `yaml:"in"` // Where to run the step (e.g., "machine")
+	If    string            `yaml:"if"`
+	Run   string            `yaml:"run"`
+	TTY   TTYSpec           `yaml:"tty"` // Run step in a PTY (interactive/TUI)
+	Sleep string            `yaml:"sleep"`
+	Log   string            `yaml:"log"`
+	Uses  string            `yaml:"uses"` // Step library reference (e.g., "docker@v1"), expanded by LoadSpec
+	With  map[string]string `yaml:"with"` // Inputs for Uses
+
+	Compose  *ComposeSpec  `yaml:"compose"`  // Start a Docker Compose project instead of running a script
+	Register RegisterSpec  `yaml:"register"` // Store the step's stdout as {steps.NAME}
+	Parallel *ParallelSpec `yaml:"parallel"` // Run child steps concurrently
+
+	// From is the library a step was expanded from (e.g., "lib/docker"); empty for the app's own steps.
+	From string `yaml:"-"`
+}
+
+// SSLConditionVars are the options that move a step with an `if:` to SSL setup.
+var SSLConditionVars = []string{
+	"opts.SSL",
+	"opts.EnableSSL",
+	"opts.SSLPrivateKeyFile",
+	"opts.SSLCertificateCrt",
+	"opts.HttpToHttpsRedirection",
+}
+
+// SSLPhase reports whether the step runs during SSL setup instead of install: its `if:` references
+// one of SSLConditionVars. Other conditions (steps.*, wizard.*) run during install.
+func (s Step) SSLPhase() bool {
+	for _, v := range ConditionVars(s.If) {
+		if slices.Contains(SSLConditionVars, strings.TrimSpace(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Title returns the step name as shown in logs, including the library it came from.
+func (s Step) Title() string {
+	if s.From == "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s (from %s)", s.Name, s.From)
+}
+
+// ComposeSpec describes a Docker Compose project started by a `compose:` step.
+// The step writes Content and Env, runs `docker compose up -d` in Dir and waits for WaitHealthy services.
+type ComposeSpec struct {
+	// Dir is the project directory on the server; it is created if missing.
+	Dir string `yaml:"dir"`
+	// File is the compose file inside Dir. When empty, Compose's defaults apply (compose.yml plus overrides)
+	// and Content is written to compose.yml.
+	File string `yaml:"file"`
+	// Content is a compose document written to File before starting.
+	Content string `yaml:"content"`
+	// Env is written to Dir/.env; values are written verbatim after template rendering.
+	Env map[string]string `yaml:"env"`
+	// WaitHealthy lists services that must be healthy (or running, without a healthcheck) before the step succeeds.
+	WaitHealthy []string `yaml:"wait_healthy"`
+	// Timeout bounds WaitHealthy (e.g. 5m); defaults to DefaultComposeTimeout.
+	Timeout string `yaml:"timeout"`
+}
+
+// ParallelSpec runs child steps concurrently, each over its own SSH session.
+// The group fails fast: when a child fails, the others are stopped.
+type ParallelSpec struct {
+	// MaxConcurrency limits how many children run at once; 0 runs them all.
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// Steps are the children. They support run, log, sleep, if and register (visible after the group).
+	Steps []Step `yaml:"steps"`
 }
 
+// DefaultComposeTimeout is how long a compose step waits for WaitHealthy services by default.
+const DefaultComposeTimeout = 5 * time.Minute
+
 type TTYSpec struct {
-	Enabled    bool        `yaml:"-"`
+	Enabled bool `yaml:"-"`
+	// Expect rules answer prompts whenever their pattern shows up on the screen, in any order.
+	Expect []ExpectRule `yaml:"expect"`
+	// FailOn patterns abort the step as soon as they show up on the screen.
+	FailOn []ExpectFailure `yaml:"fail_on"`
+	// Timeout bounds the whole step (e.g. 30m); empty means no limit.
+	Timeout string `yaml:"timeout"`
+	// Term is the TERM the PTY advertises; empty means xterm-256color.
+	Term string `yaml:"term"`
+	// Rows and Cols set the initial terminal size (default 40x120). The web UI resizes it to fit its terminal.
+	Rows int `yaml:"rows"`
+	Cols int `yaml:"cols"`
+	// AutoAnswer is the original, ordered form. Each answer now runs as an expect rule that fires once,
+	// and only after the answer before it.
 	AutoAnswer []TTYAnswer `yaml:"auto_answer"`
 }
 
@@ -121,12 +265,121 @@ type TTYAnswer struct {
 	WaitFor string `yaml:"wait_for"`
 	// WaitForRegex treats WaitFor as a regexp when true.
 	WaitForRegex bool `yaml:"wait_for_regex"`
-	// TimeoutMS is max time to wait for WaitFor before giving up on this answer (0 => default 10min).
+	// TimeoutMS is no longer used: answers wait for their prompt until the step ends (see TTYSpec.Timeout).
 	TimeoutMS int `yaml:"timeout_ms"`
 	// DelayMS waits before sending this answer (optional).
 	DelayMS int `yaml:"delay_ms"`
 }
 
+// ExpectRule sends input when Pattern appears on the screen.
+type ExpectRule struct {
+	// Pattern is matched against the screen, one line per line of text (soft-wrapped rows are joined).
+	// Empty matches right away.
+	Pattern string `yaml:"pattern"`
+	// Regex treats Pattern as a regular expression.
+	Regex bool `yaml:"regex"`
+	// Send is rendered with template vars and typed. Enter follows unless SendKeys is set or Send
+	// contains \r or \n.
+	Send string `yaml:"send"`
+	// SendKeys are named keys (see Keys) pressed after Send.
+	SendKeys []string `yaml:"send_keys"`
+	// Repeat lets the rule fire again each time the pattern shows up anew; by default it fires once.
+	Repeat bool `yaml:"repeat"`
+	// DelayMS waits before sending; defaults to DefaultExpectDelay.
+	DelayMS int `yaml:"delay_ms"`
+
+	// yesNo sends true/false answers as y/n, like auto_answer always did.
+	yesNo bool
+	// afterPrevious keeps auto_answer order: the rule waits for the rule before it to fire.
+	afterPrevious bool
+}
+
+// ExpectFailure aborts a TTY step when Pattern appears on the screen.
+type ExpectFailure struct {
+	Pattern string `yaml:"pattern"`
+	Regex   bool   `yaml:"regex"`
+	// Message is the step's error; defaults to naming the pattern.
+	Message string `yaml:"message"`
+}
+
+// DefaultExpectDelay is how long an expect rule waits before sending, giving the prompt time to finish rendering.
+const DefaultExpectDelay = 350 * time.Millisecond
+
+// Keys are the names usable in send_keys.
+var Keys = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"shift-tab": "\x1b[Z",
+	"space":     " ",
+	"backspace": "\x7f",
+	"escape":    "\x1b",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"ctrl-c":    "\x03",
+	"ctrl-d":    "\x04",
+}
+
+// Rules returns the step's expect rules, followed by its auto_answer entries as rules that fire once,
+// each after the one before it.
+func (t TTYSpec) Rules() []ExpectRule {
+	rules := append([]ExpectRule(nil), t.Expect...)
+	for _, a := range t.AutoAnswer {
+		rules = append(rules, ExpectRule{
+			Pattern: a.WaitFor,
+			Regex:   a.WaitForRegex,
+			Send:    a.Value,
+			DelayMS: a.DelayMS,
+			yesNo:   true,
+
+			afterPrevious: true,
+		})
+	}
+	return rules
+}
+
+// AfterPrevious reports whether the rule becomes eligible only once the rule before it has fired,
+// as converted auto_answer entries do.
+func (r ExpectRule) AfterPrevious() bool {
+	return r.afterPrevious
+}
+
+// Input renders the bytes the rule types.
+func (r ExpectRule) Input(vars map[string]string) string {
+	val := RenderTemplate(r.Send, vars)
+	explicit := strings.ContainsAny(r.Send, "\r\n")
+	if !explicit {
+		val = strings.TrimRight(val, "\r\n")
+	}
+	if r.yesNo {
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true":
+			val = "y"
+		case "false":
+			val = "n"
+		}
+	}
+	if len(r.SendKeys) == 0 {
+		if !explicit {
+			val += "\r"
+		}
+		return val
+	}
+	for _, k := range r.SendKeys {
+		val += Keys[strings.ToLower(strings.TrimSpace(k))]
+	}
+	return val
+}
+
+// Delay returns how long to wait before sending.
+func (r ExpectRule) Delay() time.Duration {
+	if r.DelayMS > 0 {
+		return time.Duration(r.DelayMS) * time.Millisecond
+	}
+	return DefaultExpectDelay
+}
+
 // UnmarshalYAML allows:
 //   - tty: true
 //   - tty: false
@@ -177,11 +430,35 @@ func (l *Loader) Load(data []byte) (Spec, error) {
 	return l.spec, l.err
 }
 
+// LoadSpec parses a spec and expands its `uses:` steps from Libraries.
 func LoadSpec(data []byte) (Spec, error) {
+	spec, err := parseSpec(data)
+	if err != nil {
+		return spec, err
+	}
+	spec.Steps, err = ExpandSteps(spec.Steps)
+	return spec, err
+}
+
+func parseSpec(data []byte) (Spec, error) {
 	var spec Spec
 	if len(data) == 0 {
 		return spec, fmt.Errorf("empty DSL data")
 	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return spec, err
+	}
+	applied, err := MigrateNode(&root)
+	if err != nil {
+		return spec, err
+	}
+	if len(applied) > 0 {
+		// Decode the upgraded document so KnownFields checks it against the latest Spec.
+		if data, err = yaml.Marshal(&root); err != nil {
+			return spec, err
+		}
+	}
 	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
 	decoder.KnownFields(true)
 	if err := decoder.Decode(&spec); err != nil {
@@ -191,7 +468,7 @@ func LoadSpec(data []byte) (Spec, error) {
 }
 
 func RenderTemplate(input string, vars map[string]string) string {
-	out := input
+	out := renderTemplateFuncs(input, vars)
 	for key, val := range vars {
 		out = strings.ReplaceAll(out, key, val)
 	}
@@ -234,6 +511,20 @@ func evalToken(token string, bools map[string]bool) bool {
 	return value
 }
 
+// ConditionVars returns the identifiers referenced by an `if:` expression.
+func ConditionVars(expr string) []string {
+	var out []string
+	for _, or := range strings.Split(expr, "||") {
+		for _, token := range strings.Split(or, "&&") {
+			token = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(token), "!"))
+			if token != "" {
+				out = append(out, token)
+			}
+		}
+	}
+	return out
+}
+
 type Runner struct {
 	Run         func(string) error
 	RunPTY      func(cmd string, onData func([]byte)) (stdin io.WriteCloser, wait func() error, err error)
@@ -244,19 +535,15 @@ type Runner struct {
 
 func RunSteps(r Runner, steps []Step, vars map[string]string, bools map[string]bool) error {
 	for _, step := range steps {
-		hasCondition := strings.TrimSpace(step.If) != ""
-		if r.Conditional && !hasCondition {
-			continue
-		}
-		if !r.Conditional && hasCondition {
+		if r.Conditional != step.SSLPhase() {
 			continue
 		}
-		if hasCondition && !EvaluateCondition(step.If, bools) {
+		if strings.TrimSpace(step.If) != "" && !EvaluateCondition(step.If, bools) {
 			continue
 		}
 
 		if step.Name != "" && r.Log != nil {
-			r.Log("⏳ " + step.Name)
+			r.Log("⏳ " + step.Title())
 		}
 		if step.Log != "" && r.Log != nil {
 			r.Log(RenderTemplate(step.Log, vars))
@@ -301,10 +588,11 @@ func BuildRunCommand(script string) string {
 		return ""
 	}
 	script = "set -e\n" + script
-	return "bash -lc " + shellQuote(script)
+	return "bash -lc " + ShellQuote(script)
 }
 
-func shellQuote(input string) string {
+// ShellQuote quotes input as a single POSIX shell word.
+func ShellQuote(input string) string {
 	return "'" + strings.ReplaceAll(input, "'", `'"'"'`) + "'"
 }
 
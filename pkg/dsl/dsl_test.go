@@ -0,0 +1,67 @@
+package dsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepSSLPhase(t *testing.T) {
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"", false},
+		{"steps.docker_version", false},
+		{"wizard.telemetry", false},
+		{"wizard.dependencies.Redis", false},
+		{"!wizard.telemetry && steps.done", false},
+		{"opts.Domain", false},
+		{"opts.SSL", true},
+		{"!opts.EnableSSL", true},
+		{"opts.SSLPrivateKeyFile || opts.SSLCertificateCrt", true},
+		{"wizard.telemetry && opts.HttpToHttpsRedirection", true},
+	}
+	for _, tt := range tests {
+		if got := (Step{If: tt.cond}).SSLPhase(); got != tt.want {
+			t.Errorf("SSLPhase(%q) = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestRunStepsWizardConditionsRunAtInstall(t *testing.T) {
+	questions := []WizardQuestionSpec{{
+		ID:   "dependencies",
+		Type: "multiselect",
+		Choices: []WizardChoiceSpec{
+			{Name: "Redis"},
+			{Name: "Postgres"},
+		},
+	}}
+	steps := []Step{
+		{Name: "always", Run: "echo always"},
+		{Name: "redis", If: "wizard.dependencies.Redis", Run: "echo redis"},
+		{Name: "postgres", If: "wizard.dependencies.Postgres", Run: "echo postgres"},
+		{Name: "certs", If: "opts.SSL", Run: "echo certs"},
+	}
+	vars, bools := WizardVars(questions, map[string]interface{}{"dependencies": []string{"Redis"}})
+	bools["opts.SSL"] = true
+
+	run := func(conditional bool) []string {
+		var ran []string
+		r := Runner{
+			Run:         func(cmd string) error { ran = append(ran, cmd); return nil },
+			Conditional: conditional,
+		}
+		if err := RunSteps(r, steps, vars, bools); err != nil {
+			t.Fatal(err)
+		}
+		return ran
+	}
+
+	if got, want := run(false), []string{BuildRunCommand("echo always"), BuildRunCommand("echo redis")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("install ran %q, want %q", got, want)
+	}
+	if got, want := run(true), []string{BuildRunCommand("echo certs")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SSL setup ran %q, want %q", got, want)
+	}
+}
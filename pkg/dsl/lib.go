@@ -0,0 +1,293 @@
+package dsl
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Libraries resolves `uses:` references. It is rooted at marketplace/lib and holds
+// <name>/<version>.yaml files (e.g. docker/v1.yaml for `uses: docker@v1`).
+// When nil, specs using `uses:` fail to load.
+var Libraries fs.FS
+
+// StepLibrary is a reusable, parameterized list of steps shared across apps.
+type StepLibrary struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Inputs      []LibraryInput `yaml:"inputs"`
+	Steps       []Step         `yaml:"steps"`
+}
+
+// LibraryInput declares a `with:` input, available to library steps as `{inputs.NAME}`.
+type LibraryInput struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Default     string `yaml:"default"`
+	Required    bool   `yaml:"required"`
+}
+
+var (
+	libRefPart      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]*$`)
+	inputVarPattern = regexp.MustCompile(`\{inputs\.([A-Za-z0-9_\-]+)\}`)
+)
+
+// ParseLibraryRef splits a `uses:` reference such as "docker@v1" into name and version.
+func ParseLibraryRef(ref string) (name, version string, err error) {
+	ref = strings.TrimSpace(ref)
+	name, version, ok := strings.Cut(ref, "@")
+	if !ok || version == "" {
+		return "", "", fmt.Errorf("uses %q: missing @version (e.g. docker@v1)", ref)
+	}
+	if !libRefPart.MatchString(name) || !libRefPart.MatchString(version) || strings.Contains(ref, "..") {
+		return "", "", fmt.Errorf("uses %q: invalid library reference", ref)
+	}
+	return name, version, nil
+}
+
+// LoadLibrary reads and parses a step library from Libraries.
+func LoadLibrary(ref string) (StepLibrary, error) {
+	var lib StepLibrary
+	name, version, err := ParseLibraryRef(ref)
+	if err != nil {
+		return lib, err
+	}
+	if Libraries == nil {
+		return lib, fmt.Errorf("uses %q: step libraries are not available", ref)
+	}
+	path := name + "/" + version + ".yaml"
+	data, err := fs.ReadFile(Libraries, path)
+	if err != nil {
+		return lib, fmt.Errorf("uses %q: read lib/%s: %w", ref, path, err)
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&lib); err != nil {
+		return lib, fmt.Errorf("uses %q: parse lib/%s: %w", ref, path, err)
+	}
+	if lib.Name == "" {
+		lib.Name = name
+	}
+	return lib, nil
+}
+
+// ExpandSteps replaces every `uses:` step with the library's steps, recursively.
+// Expanded steps remember their origin in Step.From for logging.
+func ExpandSteps(steps []Step) ([]Step, error) {
+	return expandSteps(steps, nil)
+}
+
+func expandSteps(steps []Step, stack []string) ([]Step, error) {
+	out := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		if step.Parallel != nil {
+			children, err := expandSteps(step.Parallel.Steps, stack)
+			if err != nil {
+				return nil, err
+			}
+			group := *step.Parallel
+			group.Steps = children
+			step.Parallel = &group
+		}
+		if strings.TrimSpace(step.Uses) == "" {
+			out = append(out, step)
+			continue
+		}
+		expanded, err := expandUses(step, stack)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+func expandUses(step Step, stack []string) ([]Step, error) {
+	ref := strings.TrimSpace(step.Uses)
+	for _, s := range stack {
+		if s == ref {
+			return nil, fmt.Errorf("uses cycle: %s -> %s", strings.Join(stack, " -> "), ref)
+		}
+	}
+	if step.Run != "" || step.TTY.Enabled || step.Compose != nil || step.Parallel != nil {
+		return nil, fmt.Errorf("uses %q: a step with uses cannot also set run, tty, compose or parallel", ref)
+	}
+
+	lib, err := LoadLibrary(ref)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := libraryInputs(ref, lib, step.With)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _, _ := ParseLibraryRef(ref)
+	origin := "lib/" + name
+
+	steps := make([]Step, 0, len(lib.Steps))
+	for _, ls := range lib.Steps {
+		s, err := applyInputs(ref, ls, inputs)
+		if err != nil {
+			return nil, err
+		}
+		// Conditions on the calling step apply to every library step.
+		s.If = joinConditions(step.If, s.If)
+		steps = append(steps, s)
+	}
+
+	steps, err = expandSteps(steps, append(stack, ref))
+	if err != nil {
+		return nil, err
+	}
+	for i := range steps {
+		if steps[i].From == "" {
+			steps[i].From = origin
+		}
+	}
+	return steps, nil
+}
+
+func libraryInputs(ref string, lib StepLibrary, with map[string]string) (map[string]string, error) {
+	declared := make(map[string]bool, len(lib.Inputs))
+	values := make(map[string]string, len(lib.Inputs))
+	for _, in := range lib.Inputs {
+		declared[in.Name] = true
+		v, ok := with[in.Name]
+		if !ok {
+			if in.Required {
+				return nil, fmt.Errorf("uses %q: missing required input %q", ref, in.Name)
+			}
+			v = in.Default
+		}
+		values[in.Name] = v
+	}
+	for key := range with {
+		if !declared[key] {
+			return nil, fmt.Errorf("uses %q: unknown input %q", ref, key)
+		}
+	}
+	return values, nil
+}
+
+// applyInputs renders `{inputs.NAME}` in a library step. Other template variables are left for runtime.
+func applyInputs(ref string, step Step, inputs map[string]string) (Step, error) {
+	var undefined string
+	render := func(s string) string {
+		return inputVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+			key := inputVarPattern.FindStringSubmatch(m)[1]
+			v, ok := inputs[key]
+			if !ok && undefined == "" {
+				undefined = key
+			}
+			return v
+		})
+	}
+
+	step.Name = render(step.Name)
+	step.If = render(step.If)
+	step.Run = render(step.Run)
+	step.Log = render(step.Log)
+	step.Sleep = render(step.Sleep)
+	if len(step.With) > 0 {
+		with := make(map[string]string, len(step.With))
+		for k, v := range step.With {
+			with[k] = render(v)
+		}
+		step.With = with
+	}
+	if step.Compose != nil {
+		c := *step.Compose
+		c.Dir = render(c.Dir)
+		c.File = render(c.File)
+		c.Content = render(c.Content)
+		c.Timeout = render(c.Timeout)
+		if len(c.Env) > 0 {
+			env := make(map[string]string, len(c.Env))
+			for k, v := range c.Env {
+				env[k] = render(v)
+			}
+			c.Env = env
+		}
+		services := make([]string, len(c.WaitHealthy))
+		for i, svc := range c.WaitHealthy {
+			services[i] = render(svc)
+		}
+		c.WaitHealthy = services
+		step.Compose = &c
+	}
+	if step.Parallel != nil {
+		group := *step.Parallel
+		children := make([]Step, len(group.Steps))
+		for i, child := range group.Steps {
+			rendered, err := applyInputs(ref, child, inputs)
+			if err != nil {
+				return step, err
+			}
+			children[i] = rendered
+		}
+		group.Steps = children
+		step.Parallel = &group
+	}
+	if len(step.TTY.AutoAnswer) > 0 {
+		answers := make([]TTYAnswer, len(step.TTY.AutoAnswer))
+		for i, a := range step.TTY.AutoAnswer {
+			a.Value = render(a.Value)
+			a.WaitFor = render(a.WaitFor)
+			answers[i] = a
+		}
+		step.TTY.AutoAnswer = answers
+	}
+	if len(step.TTY.Expect) > 0 {
+		rules := make([]ExpectRule, len(step.TTY.Expect))
+		for i, r := range step.TTY.Expect {
+			r.Pattern = render(r.Pattern)
+			r.Send = render(r.Send)
+			rules[i] = r
+		}
+		step.TTY.Expect = rules
+	}
+	if len(step.TTY.FailOn) > 0 {
+		failOn := make([]ExpectFailure, len(step.TTY.FailOn))
+		for i, f := range step.TTY.FailOn {
+			f.Pattern = render(f.Pattern)
+			f.Message = render(f.Message)
+			failOn[i] = f
+		}
+		step.TTY.FailOn = failOn
+	}
+	step.TTY.Timeout = render(step.TTY.Timeout)
+	step.Register.Name = render(step.Register.Name)
+	step.Register.Regex = render(step.Register.Regex)
+	step.Register.JSON = render(step.Register.JSON)
+
+	if undefined != "" {
+		return step, fmt.Errorf("uses %q: step %q references undefined input %q", ref, step.Name, undefined)
+	}
+	return step, nil
+}
+
+func joinConditions(outer, inner string) string {
+	outer = strings.TrimSpace(outer)
+	inner = strings.TrimSpace(inner)
+	switch {
+	case outer == "":
+		return inner
+	case inner == "":
+		return outer
+	case strings.Contains(outer, "||") || strings.Contains(inner, "||"):
+		// EvaluateCondition has no parentheses; distribute && over the || terms.
+		var terms []string
+		for _, o := range strings.Split(outer, "||") {
+			for _, i := range strings.Split(inner, "||") {
+				terms = append(terms, strings.TrimSpace(o)+" && "+strings.TrimSpace(i))
+			}
+		}
+		return strings.Join(terms, " || ")
+	default:
+		return outer + " && " + inner
+	}
+}
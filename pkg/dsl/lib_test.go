@@ -0,0 +1,66 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyInputsRendersEveryField(t *testing.T) {
+	step := Step{
+		Name: "Install {inputs.name}",
+		Run:  "install {inputs.name}",
+		TTY: TTYSpec{
+			Enabled:    true,
+			Timeout:    "{inputs.timeout}",
+			Expect:     []ExpectRule{{Pattern: "Install {inputs.name}?", Send: "{inputs.answer}"}},
+			FailOn:     []ExpectFailure{{Pattern: "{inputs.name} failed", Message: "{inputs.name} did not install"}},
+			AutoAnswer: []TTYAnswer{{WaitFor: "Port for {inputs.name}", Value: "{inputs.port}"}},
+		},
+		Register: RegisterSpec{Name: "{inputs.name}_version", Regex: "{inputs.name} ([0-9.]+)", JSON: "{inputs.path}"},
+	}
+	inputs := map[string]string{
+		"name":    "redis",
+		"timeout": "10m",
+		"answer":  "y",
+		"port":    "6379",
+		"path":    "version.id",
+	}
+	got, err := applyInputs("redis@v1", step, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]string{
+		"name":                 got.Name,
+		"run":                  got.Run,
+		"tty.timeout":          got.TTY.Timeout,
+		"tty.expect.pattern":   got.TTY.Expect[0].Pattern,
+		"tty.expect.send":      got.TTY.Expect[0].Send,
+		"tty.fail_on.pattern":  got.TTY.FailOn[0].Pattern,
+		"tty.fail_on.message":  got.TTY.FailOn[0].Message,
+		"tty.auto_answer.wait": got.TTY.AutoAnswer[0].WaitFor,
+		"tty.auto_answer.val":  got.TTY.AutoAnswer[0].Value,
+		"register.name":        got.Register.Name,
+		"register.regex":       got.Register.Regex,
+		"register.json":        got.Register.JSON,
+	}
+	for field, v := range fields {
+		if strings.Contains(v, "{inputs.") {
+			t.Errorf("%s not rendered: %q", field, v)
+		}
+	}
+	if got.TTY.Timeout != "10m" || got.Register.Name != "redis_version" || got.TTY.Expect[0].Send != "y" {
+		t.Errorf("unexpected rendering: %+v", got)
+	}
+	// The library's step itself is left untouched.
+	if step.TTY.Expect[0].Pattern != "Install {inputs.name}?" {
+		t.Errorf("applyInputs changed the library step: %q", step.TTY.Expect[0].Pattern)
+	}
+}
+
+func TestApplyInputsUndefined(t *testing.T) {
+	step := Step{Name: "x", TTY: TTYSpec{FailOn: []ExpectFailure{{Pattern: "{inputs.missing}"}}}}
+	if _, err := applyInputs("lib@v1", step, nil); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("err = %v, want undefined input \"missing\"", err)
+	}
+}
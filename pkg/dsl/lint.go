@@ -0,0 +1,890 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateVarPattern matches selfhosted template variables. Shell (`${VAR}`) and Caddy (`{$VAR}`) syntax is not matched.
+// A variable may be piped through one of TemplateFuncs: {wizard.telemetry | yn}.
+var templateVarPattern = regexp.MustCompile(`\{((?:opts|secrets|wizard|steps|answers|version)\.[A-Za-z0-9_.\-]+)(?:\s*\|\s*([A-Za-z]+))?\}`)
+
+var conditionIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// LintOptions describes the environment a spec is validated against.
+type LintOptions struct {
+	// Providers are the known provider names. Nil skips the check.
+	Providers []string
+	// OSImages are the known `os` identifiers (providers.SupportedOS). Nil skips the check.
+	OSImages []string
+	// Vars are template variables always available to steps (e.g. "{opts.Domain}").
+	// Secrets and wizard answers declared by the spec are added automatically.
+	Vars []string
+	// Bools are identifiers usable in `if` conditions (e.g. "opts.SSL").
+	Bools []string
+}
+
+// LintIssue is a single validation problem, located by file, line and step.
+type LintIssue struct {
+	File    string
+	Line    int
+	Step    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	var b strings.Builder
+	b.WriteString(i.File)
+	if i.Line > 0 {
+		fmt.Fprintf(&b, ":%d", i.Line)
+	}
+	b.WriteString(": ")
+	if i.Step != "" {
+		fmt.Fprintf(&b, "step %q: ", i.Step)
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// LintError aggregates every issue found in a spec.
+type LintError struct {
+	Issues []LintIssue
+}
+
+func (e *LintError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, i := range e.Issues {
+		lines = append(lines, i.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Lint parses and validates a spec. It returns the parsed spec and a *LintError when any issue was found.
+// `uses:` steps are checked against Libraries and the returned spec has them expanded.
+func Lint(file string, data []byte, opts LintOptions) (Spec, error) {
+	var root yaml.Node
+	_ = yaml.Unmarshal(data, &root)
+
+	spec, err := parseSpec(data)
+	if err != nil {
+		line := yamlErrorLine(err.Error())
+		var versionErr *UnsupportedAPIVersionError
+		if errors.As(err, &versionErr) {
+			line = lineOf(mappingValue(documentNode(&root), "apiVersion"))
+		}
+		return spec, &LintError{Issues: []LintIssue{{File: file, Line: line, Message: err.Error()}}}
+	}
+
+	l := &linter{file: file, spec: spec, opts: opts, doc: documentNode(&root)}
+	l.run()
+	if len(l.issues) > 0 {
+		return spec, &LintError{Issues: l.issues}
+	}
+	spec.Steps, err = ExpandSteps(spec.Steps)
+	if err != nil {
+		return spec, &LintError{Issues: []LintIssue{{File: file, Message: err.Error()}}}
+	}
+	return spec, nil
+}
+
+// ValidateCondition checks that an `if` expression parses and only references known identifiers.
+// A nil known map skips the identifier check.
+func ValidateCondition(expr string, known map[string]bool) error {
+	if strings.TrimSpace(expr) == "" {
+		return fmt.Errorf("empty condition")
+	}
+	for _, or := range strings.Split(expr, "||") {
+		for _, token := range strings.Split(or, "&&") {
+			token = strings.TrimSpace(token)
+			token = strings.TrimSpace(strings.TrimPrefix(token, "!"))
+			if token == "" {
+				return fmt.Errorf("invalid condition %q: empty operand", expr)
+			}
+			if !conditionIdent.MatchString(token) {
+				return fmt.Errorf("invalid condition %q: unexpected %q", expr, token)
+			}
+			if known != nil && !known[token] {
+				return fmt.Errorf("unknown condition variable %q", token)
+			}
+		}
+	}
+	return nil
+}
+
+type linter struct {
+	file   string
+	spec   Spec
+	opts   LintOptions
+	doc    *yaml.Node
+	issues []LintIssue
+}
+
+func (l *linter) add(line int, step, format string, a ...interface{}) {
+	l.issues = append(l.issues, LintIssue{File: l.file, Line: line, Step: step, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) run() {
+	if strings.TrimSpace(l.spec.App) == "" {
+		l.add(lineOf(l.doc), "", "missing 'app' name")
+	}
+
+	l.lintProviders()
+	l.lintOS()
+	l.lintMetadata()
+	versionVars := l.lintVersions()
+	l.lintWizard()
+	secrets := l.lintSecrets()
+
+	vars := map[string]bool{}
+	for _, v := range l.opts.Vars {
+		vars[v] = true
+	}
+	for name := range secrets {
+		vars["{secrets."+name+"}"] = true
+	}
+	for name := range versionVars {
+		vars["{version."+name+"}"] = true
+	}
+	wizardVars, wizardBools := WizardLintNames(l.spec.Wizard.Steps.Application.CustomQuestions)
+	for _, v := range wizardVars {
+		vars[v] = true
+	}
+
+	var bools map[string]bool
+	if l.opts.Bools != nil {
+		bools = map[string]bool{}
+		for _, b := range l.opts.Bools {
+			bools[b] = true
+		}
+		for _, b := range wizardBools {
+			bools[b] = true
+		}
+	}
+
+	l.lintSteps(vars, bools)
+}
+
+func (l *linter) lintProviders() {
+	if l.opts.Providers == nil {
+		return
+	}
+	known := toSet(l.opts.Providers)
+	node := mappingValue(l.doc, "providers")
+	for i, p := range l.spec.Providers {
+		if !known[p] {
+			l.add(lineOf(seqItem(node, i)), "", "unknown provider %q (known: %s)", p, strings.Join(sortedKeys(known), ", "))
+		}
+	}
+}
+
+func (l *linter) lintOS() {
+	osName := strings.TrimSpace(l.spec.OS)
+	if osName == "" {
+		return
+	}
+	if l.opts.OSImages == nil {
+		return
+	}
+	known := toSet(l.opts.OSImages)
+	if !known[osName] {
+		l.add(lineOf(mappingValue(l.doc, "os")), "", "unknown os %q (known: %s)", osName, strings.Join(sortedKeys(known), ", "))
+	}
+}
+
+// versionVarPattern is the format of version var names, used as {version.NAME}.
+var versionVarPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// lintVersions checks `versions:` and returns the var names every version sets.
+func (l *linter) lintVersions() map[string]bool {
+	node := mappingValue(l.doc, "versions")
+	seen := map[string]bool{}
+	defaults := 0
+	for i, v := range l.spec.Versions {
+		vNode := seqItem(node, i)
+		line := lineOf(vNode)
+		name := strings.TrimSpace(v.Version)
+		switch {
+		case name == "":
+			l.add(line, "", "version has no 'version'")
+		case seen[strings.TrimPrefix(name, "v")]:
+			l.add(line, "", "duplicate version %q", name)
+		case strings.ContainsAny(name, "@ \t"):
+			l.add(line, "", "version %q must not contain spaces or '@'", name)
+		}
+		seen[strings.TrimPrefix(name, "v")] = true
+		if v.Default {
+			defaults++
+			if defaults == 2 {
+				l.add(lineOf(mappingValue(vNode, "default")), "", "only one version can be the default")
+			}
+		}
+		for key := range v.Vars {
+			if !versionVarPattern.MatchString(key) {
+				l.add(lineOf(mappingValue(vNode, "vars")), "", "version %q: invalid var name %q", name, key)
+			}
+		}
+	}
+
+	// Steps can only use vars that every version sets.
+	common := map[string]bool{}
+	for i, v := range l.spec.Versions {
+		if i == 0 {
+			for key := range v.Vars {
+				common[key] = true
+			}
+			continue
+		}
+		for key := range common {
+			if _, ok := v.Vars[key]; !ok {
+				l.add(lineOf(seqItem(node, i)), "", "version %q doesn't set vars.%s (set by %q)", v.Version, key, l.spec.Versions[0].Version)
+				delete(common, key)
+			}
+		}
+		for key := range v.Vars {
+			if !common[key] {
+				if _, ok := l.spec.Versions[0].Vars[key]; !ok {
+					l.add(lineOf(seqItem(node, i)), "", "version %q sets vars.%s, which %q doesn't", v.Version, key, l.spec.Versions[0].Version)
+				}
+			}
+		}
+	}
+	return common
+}
+
+// metadataSlug is the format of categories and tags.
+var metadataSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// IconExtensions are the image types accepted for metadata.icon.
+var IconExtensions = []string{".svg", ".png", ".jpg", ".jpeg", ".webp"}
+
+func (l *linter) lintMetadata() {
+	m := l.spec.Metadata
+	node := mappingValue(l.doc, "metadata")
+	if c := m.Category; c != "" && !metadataSlug.MatchString(c) {
+		l.add(lineOf(mappingValue(node, "category")), "", "metadata.category %q must be lowercase words joined by '-', e.g. session-replay", c)
+	}
+	tagsNode := mappingValue(node, "tags")
+	seen := map[string]bool{}
+	for i, tag := range m.Tags {
+		line := lineOf(seqItem(tagsNode, i))
+		if !metadataSlug.MatchString(tag) {
+			l.add(line, "", "metadata.tags: %q must be lowercase words joined by '-'", tag)
+		}
+		if seen[tag] {
+			l.add(line, "", "metadata.tags: duplicate tag %q", tag)
+		}
+		seen[tag] = true
+	}
+	for _, f := range []struct{ key, value string }{{"homepage", m.Homepage}, {"repository", m.Repository}} {
+		if f.value != "" && !isWebURL(f.value, false) {
+			l.add(lineOf(mappingValue(node, f.key)), "", "metadata.%s %q must be an http or https URL", f.key, f.value)
+		}
+	}
+	if icon := m.Icon; icon != "" {
+		line := lineOf(mappingValue(node, "icon"))
+		switch {
+		case strings.Contains(icon, "://"):
+			if !isWebURL(icon, true) {
+				l.add(line, "", "metadata.icon %q must be an https URL or a relative path", icon)
+			}
+		case strings.HasPrefix(icon, "/") || slices.Contains(strings.Split(icon, "/"), ".."):
+			l.add(line, "", "metadata.icon %q must stay inside the marketplace directory", icon)
+		case !slices.Contains(IconExtensions, strings.ToLower(path.Ext(icon))):
+			l.add(line, "", "metadata.icon %q must be one of: %s", icon, strings.Join(IconExtensions, ", "))
+		}
+	}
+}
+
+// isWebURL reports whether s is an absolute https (or, unless httpsOnly, http) URL with a host.
+func isWebURL(s string, httpsOnly bool) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "https" || (!httpsOnly && u.Scheme == "http")
+}
+
+func (l *linter) lintSecrets() map[string]bool {
+	names := map[string]bool{}
+	node := mappingValue(l.doc, "secrets")
+	for i, s := range l.spec.Secrets {
+		line := lineOf(seqItem(node, i))
+		name := strings.TrimSpace(s.Name)
+		if name == "" {
+			l.add(line, "", "secret has no name")
+			continue
+		}
+		if names[name] {
+			l.add(line, "", "duplicate secret %q", name)
+		}
+		names[name] = true
+		switch strings.ToLower(strings.TrimSpace(s.Encoding)) {
+		case "", "hex", "base64", "base64url":
+		default:
+			l.add(line, "", "secret %q: unknown encoding %q", name, s.Encoding)
+		}
+		switch strings.ToLower(strings.TrimSpace(s.Charset)) {
+		case "", "alnum", "alpha", "numeric", "hex", "symbols":
+		default:
+			l.add(line, "", "secret %q: unknown charset %q", name, s.Charset)
+		}
+		if s.Length < 0 {
+			l.add(line, "", "secret %q: length must be positive", name)
+		}
+	}
+	return names
+}
+
+func (l *linter) lintWizard() map[string]bool {
+	ids := map[string]bool{}
+	node := mappingValue(mappingValue(mappingValue(mappingValue(l.doc, "wizard"), "steps"), "application"), "custom_questions")
+	for i, q := range l.spec.Wizard.Steps.Application.CustomQuestions {
+		qNode := seqItem(node, i)
+		line := lineOf(qNode)
+		id := QuestionID(q)
+		if ids[id] {
+			l.add(line, "", "duplicate wizard question id %q", id)
+		}
+		// show_if and computed defaults may only use answers to earlier questions.
+		l.lintQuestionDynamics(q, id, qNode, ids)
+		ids[id] = true
+
+		qType := strings.ToLower(strings.TrimSpace(q.Type))
+		if qType == "secret" {
+			qType = "password"
+		}
+		defaultLine := lineOf(mappingValue(qNode, "default"))
+		textLike := false
+		switch qType {
+		case "boolean":
+			if q.Default != nil {
+				if _, ok := q.Default.(bool); !ok {
+					l.add(defaultLine, "", "wizard question %q: boolean default must be true or false", id)
+				}
+			}
+		case "text", "password", "email", "url", "domain":
+			textLike = true
+			if q.Default != nil {
+				if _, ok := q.Default.(string); !ok {
+					l.add(defaultLine, "", "wizard question %q: %s default must be a string", id, qType)
+				}
+			}
+		case "number":
+			switch q.Default.(type) {
+			case nil, int, float64:
+			default:
+				l.add(defaultLine, "", "wizard question %q: number default must be a number", id)
+			}
+		case "choice", "select", "multiselect":
+			l.lintChoices(q, id, qType, qNode)
+		case "":
+			l.add(line, "", "wizard question %q has no type", id)
+		default:
+			l.add(lineOf(mappingValue(qNode, "type")), "", "wizard question %q: unknown type %q", id, q.Type)
+		}
+		l.lintQuestionRules(q, id, qType, textLike, qNode)
+	}
+	return ids
+}
+
+func (l *linter) lintSteps(vars, bools map[string]bool) {
+	stepsNode := mappingValue(l.doc, "steps")
+	for i, step := range l.spec.Steps {
+		stepNode := seqItem(stepsNode, i)
+		name := step.Name
+		if name == "" {
+			name = step.Uses
+		}
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if strings.TrimSpace(step.Uses) == "" {
+			l.lintStep(step, name, stepNode, vars, bools)
+			continue
+		}
+		// Library steps have no lines in this file; report them at the calling step.
+		expanded, err := expandUses(step, nil)
+		if err != nil {
+			l.add(lineOf(mappingValue(stepNode, "uses")), name, "%v", err)
+			continue
+		}
+		for _, ls := range expanded {
+			l.lintStep(ls, ls.Title(), stepNode, vars, bools)
+		}
+	}
+}
+
+// lintRegister validates `register:` and makes the value known to the steps that follow.
+func (l *linter) lintRegister(step Step, name string, node *yaml.Node, lineAt func(*yaml.Node) int, vars, bools map[string]bool) {
+	r := step.Register
+	if r.Name == "" && r.Regex == "" && r.JSON == "" {
+		return
+	}
+	regNode := mappingValue(node, "register")
+	if err := r.Validate(); err != nil {
+		l.add(lineAt(regNode), name, "%v", err)
+	}
+	if step.TTY.Enabled || step.Compose != nil || strings.TrimSpace(step.Run) == "" {
+		l.add(lineAt(regNode), name, "register is only supported on run steps without tty")
+	}
+	vars["{steps."+r.Name+"}"] = true
+	if bools != nil {
+		bools["steps."+r.Name] = true
+	}
+}
+
+// lintStep validates a single step. Line numbers come from node; keys missing from node
+// (e.g. for steps expanded from a library) are reported at node's own line.
+func (l *linter) lintStep(step Step, name string, node *yaml.Node, vars, bools map[string]bool) {
+	lineAt := func(n *yaml.Node) int {
+		if n == nil {
+			return lineOf(node)
+		}
+		return n.Line
+	}
+	if strings.TrimSpace(step.If) != "" {
+		if err := ValidateCondition(step.If, bools); err != nil {
+			l.add(lineAt(mappingValue(node, "if")), name, "%v", err)
+		}
+	}
+	if strings.TrimSpace(step.Sleep) != "" {
+		if _, err := ParseDuration(step.Sleep); err != nil {
+			l.add(lineAt(mappingValue(node, "sleep")), name, "%v", err)
+		}
+	}
+	if step.Compose != nil {
+		l.lintCompose(*step.Compose, name, node, lineAt, vars)
+		if strings.TrimSpace(step.Run) != "" || step.TTY.Enabled {
+			l.add(lineAt(mappingValue(node, "compose")), name, "a compose step cannot also set run or tty")
+		}
+	}
+	if step.Parallel != nil {
+		if strings.TrimSpace(step.Run) != "" || step.TTY.Enabled || step.Compose != nil {
+			l.add(lineAt(mappingValue(node, "parallel")), name, "a parallel step cannot also set run, tty or compose")
+		}
+		l.lintParallel(*step.Parallel, name, node, lineAt, vars, bools)
+	}
+	l.lintTemplate(mappingValue(node, "run"), lineOf(node), name, step.Run, vars)
+	l.lintTemplate(mappingValue(node, "log"), lineOf(node), name, step.Log, vars)
+	l.lintExpect(step.TTY, name, mappingValue(node, "tty"), lineAt, vars)
+	answersNode := mappingValue(mappingValue(node, "tty"), "auto_answer")
+	for ai, a := range step.TTY.AutoAnswer {
+		aNode := seqItem(answersNode, ai)
+		l.lintTemplate(mappingValue(aNode, "value"), lineOf(node), name, a.Value, vars)
+		if a.WaitForRegex {
+			if _, err := regexp.Compile(a.WaitFor); err != nil {
+				l.add(lineAt(mappingValue(aNode, "wait_for")), name, "invalid wait_for regex: %v", err)
+			}
+		}
+	}
+	// Registered values are only visible to the steps that follow.
+	l.lintRegister(step, name, node, lineAt, vars, bools)
+}
+
+// maxTTYSize bounds tty rows and cols.
+const maxTTYSize = 1000
+
+// lintExpect validates a TTY step's terminal size, expect rules, fail_on patterns and timeout.
+func (l *linter) lintExpect(tty TTYSpec, name string, ttyNode *yaml.Node, lineAt func(*yaml.Node) int, vars map[string]bool) {
+	if strings.TrimSpace(tty.Timeout) != "" {
+		if _, err := ParseDuration(tty.Timeout); err != nil {
+			l.add(lineAt(mappingValue(ttyNode, "timeout")), name, "tty timeout: %v", err)
+		}
+	}
+	for _, size := range []struct {
+		key string
+		n   int
+	}{{"rows", tty.Rows}, {"cols", tty.Cols}} {
+		if size.n < 0 || size.n > maxTTYSize {
+			l.add(lineAt(mappingValue(ttyNode, size.key)), name, "tty %s must be between 1 and %d", size.key, maxTTYSize)
+		}
+	}
+	rulesNode := mappingValue(ttyNode, "expect")
+	for i, r := range tty.Expect {
+		rNode := seqItem(rulesNode, i)
+		if r.Regex {
+			if _, err := regexp.Compile(r.Pattern); err != nil {
+				l.add(lineAt(mappingValue(rNode, "pattern")), name, "invalid expect pattern: %v", err)
+			}
+		}
+		if r.Pattern == "" && r.Repeat {
+			l.add(lineAt(rNode), name, "expect: a rule without a pattern can't repeat")
+		}
+		for _, k := range r.SendKeys {
+			if _, ok := Keys[strings.ToLower(strings.TrimSpace(k))]; !ok {
+				l.add(lineAt(mappingValue(rNode, "send_keys")), name, "expect: unknown key %q (known: %s)", k, strings.Join(keyNames(), ", "))
+			}
+		}
+		l.lintTemplate(mappingValue(rNode, "send"), lineAt(rNode), name, r.Send, vars)
+	}
+	failNode := mappingValue(ttyNode, "fail_on")
+	for i, f := range tty.FailOn {
+		fNode := seqItem(failNode, i)
+		if strings.TrimSpace(f.Pattern) == "" {
+			l.add(lineAt(fNode), name, "fail_on: missing pattern")
+			continue
+		}
+		if f.Regex {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				l.add(lineAt(mappingValue(fNode, "pattern")), name, "invalid fail_on pattern: %v", err)
+			}
+		}
+	}
+}
+
+func keyNames() []string {
+	names := make([]string, 0, len(Keys))
+	for k := range Keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lintParallel validates a group's children. Children can't see each other's registered values;
+// those become visible to the steps after the group.
+func (l *linter) lintParallel(group ParallelSpec, name string, stepNode *yaml.Node, lineAt func(*yaml.Node) int, vars, bools map[string]bool) {
+	node := mappingValue(stepNode, "parallel")
+	if group.MaxConcurrency < 0 {
+		l.add(lineAt(mappingValue(node, "max_concurrency")), name, "parallel: max_concurrency must be >= 0")
+	}
+	if len(group.Steps) == 0 {
+		l.add(lineAt(node), name, "parallel: no steps")
+	}
+
+	childVars := copySet(vars)
+	childBools := copySet(bools)
+	childrenNode := mappingValue(node, "steps")
+	for i, child := range group.Steps {
+		childNode := seqItem(childrenNode, i)
+		childName := child.Name
+		if childName == "" {
+			childName = child.Uses
+		}
+		if childName == "" {
+			childName = fmt.Sprintf("#%d", i+1)
+		}
+		childName = name + " / " + childName
+
+		children := []Step{child}
+		if strings.TrimSpace(child.Uses) != "" {
+			expanded, err := expandUses(child, nil)
+			if err != nil {
+				l.add(lineAt(mappingValue(childNode, "uses")), childName, "%v", err)
+				continue
+			}
+			children = expanded
+		}
+		for _, c := range children {
+			cName := childName
+			if c.From != "" {
+				cName = name + " / " + c.Title()
+			}
+			if c.TTY.Enabled || c.Compose != nil || c.Parallel != nil {
+				l.add(lineAt(childNode), cName, "parallel children only support run, log, sleep, if and register")
+			}
+			l.lintStep(c, cName, childNode, copySet(childVars), copySet(childBools))
+			if c.Register.Name != "" {
+				vars["{steps."+c.Register.Name+"}"] = true
+				if bools != nil {
+					bools["steps."+c.Register.Name] = true
+				}
+			}
+		}
+	}
+}
+
+func copySet(m map[string]bool) map[string]bool {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]bool, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func (l *linter) lintCompose(c ComposeSpec, name string, stepNode *yaml.Node, lineAt func(*yaml.Node) int, vars map[string]bool) {
+	node := mappingValue(stepNode, "compose")
+	if strings.TrimSpace(c.Dir) == "" {
+		l.add(lineAt(node), name, "compose: dir is required")
+	}
+	if strings.TrimSpace(c.Timeout) != "" {
+		if _, err := ParseDuration(c.Timeout); err != nil {
+			l.add(lineAt(mappingValue(node, "timeout")), name, "compose: %v", err)
+		}
+	}
+	l.lintTemplate(mappingValue(node, "dir"), lineAt(node), name, c.Dir, vars)
+	l.lintTemplate(mappingValue(node, "file"), lineAt(node), name, c.File, vars)
+	l.lintTemplate(mappingValue(node, "content"), lineAt(node), name, c.Content, vars)
+	envNode := mappingValue(node, "env")
+	for _, k := range sortedStringKeys(c.Env) {
+		l.lintTemplate(mappingValue(envNode, k), lineAt(envNode), name, c.Env[k], vars)
+	}
+
+	// When the compose document is inline, wait_healthy services must be defined in it.
+	if strings.TrimSpace(c.Content) == "" || len(c.WaitHealthy) == 0 {
+		return
+	}
+	var doc struct {
+		Services map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(c.Content), &doc); err != nil {
+		l.add(lineAt(mappingValue(node, "content")), name, "compose: invalid content: %v", err)
+		return
+	}
+	waitNode := mappingValue(node, "wait_healthy")
+	for i, svc := range c.WaitHealthy {
+		if _, ok := doc.Services[svc]; !ok {
+			l.add(lineAt(seqItem(waitNode, i)), name, "compose: wait_healthy service %q is not defined in content", svc)
+		}
+	}
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lintTemplate reports undefined template variables, pointing at the line where each one is used.
+// When node is nil (the text isn't in this file), issues are reported at fallbackLine.
+func (l *linter) lintTemplate(node *yaml.Node, fallbackLine int, step, text string, vars map[string]bool) {
+	if text == "" {
+		return
+	}
+	for _, loc := range templateVarPattern.FindAllStringSubmatchIndex(text, -1) {
+		token := "{" + text[loc[2]:loc[3]] + "}"
+		var fn string
+		if loc[4] >= 0 {
+			fn = text[loc[4]:loc[5]]
+		}
+		if vars[token] && (fn == "" || TemplateFuncs[fn] != nil) {
+			continue
+		}
+		line := fallbackLine
+		if node != nil {
+			line = node.Line
+			if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+				// Block scalar content starts on the line after the indicator.
+				line++
+			}
+			line += strings.Count(text[:loc[0]], "\n")
+		}
+		if !vars[token] {
+			l.add(line, step, "undefined template variable %s", token)
+		} else {
+			l.add(line, step, "unknown template function %q in %s (known: %s)", fn, text[loc[0]:loc[1]], strings.Join(templateFuncNames(), ", "))
+		}
+	}
+}
+
+// lintQuestionDynamics checks show_if and templated defaults against deploy options and earlier answers.
+func (l *linter) lintQuestionDynamics(q WizardQuestionSpec, id string, qNode *yaml.Node, earlier map[string]bool) {
+	if strings.TrimSpace(q.ShowIf) != "" {
+		showIfNode := mappingValue(qNode, "show_if")
+		cond, err := ParseCondition(q.ShowIf)
+		if err != nil {
+			l.add(lineOf(showIfNode), "", "wizard question %q: show_if: %v", id, err)
+		}
+		for _, v := range cond.Vars() {
+			switch {
+			case strings.HasPrefix(v, "answers."):
+				ref := strings.TrimPrefix(v, "answers.")
+				if ref == id || !earlier[ref] {
+					l.add(lineOf(showIfNode), "", "wizard question %q: show_if references %s, which is not an earlier question", id, v)
+				}
+			case strings.HasPrefix(v, "opts."):
+				if l.opts.Bools != nil && !slices.Contains(l.opts.Bools, v) {
+					l.add(lineOf(showIfNode), "", "wizard question %q: show_if: unknown option %s", id, v)
+				}
+			default:
+				l.add(lineOf(showIfNode), "", "wizard question %q: show_if: %s must start with answers. or opts.", id, v)
+			}
+		}
+	}
+
+	text, ok := q.Default.(string)
+	if !ok || !strings.Contains(text, "{") {
+		return
+	}
+	vars := map[string]bool{}
+	for _, v := range l.opts.Vars {
+		if strings.HasPrefix(v, "{opts.") {
+			vars[v] = true
+		}
+	}
+	for ref := range earlier {
+		vars["{answers."+ref+"}"] = true
+	}
+	l.lintTemplate(mappingValue(qNode, "default"), lineOf(qNode), "", text, vars)
+}
+
+// lintChoices checks choice, select and multiselect questions.
+func (l *linter) lintChoices(q WizardQuestionSpec, id, qType string, qNode *yaml.Node) {
+	line := lineOf(qNode)
+	if len(q.Choices) == 0 {
+		l.add(line, "", "wizard question %q: %s question has no choices", id, qType)
+	}
+	names := map[string]bool{}
+	on := 0
+	choicesNode := mappingValue(qNode, "choices")
+	for ci, c := range q.Choices {
+		cLine := lineOf(seqItem(choicesNode, ci))
+		if strings.TrimSpace(c.Name) == "" {
+			l.add(cLine, "", "wizard question %q: choice has no name", id)
+		}
+		if names[c.Name] {
+			l.add(cLine, "", "wizard question %q: duplicate choice %q", id, c.Name)
+		}
+		names[c.Name] = true
+		if c.Default != nil {
+			b, ok := c.Default.(bool)
+			if !ok {
+				l.add(cLine, "", "wizard question %q: choice %q default must be true or false", id, c.Name)
+			} else if b {
+				on++
+			}
+		}
+	}
+	if qType == "select" && on > 1 {
+		l.add(line, "", "wizard question %q: select allows a single default choice; use multiselect", id)
+	}
+	if q.Default != nil {
+		defaultLine := lineOf(mappingValue(qNode, "default"))
+		if _, isList := q.Default.([]interface{}); isList && qType == "select" {
+			l.add(defaultLine, "", "wizard question %q: select default must be a single choice", id)
+		}
+		for _, d := range defaultChoiceNames(q.Default) {
+			if !names[d] {
+				l.add(defaultLine, "", "wizard question %q: default %q is not one of its choices", id, d)
+			}
+		}
+	}
+}
+
+// lintQuestionRules checks a question's validation attributes against its type.
+func (l *linter) lintQuestionRules(q WizardQuestionSpec, id, qType string, textLike bool, qNode *yaml.Node) {
+	at := func(key string) int {
+		if n := mappingValue(qNode, key); n != nil {
+			return n.Line
+		}
+		return lineOf(qNode)
+	}
+	if q.Pattern != "" {
+		if !textLike {
+			l.add(at("pattern"), "", "wizard question %q: pattern only applies to text-like questions", id)
+		} else if _, err := regexp.Compile(q.Pattern); err != nil {
+			l.add(at("pattern"), "", "wizard question %q: invalid pattern: %v", id, err)
+		}
+	}
+	if (q.Min != nil || q.Max != nil) && qType != "number" {
+		l.add(at("min"), "", "wizard question %q: min/max only apply to number questions", id)
+	}
+	if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+		l.add(at("min"), "", "wizard question %q: min is greater than max", id)
+	}
+	if q.MinLength != nil || q.MaxLength != nil {
+		if !textLike {
+			l.add(at("min_length"), "", "wizard question %q: min_length/max_length only apply to text-like questions", id)
+		}
+		if q.MinLength != nil && *q.MinLength < 0 || q.MaxLength != nil && *q.MaxLength < 0 {
+			l.add(at("min_length"), "", "wizard question %q: lengths must not be negative", id)
+		}
+		if q.MinLength != nil && q.MaxLength != nil && *q.MinLength > *q.MaxLength {
+			l.add(at("min_length"), "", "wizard question %q: min_length is greater than max_length", id)
+		}
+	}
+	if len(q.AllowedValues) > 0 && !textLike && qType != "number" {
+		l.add(at("allowed_values"), "", "wizard question %q: allowed_values only applies to text-like and number questions; use choices", id)
+	}
+}
+
+func defaultChoiceNames(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, x := range t {
+			out = append(out, fmt.Sprintf("%v", x))
+		}
+		return out
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
+}
+
+func documentNode(root *yaml.Node) *yaml.Node {
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		return root.Content[0]
+	}
+	return root
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func seqItem(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
+
+func lineOf(node *yaml.Node) int {
+	if node == nil {
+		return 0
+	}
+	return node.Line
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+func yamlErrorLine(msg string) int {
+	m := yamlLinePattern.FindStringSubmatch(msg)
+	if m == nil {
+		return 0
+	}
+	var n int
+	fmt.Sscanf(m[1], "%d", &n)
+	return n
+}
+
+func toSet(items []string) map[string]bool {
+	out := make(map[string]bool, len(items))
+	for _, i := range items {
+		out[i] = true
+	}
+	return out
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
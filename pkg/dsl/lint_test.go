@@ -0,0 +1,126 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var lintOptions = LintOptions{
+	Providers: []string{"digitalocean"},
+	OSImages:  []string{"ubuntu-22-04-x64", "ubuntu-24-04-x64"},
+	Vars:      []string{"{opts.Domain}"},
+	Bools:     []string{"opts.SSL"},
+}
+
+func TestLintValidSpec(t *testing.T) {
+	spec, err := Lint("ok.yaml", []byte(`apiVersion: selfhosted/v2
+app: demo
+description: Demo app
+os: ubuntu-24-04-x64
+providers: [digitalocean]
+secrets:
+  - name: db_password
+wizard:
+  steps:
+    application:
+      custom_questions:
+        - name: Mode
+          id: mode
+          type: select
+          choices:
+            - name: fast
+              default: true
+            - name: safe
+steps:
+  - name: Install
+    in: machine
+    if: opts.SSL
+    run: echo {secrets.db_password} {wizard.mode} {opts.Domain}
+`), lintOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.App != "demo" || len(spec.Steps) != 1 {
+		t.Errorf("spec = %+v", spec)
+	}
+}
+
+func TestLintReportsEveryIssue(t *testing.T) {
+	_, err := Lint("bad.yaml", []byte(`apiVersion: selfhosted/v2
+app: demo
+description: Demo app
+os: windows-11
+providers: [nowhere]
+versions:
+  - version: "1.0"
+    default: true
+  - version: "1.0"
+wizard:
+  steps:
+    application:
+      custom_questions:
+        - name: Mode
+          id: mode
+          type: selct
+steps:
+  - name: Install
+    in: machine
+    if: opts.Nope
+    run: echo {wizard.missing} {opts.Domain}
+`), lintOptions)
+	var lerr *LintError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("err = %v, want a *LintError", err)
+	}
+	want := []string{
+		`bad.yaml:5: unknown provider "nowhere"`,
+		`bad.yaml:4: unknown os "windows-11"`,
+		`bad.yaml:9: duplicate version "1.0"`,
+		`bad.yaml:16: wizard question "mode": unknown type "selct"`,
+		`bad.yaml:20: step "Install": unknown condition variable "opts.Nope"`,
+		`bad.yaml:21: step "Install": undefined template variable {wizard.missing}`,
+	}
+	if len(lerr.Issues) != len(want) {
+		t.Fatalf("got %d issues, want %d:\n%v", len(lerr.Issues), len(want), err)
+	}
+	for i, issue := range lerr.Issues {
+		if !strings.HasPrefix(issue.String(), want[i]) {
+			t.Errorf("issue %d = %q, want prefix %q", i, issue.String(), want[i])
+		}
+	}
+}
+
+func TestLintParseErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		data string
+		line int
+	}{
+		"unknown field": {"apiVersion: selfhosted/v2\napp: demo\nstepz: []\n", 3},
+		"newer version": {"app: demo\napiVersion: selfhosted/v9\n", 2},
+	} {
+		_, err := Lint("x.yaml", []byte(tt.data), lintOptions)
+		var lerr *LintError
+		if !errors.As(err, &lerr) || len(lerr.Issues) != 1 {
+			t.Errorf("%s: err = %v, want one issue", name, err)
+			continue
+		}
+		if lerr.Issues[0].Line != tt.line {
+			t.Errorf("%s: line = %d, want %d", name, lerr.Issues[0].Line, tt.line)
+		}
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	known := map[string]bool{"opts.SSL": true, "answers.mode": true}
+	for expr, ok := range map[string]bool{
+		`opts.SSL`:                  true,
+		`!opts.SSL || answers.mode`: true,
+		`opts.Nope`:                 false,
+		`opts.SSL &&`:               false,
+	} {
+		if err := ValidateCondition(expr, known); (err == nil) != ok {
+			t.Errorf("ValidateCondition(%q) = %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package dsl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// API versions of the app DSL. Specs without `apiVersion` are treated as APIVersionV1.
+const (
+	APIVersionV1 = "selfhosted/v1"
+	APIVersionV2 = "selfhosted/v2"
+)
+
+// LatestAPIVersion is the version Spec describes; older specs are migrated to it when loaded.
+const LatestAPIVersion = APIVersionV2
+
+// Migration upgrades a spec document from one API version to the next.
+type Migration struct {
+	From        string
+	To          string
+	Description string
+	// Apply edits the spec's top-level mapping node in place.
+	Apply func(spec *yaml.Node) error
+}
+
+// Migrations is the ordered upgrade chain; each entry's To is the next entry's From
+// and the last entry's To is LatestAPIVersion.
+var Migrations = []Migration{
+	{
+		From:        APIVersionV1,
+		To:          APIVersionV2,
+		Description: "move top-level domain_hint to wizard.domain_hint",
+		Apply:       migrateDomainHint,
+	},
+}
+
+// APIVersions lists every version this build can load, oldest first.
+func APIVersions() []string {
+	versions := []string{APIVersionV1}
+	for _, m := range Migrations {
+		versions = append(versions, m.To)
+	}
+	return versions
+}
+
+var apiVersionPattern = regexp.MustCompile(`^selfhosted/v([0-9]+)$`)
+
+// UnsupportedAPIVersionError is returned for an `apiVersion` this build doesn't know, typically
+// a spec written for a newer selfhosted release.
+type UnsupportedAPIVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedAPIVersionError) Error() string {
+	if m := apiVersionPattern.FindStringSubmatch(e.Version); m != nil {
+		latest := apiVersionPattern.FindStringSubmatch(LatestAPIVersion)
+		n, _ := strconv.Atoi(m[1])
+		max, _ := strconv.Atoi(latest[1])
+		if n > max {
+			return fmt.Sprintf("apiVersion %q is newer than this build supports (latest: %s); upgrade selfhosted to load this app", e.Version, LatestAPIVersion)
+		}
+	}
+	return fmt.Sprintf("unknown apiVersion %q (supported: %s)", e.Version, strings.Join(APIVersions(), ", "))
+}
+
+// MigrateNode upgrades a parsed spec document in place to LatestAPIVersion and sets its `apiVersion`.
+// It returns the migrations that were applied, in order.
+func MigrateNode(root *yaml.Node) ([]Migration, error) {
+	spec := documentNode(root)
+	if spec == nil || spec.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("app spec must be a YAML mapping")
+	}
+
+	version := APIVersionV1
+	if v := mappingValue(spec, "apiVersion"); v != nil {
+		version = strings.TrimSpace(v.Value)
+	}
+	known := false
+	for _, v := range APIVersions() {
+		if v == version {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, &UnsupportedAPIVersionError{Version: version}
+	}
+
+	var applied []Migration
+	for _, m := range Migrations {
+		if m.From != version {
+			continue
+		}
+		if err := m.Apply(spec); err != nil {
+			return applied, fmt.Errorf("migrate %s -> %s: %w", m.From, m.To, err)
+		}
+		applied = append(applied, m)
+		version = m.To
+	}
+	setAPIVersion(spec, version)
+	return applied, nil
+}
+
+// MigrateYAML rewrites a spec file's content to LatestAPIVersion. When no migration is needed
+// (the spec already declares the latest version) data is returned unchanged.
+func MigrateYAML(data []byte) ([]byte, []Migration, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	spec := documentNode(&root)
+	declared := spec != nil && mappingValue(spec, "apiVersion") != nil
+	applied, err := MigrateNode(&root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(applied) == 0 && declared {
+		return data, nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	out := restoreBlankLines(data, buf.Bytes())
+	if _, err := parseSpec(out); err != nil {
+		return nil, nil, fmt.Errorf("migrated spec is invalid: %w", err)
+	}
+	return out, applied, nil
+}
+
+// restoreBlankLines re-adds the blank lines separating sections in orig, which the YAML encoder drops.
+// Lines of out are matched to orig in order; a blank line is emitted before each matched line that
+// had one in orig.
+func restoreBlankLines(orig, out []byte) []byte {
+	origLines := strings.Split(string(orig), "\n")
+	outLines := strings.Split(string(out), "\n")
+
+	var b strings.Builder
+	next := 0
+	prevBlank := true
+	for i, line := range outLines {
+		blank := strings.TrimSpace(line) == ""
+		if !blank {
+			for k := next; k < len(origLines); k++ {
+				if origLines[k] != line {
+					continue
+				}
+				if !prevBlank && k > 0 && strings.TrimSpace(origLines[k-1]) == "" {
+					b.WriteString("\n")
+				}
+				next = k + 1
+				break
+			}
+		}
+		b.WriteString(line)
+		if i < len(outLines)-1 {
+			b.WriteString("\n")
+		}
+		prevBlank = blank
+	}
+	return []byte(b.String())
+}
+
+// setAPIVersion sets `apiVersion`, adding it as the first key when missing.
+func setAPIVersion(spec *yaml.Node, version string) {
+	if v := mappingValue(spec, "apiVersion"); v != nil {
+		v.Kind, v.Tag, v.Value, v.Style = yaml.ScalarNode, "!!str", version, 0
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "apiVersion"}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version}
+	if len(spec.Content) > 0 {
+		// Keep comments at the top of the file (e.g. the yaml-language-server modeline) above apiVersion.
+		key.HeadComment, spec.Content[0].HeadComment = spec.Content[0].HeadComment, ""
+	}
+	spec.Content = append([]*yaml.Node{key, value}, spec.Content...)
+}
+
+// migrateDomainHint moves the v1 top-level domain_hint under wizard unless wizard already sets one.
+func migrateDomainHint(spec *yaml.Node) error {
+	idx := -1
+	for i := 0; i+1 < len(spec.Content); i += 2 {
+		if spec.Content[i].Value == "domain_hint" {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil
+	}
+	hint := spec.Content[idx+1]
+	spec.Content = append(spec.Content[:idx], spec.Content[idx+2:]...)
+
+	wizard := mappingValue(spec, "wizard")
+	if wizard == nil || wizard.Kind != yaml.MappingNode {
+		wizard = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(spec, "wizard", wizard)
+	}
+	if existing := mappingValue(wizard, "domain_hint"); existing != nil && strings.TrimSpace(existing.Value) != "" {
+		return nil
+	}
+	setMappingValue(wizard, "domain_hint", hint)
+	return nil
+}
+
+// setMappingValue replaces key's value in a mapping node, or appends the key.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
@@ -0,0 +1,76 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const v1Spec = `# yaml-language-server: $schema=../schema.json
+app: demo
+description: Demo app
+domain_hint: demo
+
+steps:
+  - name: Install
+    in: machine
+    run: echo installed
+`
+
+func TestMigrateYAML(t *testing.T) {
+	out, applied, err := MigrateYAML([]byte(v1Spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].From != APIVersionV1 || applied[0].To != APIVersionV2 {
+		t.Fatalf("applied = %+v", applied)
+	}
+	text := string(out)
+	if !strings.HasPrefix(text, "# yaml-language-server: $schema=../schema.json\napiVersion: selfhosted/v2\n") {
+		t.Errorf("header comment and apiVersion not first:\n%s", text)
+	}
+	if !strings.Contains(text, "\n\nsteps:") {
+		t.Errorf("blank line before steps dropped:\n%s", text)
+	}
+	spec, err := parseSpec(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Wizard.DomainHint != "demo" {
+		t.Errorf("wizard.domain_hint = %q, want demo", spec.Wizard.DomainHint)
+	}
+
+	// A spec already at the latest version is returned unchanged.
+	again, applied, err := MigrateYAML(out)
+	if err != nil || len(applied) != 0 || string(again) != text {
+		t.Errorf("migrating a v2 spec: applied %v, err %v, changed %v", applied, err, string(again) != text)
+	}
+}
+
+func TestMigrateKeepsWizardDomainHint(t *testing.T) {
+	in := strings.Replace(v1Spec, "\nsteps:", "wizard:\n  domain_hint: kept\n\nsteps:", 1)
+	out, _, err := MigrateYAML([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := parseSpec(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Wizard.DomainHint != "kept" || strings.Contains(string(out), "\ndomain_hint:") {
+		t.Errorf("wizard.domain_hint = %q, out:\n%s", spec.Wizard.DomainHint, out)
+	}
+}
+
+func TestUnsupportedAPIVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"selfhosted/v99": "newer than this build supports",
+		"example/v1":     "unknown apiVersion",
+	} {
+		_, _, err := MigrateYAML([]byte("apiVersion: " + version + "\napp: demo\n"))
+		var verr *UnsupportedAPIVersionError
+		if !errors.As(err, &verr) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", version, err, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RegisterSpec stores a step's trimmed stdout so later steps can use it as `{steps.NAME}`
+// in templates and as `steps.NAME` in `if:` conditions.
+type RegisterSpec struct {
+	Name string `yaml:"name"`
+	// Regex keeps the first capture group (or the whole match) of this regexp.
+	Regex string `yaml:"regex"`
+	// JSON parses stdout as JSON and keeps the value at this dotted path (e.g. data.items.0.id).
+	JSON string `yaml:"json"`
+}
+
+var registerNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// UnmarshalYAML allows:
+//   - register: name
+//   - register:
+//     name: ...
+//     regex: ...
+func (r *RegisterSpec) UnmarshalYAML(node *yaml.Node) error {
+	*r = RegisterSpec{}
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.Name)
+	}
+	type raw RegisterSpec
+	var tmp raw
+	if err := node.Decode(&tmp); err != nil {
+		return err
+	}
+	*r = RegisterSpec(tmp)
+	return nil
+}
+
+// Validate checks the name and extraction options.
+func (r RegisterSpec) Validate() error {
+	if !registerNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("register: invalid name %q (use letters, digits and _)", r.Name)
+	}
+	if r.Regex != "" && r.JSON != "" {
+		return fmt.Errorf("register %q: set either regex or json, not both", r.Name)
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return fmt.Errorf("register %q: invalid regex: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// Extract returns the value to store for a step's stdout.
+func (r RegisterSpec) Extract(stdout string) (string, error) {
+	out := strings.TrimSpace(stdout)
+	switch {
+	case r.Regex != "":
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return "", fmt.Errorf("register %q: invalid regex: %w", r.Name, err)
+		}
+		m := re.FindStringSubmatch(out)
+		if m == nil {
+			return "", fmt.Errorf("register %q: regex %q did not match the output", r.Name, r.Regex)
+		}
+		if len(m) > 1 {
+			return strings.TrimSpace(m[1]), nil
+		}
+		return strings.TrimSpace(m[0]), nil
+	case r.JSON != "":
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(out))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return "", fmt.Errorf("register %q: output is not JSON: %w", r.Name, err)
+		}
+		for _, key := range strings.Split(r.JSON, ".") {
+			switch t := v.(type) {
+			case map[string]interface{}:
+				next, ok := t[key]
+				if !ok {
+					return "", fmt.Errorf("register %q: %s not found in output", r.Name, r.JSON)
+				}
+				v = next
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(t) {
+					return "", fmt.Errorf("register %q: %s not found in output", r.Name, r.JSON)
+				}
+				v = t[i]
+			default:
+				return "", fmt.Errorf("register %q: %s not found in output", r.Name, r.JSON)
+			}
+		}
+		switch t := v.(type) {
+		case string:
+			return t, nil
+		case nil:
+			return "", nil
+		case json.Number:
+			return t.String(), nil
+		case bool:
+			return strconv.FormatBool(t), nil
+		default:
+			b, err := json.Marshal(t)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+	default:
+		return out, nil
+	}
+}
+
+// Truthy reports how a registered value reads in an `if:` condition: empty, "0", "false" and "no" are false.
+func Truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
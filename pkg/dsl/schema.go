@@ -0,0 +1,241 @@
+package dsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the $id of the generated app DSL JSON Schema.
+const SchemaID = "https://selfhosted.dev/schemas/app.json"
+
+// sizePattern accepts the same values as ParseSizeToMB/ParseSizeToGB (e.g. 512mb, 2gib, 80gb, 20).
+const sizePattern = `^\s*[0-9]+\s*([mM][bB]|[gG][bB]|[gG][iI][bB])?\s*$`
+
+// schemaDescriptions documents fields, keyed by "<GoType>.<yaml key>".
+var schemaDescriptions = map[string]string{
+	"Spec.apiVersion":                        "DSL version the spec is written for. Specs without it are treated as selfhosted/v1 and migrated when loaded.",
+	"Spec.app":                               "Unique app identifier used by the CLI and API.",
+	"Spec.description":                       "Human-readable description shown in app lists.",
+	"Spec.metadata":                          "How the app is shown in app lists and catalogs.",
+	"MetadataSpec.display_name":              "Name shown instead of the app identifier.",
+	"MetadataSpec.category":                  "Lowercase category used to group and filter apps, e.g. analytics or session-replay.",
+	"MetadataSpec.tags":                      "Lowercase keywords matched by search.",
+	"MetadataSpec.homepage":                  "Project website (http or https URL).",
+	"MetadataSpec.repository":                "Upstream source repository (http or https URL).",
+	"MetadataSpec.license":                   "SPDX license identifier of the upstream project, e.g. MIT or AGPL-3.0.",
+	"MetadataSpec.icon":                      "Icon file relative to the marketplace root (svg, png, jpg or webp), or an https URL.",
+	"MetadataSpec.version":                   "Upstream version the spec installs, for display.",
+	"Spec.os":                                "Canonical server OS; resolved to each provider's own image. Defaults to ubuntu-22-04-x64.",
+	"Spec.min_spec":                          "Minimum hardware requirements.",
+	"Spec.providers":                         "Cloud providers the app supports.",
+	"Spec.versions":                          "App versions a deploy can pick (e.g. --app umami@2.13); pin image tags and script URLs in their vars.",
+	"VersionSpec.version":                    "Version identifier, e.g. 2.13.2. Requests can name a prefix (2.13 picks the highest 2.13.x).",
+	"VersionSpec.default":                    "Deploy this version when none is requested. Defaults to the latest.",
+	"VersionSpec.vars":                       "Values exposed to steps as {version.NAME}.",
+	"Spec.dns":                               "DNS records to create for the app.",
+	"Spec.secrets":                           "Secrets generated locally once per deployment, available as {secrets.NAME}.",
+	"Spec.wizard":                            "Extra questions shown by the web and TUI wizards.",
+	"Spec.steps":                             "Installation steps, run in order over SSH.",
+	"SpecHW.cpu":                             "Minimum vCPUs.",
+	"SpecHW.ram":                             "Minimum memory, e.g. 2gib or 512mb.",
+	"SpecHW.disk":                            "Minimum disk, e.g. 20gib.",
+	"DNSRecordSpec.type":                     "Record type (A, AAAA, CNAME, ...). Defaults to A.",
+	"DNSRecordSpec.name":                     "Record name; supports {opts.Domain}. A bare label becomes <label>.<domain>.",
+	"DNSRecordSpec.content":                  "Record content; defaults to the server IP for A/AAAA.",
+	"DNSRecordSpec.ttl":                      "TTL in seconds; 0 means provider default.",
+	"DNSRecordSpec.proxied":                  "Cloudflare proxy override; omit to use the deploy default.",
+	"SecretSpec.name":                        "Secret name, referenced as {secrets.NAME}.",
+	"SecretSpec.length":                      "Characters (charset) or random bytes (encoding). Defaults to 32.",
+	"SecretSpec.charset":                     "Characters to pick from when no encoding is set.",
+	"SecretSpec.encoding":                    "Encode random bytes instead of picking characters.",
+	"WizardSpec.domain_hint":                 "Placeholder shown for the domain input.",
+	"WizardQuestionSpec.id":                  "Answer key; defaults to a slug of the name.",
+	"WizardQuestionSpec.type":                "Question type.",
+	"WizardQuestionSpec.default":             "Default answer. String defaults may use {opts.*} and {answers.ID} of earlier questions.",
+	"WizardQuestionSpec.help":                "Help text shown under the question.",
+	"WizardQuestionSpec.placeholder":         "Placeholder shown in empty inputs.",
+	"WizardQuestionSpec.pattern":             "Regular expression text-like answers must match.",
+	"WizardQuestionSpec.min":                 "Smallest allowed number.",
+	"WizardQuestionSpec.max":                 "Largest allowed number.",
+	"WizardQuestionSpec.min_length":          "Minimum answer length in characters.",
+	"WizardQuestionSpec.max_length":          "Maximum answer length in characters.",
+	"WizardQuestionSpec.allowed_values":      "Exact values the answer must be one of (text-like and number questions).",
+	"WizardQuestionSpec.show_if":             "Show the question only when this expression over answers.ID (earlier questions) and opts.* holds, e.g. answers.ssl == \"Custom\".",
+	"Step.name":                              "Shown in deploy logs as the step title.",
+	"Step.in":                                "Where the step runs (machine).",
+	"Step.if":                                "Boolean expression over opts.*, steps.* and wizard.* (wizard.ID, wizard.ID.CHOICE), e.g. opts.SSL && !opts.SSLCertificateCrt. Steps whose condition uses opts.* run during SSL setup.",
+	"Step.run":                               "Bash script; executed with set -e.",
+	"Step.tty":                               "Run in a PTY: true, or an object with auto_answer rules.",
+	"Step.sleep":                             "Wait before running, e.g. 30s, 2m or 10.",
+	"Step.log":                               "Message logged before the step runs.",
+	"Step.uses":                              "Step library from marketplace/lib, e.g. docker@v1. Replaced by the library's steps at load time.",
+	"Step.with":                              "Inputs for uses, available to library steps as {inputs.NAME}.",
+	"Step.register":                          "Store the step's trimmed stdout as {steps.NAME}: a name, or an object with regex/json extraction.",
+	"RegisterSpec.name":                      "Variable name, used as {steps.NAME} and steps.NAME in conditions.",
+	"RegisterSpec.regex":                     "Keep the first capture group (or the whole match) of this regexp.",
+	"RegisterSpec.json":                      "Parse stdout as JSON and keep the value at this dotted path, e.g. data.items.0.id.",
+	"Step.parallel":                          "Run child steps concurrently; the group fails fast when any child fails.",
+	"ParallelSpec.max_concurrency":           "How many children run at once; 0 runs them all.",
+	"ParallelSpec.steps":                     "Child steps (run, log, sleep, if, register). Registered values are visible after the group.",
+	"Step.compose":                           "Start a Docker Compose project and wait for its services.",
+	"ComposeSpec.dir":                        "Project directory on the server; created if missing.",
+	"ComposeSpec.file":                       "Compose file inside dir; defaults to Compose's own lookup (compose.yml + overrides).",
+	"ComposeSpec.content":                    "Compose document written to file (compose.yml by default) before starting.",
+	"ComposeSpec.env":                        "Variables written to dir/.env.",
+	"ComposeSpec.wait_healthy":               "Services that must be healthy (or running, without a healthcheck) before the step succeeds.",
+	"ComposeSpec.timeout":                    "Max wait for wait_healthy, e.g. 5m (default).",
+	"TTYSpec.expect":                         "Rules that answer prompts whenever their pattern shows up on the screen, in any order.",
+	"TTYSpec.fail_on":                        "Patterns that abort the step when they show up on the screen.",
+	"TTYSpec.timeout":                        "Max duration of the whole step, e.g. 30m (no limit by default).",
+	"TTYSpec.term":                           "TERM advertised to the command (default xterm-256color).",
+	"TTYSpec.rows":                           "Initial terminal height (default 40); the web UI resizes the terminal to fit.",
+	"TTYSpec.cols":                           "Initial terminal width (default 120); the web UI resizes the terminal to fit.",
+	"TTYSpec.auto_answer":                    "Original answer list; each entry runs as an expect rule that fires once, after the entry before it. Prefer expect.",
+	"ExpectRule.pattern":                     "Text (or regex) matched against the screen; soft-wrapped rows count as one line. Empty matches right away.",
+	"ExpectRule.regex":                       "Treat pattern as a regular expression.",
+	"ExpectRule.send":                        "Text typed when the pattern shows up; Enter follows unless send_keys is set or it contains \\r or \\n.",
+	"ExpectRule.send_keys":                   "Keys pressed after send: enter, tab, shift-tab, space, backspace, escape, up, down, left, right, ctrl-c, ctrl-d.",
+	"ExpectRule.repeat":                      "Fire again each time the pattern shows up anew (by default a rule fires once).",
+	"ExpectRule.delay_ms":                    "Delay before sending (default 350).",
+	"ExpectFailure.pattern":                  "Text (or regex) that fails the step when it shows up on the screen.",
+	"ExpectFailure.regex":                    "Treat pattern as a regular expression.",
+	"ExpectFailure.message":                  "Error reported when the pattern shows up.",
+	"TTYAnswer.value":                        "Text sent to the PTY; Enter is appended unless it contains \\r or \\n.",
+	"TTYAnswer.wait_for":                     "Wait until the screen contains this text before sending.",
+	"TTYAnswer.wait_for_regex":               "Treat wait_for as a regular expression.",
+	"TTYAnswer.timeout_ms":                   "Ignored; answers wait for their prompt until the step ends. Use tty.timeout.",
+	"TTYAnswer.delay_ms":                     "Delay before sending.",
+	"WizardChoiceSpec.default":               "Whether the choice is selected by default.",
+	"WizardApplicationStep.custom_questions": "Questions shown on the application step.",
+}
+
+// schemaEnums restricts string fields to known values, keyed like schemaDescriptions.
+var schemaEnums = map[string][]string{
+	"Spec.apiVersion":         APIVersions(),
+	"Step.in":                 {"machine"},
+	"WizardQuestionSpec.type": QuestionTypes,
+	"SecretSpec.charset":      {"alnum", "alpha", "numeric", "hex", "symbols"},
+	"SecretSpec.encoding":     {"hex", "base64", "base64url"},
+}
+
+var (
+	sizeMBType   = reflect.TypeOf(SizeMB(0))
+	sizeGBType   = reflect.TypeOf(SizeGB(0))
+	ttySpecType  = reflect.TypeOf(TTYSpec{})
+	registerType = reflect.TypeOf(RegisterSpec{})
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for app YAML files, generated from the Spec types.
+// opts.OSImages, when set, lists the values `os` accepts.
+func JSONSchema(opts LintOptions) map[string]interface{} {
+	g := &schemaGen{defs: map[string]interface{}{}, enums: map[string][]string{}}
+	for key, enum := range schemaEnums {
+		g.enums[key] = enum
+	}
+	if opts.OSImages != nil {
+		g.enums["Spec.os"] = opts.OSImages
+	}
+	root := g.structSchema(reflect.TypeOf(Spec{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "selfhosted app"
+	root["required"] = []string{"app", "steps"}
+	root["$defs"] = g.defs
+	return root
+}
+
+// JSONSchemaBytes returns the schema as indented JSON.
+func JSONSchemaBytes(opts LintOptions) ([]byte, error) {
+	return json.MarshalIndent(JSONSchema(opts), "", "  ")
+}
+
+type schemaGen struct {
+	defs  map[string]interface{}
+	enums map[string][]string
+}
+
+func (g *schemaGen) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case sizeMBType, sizeGBType:
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "integer", "minimum": 0},
+				map[string]interface{}{"type": "string", "pattern": sizePattern},
+			},
+		}
+	case ttySpecType:
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "boolean"},
+				g.ref(t),
+			},
+		}
+	case registerType:
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				g.ref(t),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	default:
+		// interface{}: any YAML value.
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGen) ref(t reflect.Type) map[string]interface{} {
+	if _, ok := g.defs[t.Name()]; !ok {
+		g.defs[t.Name()] = nil // reserve to stop recursion
+		g.defs[t.Name()] = g.structSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" || name == "" {
+			continue
+		}
+		ps := g.typeSchema(f.Type)
+		key := t.Name() + "." + name
+		if desc, ok := schemaDescriptions[key]; ok {
+			ps["description"] = desc
+		}
+		if enum, ok := g.enums[key]; ok {
+			ps["enum"] = enum
+		}
+		props[name] = ps
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,126 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionSpec is an app version a deploy can pick. Its vars pin what the steps install.
+type VersionSpec struct {
+	Version string `yaml:"version"`
+	// Default marks the version deployed when none is requested; without one, the latest is.
+	Default bool `yaml:"default"`
+	// Vars are exposed to steps as {version.NAME}, e.g. image tags and script URLs.
+	Vars map[string]string `yaml:"vars"`
+}
+
+// DefaultVersion returns the version marked default, else the latest. It is nil without versions.
+func DefaultVersion(versions []VersionSpec) *VersionSpec {
+	for i := range versions {
+		if versions[i].Default {
+			return &versions[i]
+		}
+	}
+	return LatestVersion(versions)
+}
+
+// LatestVersion returns the highest version (see CompareVersions). It is nil without versions.
+func LatestVersion(versions []VersionSpec) *VersionSpec {
+	var latest *VersionSpec
+	for i := range versions {
+		if latest == nil || CompareVersions(versions[i].Version, latest.Version) > 0 {
+			latest = &versions[i]
+		}
+	}
+	return latest
+}
+
+// FindVersion resolves a requested version: an exact match, else the highest version it is a
+// prefix of ("2.13" picks 2.13.2 over 2.13.1, but not 2.130). An empty request picks the default.
+func FindVersion(versions []VersionSpec, requested string) (*VersionSpec, error) {
+	requested = strings.TrimPrefix(strings.TrimSpace(requested), "v")
+	if len(versions) == 0 {
+		if requested != "" {
+			return nil, fmt.Errorf("no versions to choose from")
+		}
+		return nil, nil
+	}
+	if requested == "" {
+		return DefaultVersion(versions), nil
+	}
+	var match *VersionSpec
+	for i := range versions {
+		v := strings.TrimPrefix(versions[i].Version, "v")
+		if v == requested {
+			return &versions[i], nil
+		}
+		if strings.HasPrefix(v, requested+".") && (match == nil || CompareVersions(v, match.Version) > 0) {
+			match = &versions[i]
+		}
+	}
+	if match == nil {
+		names := make([]string, 0, len(versions))
+		for _, v := range versions {
+			names = append(names, v.Version)
+		}
+		return nil, fmt.Errorf("unknown version %q (available: %s)", requested, strings.Join(names, ", "))
+	}
+	return match, nil
+}
+
+// CompareVersions compares dotted versions part by part, numerically where both parts are numbers
+// (2.10 > 2.9), and returns -1, 0 or 1. A leading "v" is ignored, and a pre-release suffix
+// ("2.0.0-rc1") sorts before the release.
+func CompareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	aMain, aPre, _ := strings.Cut(a, "-")
+	bMain, bPre, _ := strings.Cut(b, "-")
+	ap, bp := strings.Split(aMain, "."), strings.Split(bMain, ".")
+	for i := 0; i < len(ap) || i < len(bp); i++ {
+		var x, y string
+		if i < len(ap) {
+			x = ap[i]
+		}
+		if i < len(bp) {
+			y = bp[i]
+		}
+		if c := comparePart(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func comparePart(x, y string) int {
+	xn, xErr := strconv.Atoi(orZero(x))
+	yn, yErr := strconv.Atoi(orZero(y))
+	if xErr == nil && yErr == nil {
+		switch {
+		case xn < yn:
+			return -1
+		case xn > yn:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(x, y)
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// legacyWizardPrefix is the original, flattened key for wizard answers. It renders booleans as y/n
+// and lists joined with commas, and is kept so existing specs render the same.
+const legacyWizardPrefix = "wizard.steps.application.values.custom_questions."
+
+// TemplateFuncs format a variable in templates, written as {VAR | NAME}, e.g. {wizard.telemetry | yn}.
+var TemplateFuncs = map[string]func(value string) string{
+	// yn renders a truthy value as y, anything else as n.
+	"yn": func(v string) string {
+		if Truthy(v) {
+			return "y"
+		}
+		return "n"
+	},
+	// bool renders a truthy value as true, anything else as false.
+	"bool": func(v string) string { return strconv.FormatBool(Truthy(v)) },
+	// words shell-quotes each item of a comma-separated list, for `for x in {wizard.ID | words}; do`.
+	"words": func(v string) string {
+		var words []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				words = append(words, ShellQuote(item))
+			}
+		}
+		return strings.Join(words, " ")
+	},
+	// quote shell-quotes the whole value.
+	"quote": ShellQuote,
+}
+
+func templateFuncNames() []string {
+	names := make([]string, 0, len(TemplateFuncs))
+	for name := range TemplateFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateFuncPattern matches a variable piped through one of TemplateFuncs.
+var templateFuncPattern = regexp.MustCompile(`\{([A-Za-z_]+\.[A-Za-z0-9_.\-]+)\s*\|\s*([A-Za-z]+)\}`)
+
+func renderTemplateFuncs(input string, vars map[string]string) string {
+	if !strings.Contains(input, "|") {
+		return input
+	}
+	return templateFuncPattern.ReplaceAllStringFunc(input, func(match string) string {
+		m := templateFuncPattern.FindStringSubmatch(match)
+		value, ok := vars["{"+m[1]+"}"]
+		fn := TemplateFuncs[m[2]]
+		if !ok || fn == nil {
+			return match
+		}
+		return fn(value)
+	})
+}
+
+// WizardVars exposes wizard answers to steps. Template variables:
+//
+//	{wizard.ID}   the answer; booleans as true/false, numbers as written, lists joined with commas
+//	{wizard.steps.application.values.custom_questions.ID}   the original form; booleans as y/n
+//
+// Condition identifiers: wizard.ID is the answer's truthiness, and wizard.ID.CHOICE is true when
+// CHOICE is selected in a select, multiselect or choice question. Every question gets variables,
+// unanswered (or hidden) ones are empty; answers without a question are included as well.
+func WizardVars(questions []WizardQuestionSpec, answers map[string]interface{}) (map[string]string, map[string]bool) {
+	vars := map[string]string{}
+	bools := map[string]bool{}
+	add := func(id string, v interface{}) {
+		vars["{wizard."+id+"}"] = wizardValueString(v, false)
+		vars["{"+legacyWizardPrefix+id+"}"] = wizardValueString(v, true)
+		bools["wizard."+id] = ValueTruthy(v)
+		for _, choice := range selectedChoices(v) {
+			bools["wizard."+id+"."+choice] = true
+		}
+	}
+	for _, q := range questions {
+		id := QuestionID(q)
+		add(id, answers[id])
+	}
+	for id, v := range answers {
+		if _, ok := vars["{wizard."+id+"}"]; !ok {
+			add(id, v)
+		}
+	}
+	return vars, bools
+}
+
+// WizardLintNames returns the template variables and condition identifiers WizardVars provides for questions.
+func WizardLintNames(questions []WizardQuestionSpec) (vars, bools []string) {
+	for _, q := range questions {
+		id := QuestionID(q)
+		vars = append(vars, "{wizard."+id+"}", "{"+legacyWizardPrefix+id+"}")
+		bools = append(bools, "wizard."+id)
+		for _, c := range q.Choices {
+			bools = append(bools, "wizard."+id+"."+c.Name)
+		}
+	}
+	return vars, bools
+}
+
+func wizardValueString(v interface{}, legacy bool) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if legacy {
+			if t {
+				return "y"
+			}
+			return "n"
+		}
+		return strconv.FormatBool(t)
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(t)
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, x := range t {
+			parts = append(parts, wizardValueString(x, legacy))
+		}
+		return strings.Join(parts, ",")
+	case []string:
+		return strings.Join(t, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// selectedChoices returns the choice names an answer selects: the items of a list, or a single string.
+func selectedChoices(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		if t != "" {
+			return []string{t}
+		}
+	case []string:
+		return t
+	case []interface{}:
+		var out []string
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -35,11 +35,34 @@ func sanitizeHostname(s string) string {
 	return out
 }
 
+// SizeMeetsSpecs reports whether a size satisfies CPU, memory and disk minimums.
+// Disk is compared only when both the spec and the size declare it; a size with DiskGB == 0
+// has no fixed disk (block volumes are attached separately), so it can't be ruled out.
+func SizeMeetsSpecs(size Size, specs Specs) bool {
+	if size.VCPUs < specs.CPUs || size.MemoryMB < specs.MemoryMB {
+		return false
+	}
+	if specs.DiskGB > 0 && size.DiskGB > 0 && size.DiskGB < specs.DiskGB {
+		return false
+	}
+	return true
+}
+
+// FilterSizesForSpecs returns the sizes that satisfy specs, preserving order.
+func FilterSizesForSpecs(sizes []Size, specs Specs) []Size {
+	out := make([]Size, 0, len(sizes))
+	for _, s := range sizes {
+		if SizeMeetsSpecs(s, specs) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 // pickBestSizeForSpecs returns the "best" Size that satisfies the requested specs.
 //
 // Matching:
-// - CPUs and MemoryMB are required comparisons
-// - DiskGB is compared only if specs.DiskGB > 0
+// - see SizeMeetsSpecs
 //
 // Ranking:
 // - Prefer sizes with a known (non-zero) monthly price, if any exist
@@ -58,10 +81,7 @@ func pickBestSizeForSpecs(sizes []Size, specs Specs) (*Size, bool) {
 
 	for i := range sizes {
 		s := &sizes[i]
-		if s.VCPUs < specs.CPUs || s.MemoryMB < specs.MemoryMB {
-			continue
-		}
-		if specs.DiskGB > 0 && s.DiskGB < specs.DiskGB {
+		if !SizeMeetsSpecs(*s, specs) {
 			continue
 		}
 
@@ -152,6 +152,22 @@ func (d *DigitalOcean) GetSizeForSpecs(specs Specs) (string, error) {
 	return best.Slug, nil
 }
 
+// doImages maps canonical OS identifiers to DigitalOcean distribution image slugs.
+// Distribution images are available in every region.
+var doImages = map[string]string{
+	OSUbuntu2204: "ubuntu-22-04-x64",
+	OSUbuntu2404: "ubuntu-24-04-x64",
+	OSDebian12:   "debian-12-x64",
+}
+
+func (d *DigitalOcean) ResolveImage(osImage OSImage, region string) (string, error) {
+	slug, ok := doImages[osImage.ID]
+	if !ok {
+		return "", &OSUnavailableError{Provider: d.Name(), OS: osImage, Region: region}
+	}
+	return slug, nil
+}
+
 func (d *DigitalOcean) CreateServer(config *DeployConfig) (*Server, error) {
 	if err := d.ensureClient(); err != nil {
 		return nil, err
@@ -163,7 +179,7 @@ func (d *DigitalOcean) CreateServer(config *DeployConfig) (*Server, error) {
 		profile = "basic"
 	}
 
-	moduleDir, err := terraform.FindModuleDir("digitalocean", profile)
+	module, err := terraform.FindModule("digitalocean", profile)
 	if err != nil {
 		return nil, err
 	}
@@ -173,9 +189,9 @@ func (d *DigitalOcean) CreateServer(config *DeployConfig) (*Server, error) {
 		return nil, fmt.Errorf("DIGITALOCEAN_TOKEN or DO_TOKEN environment variable required")
 	}
 
-	image := config.Image
-	if image == "" {
-		image = "ubuntu-22-04-x64"
+	image, err := ResolveImage(d, config.Image, config.Region)
+	if err != nil {
+		return nil, err
 	}
 
 	fingerprint, err := sshPublicKeyFingerprint(config.SSHPublicKey)
@@ -205,7 +221,7 @@ func (d *DigitalOcean) CreateServer(config *DeployConfig) (*Server, error) {
 	}
 
 	runID := fmt.Sprintf("%s-%d", config.Name, time.Now().Unix())
-	result, err := terraform.Apply(d.ctx, moduleDir, runID, env, vars)
+	result, err := terraform.Apply(d.ctx, module, runID, env, vars)
 	if err != nil {
 		return nil, err
 	}
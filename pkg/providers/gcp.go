@@ -310,12 +310,13 @@ func (g *GCP) ListRegions() ([]Region, error) {
 
 func (g *GCP) ListSizes() ([]Size, error) {
 	// A small, safe subset (costs vary per region; we keep price 0 for now).
+	// DiskGB is the boot disk created by the terraform module (disk_size_gb default).
 	return []Size{
-		{Slug: "e2-medium", VCPUs: 2, MemoryMB: 4096, DiskGB: 10},
-		{Slug: "e2-standard-2", VCPUs: 2, MemoryMB: 8192, DiskGB: 10},
-		{Slug: "e2-standard-4", VCPUs: 4, MemoryMB: 16384, DiskGB: 10},
-		{Slug: "n2-standard-2", VCPUs: 2, MemoryMB: 8192, DiskGB: 10},
-		{Slug: "n2-standard-4", VCPUs: 4, MemoryMB: 16384, DiskGB: 10},
+		{Slug: "e2-medium", VCPUs: 2, MemoryMB: 4096, DiskGB: 25},
+		{Slug: "e2-standard-2", VCPUs: 2, MemoryMB: 8192, DiskGB: 25},
+		{Slug: "e2-standard-4", VCPUs: 4, MemoryMB: 16384, DiskGB: 25},
+		{Slug: "n2-standard-2", VCPUs: 2, MemoryMB: 8192, DiskGB: 25},
+		{Slug: "n2-standard-4", VCPUs: 4, MemoryMB: 16384, DiskGB: 25},
 	}, nil
 }
 
@@ -328,6 +329,21 @@ func (g *GCP) GetSizeForSpecs(specs Specs) (string, error) {
 	return best.Slug, nil
 }
 
+// gcpImages maps canonical OS identifiers to public GCE image families (global, so available in every zone).
+var gcpImages = map[string]string{
+	OSUbuntu2204: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+	OSUbuntu2404: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2404-lts-amd64",
+	OSDebian12:   "projects/debian-cloud/global/images/family/debian-12",
+}
+
+func (g *GCP) ResolveImage(osImage OSImage, region string) (string, error) {
+	family, ok := gcpImages[osImage.ID]
+	if !ok {
+		return "", &OSUnavailableError{Provider: g.Name(), OS: osImage, Region: region}
+	}
+	return family, nil
+}
+
 func (g *GCP) CreateServer(config *DeployConfig) (*Server, error) {
 	ts, method, err := g.ResolveAuth()
 	if err != nil {
@@ -411,7 +427,7 @@ func (g *GCP) createServerWithTerraform(config *DeployConfig, projectID, zone, m
 		profile = "basic"
 	}
 
-	moduleDir, err := terraform.FindModuleDir("gcp", profile)
+	module, err := terraform.FindModule("gcp", profile)
 	if err != nil {
 		return nil, err
 	}
@@ -430,9 +446,9 @@ func (g *GCP) createServerWithTerraform(config *DeployConfig, projectID, zone, m
 		instName = instName[:55]
 	}
 
-	image := config.Image
-	if image == "" {
-		image = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts"
+	image, err := ResolveImage(g, config.Image, zone)
+	if err != nil {
+		return nil, err
 	}
 
 	vars := map[string]interface{}{
@@ -446,7 +462,7 @@ func (g *GCP) createServerWithTerraform(config *DeployConfig, projectID, zone, m
 	}
 
 	runID := fmt.Sprintf("%s-%d", instName, time.Now().Unix())
-	result, err := terraform.Apply(g.ctx, moduleDir, runID, env, vars)
+	result, err := terraform.Apply(g.ctx, module, runID, env, vars)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Canonical OS identifiers apps can request via the DSL `os` field.
+const (
+	OSUbuntu2204 = "ubuntu-22-04-x64"
+	OSUbuntu2404 = "ubuntu-24-04-x64"
+	OSDebian12   = "debian-12-x64"
+)
+
+// DefaultOS is used when neither the app nor the deploy config asks for an OS.
+const DefaultOS = OSUbuntu2204
+
+// OSImage describes a canonical OS independently of any provider.
+type OSImage struct {
+	ID       string // Canonical identifier, e.g. ubuntu-24-04-x64
+	Distro   string // Lowercase distribution name, e.g. ubuntu
+	Version  string // Release version, e.g. 24.04
+	Codename string // Release codename, e.g. noble
+}
+
+// Title returns a human-readable name, e.g. "Ubuntu 24.04".
+func (o OSImage) Title() string {
+	if o.Distro == "" {
+		return o.ID
+	}
+	return strings.ToUpper(o.Distro[:1]) + o.Distro[1:] + " " + o.Version
+}
+
+// Matches reports whether a provider image name (e.g. "Ubuntu Server 24.04 LTS", "debian_bookworm")
+// refers to this OS. The version must appear as a whole number so "12" doesn't match "1.12" or "120".
+func (o OSImage) Matches(name string) bool {
+	name = strings.ToLower(name)
+	if !strings.Contains(name, o.Distro) {
+		return false
+	}
+	if o.Codename != "" && strings.Contains(name, o.Codename) {
+		return true
+	}
+	re := regexp.MustCompile(`(^|[^0-9.])` + regexp.QuoteMeta(o.Version) + `([^0-9]|$)`)
+	return re.MatchString(name)
+}
+
+// OSImages lists the canonical OS identifiers every provider knows how to resolve.
+var OSImages = []OSImage{
+	{ID: OSUbuntu2204, Distro: "ubuntu", Version: "22.04", Codename: "jammy"},
+	{ID: OSUbuntu2404, Distro: "ubuntu", Version: "24.04", Codename: "noble"},
+	{ID: OSDebian12, Distro: "debian", Version: "12", Codename: "bookworm"},
+}
+
+// SupportedOS returns the canonical OS identifiers.
+func SupportedOS() []string {
+	ids := make([]string, 0, len(OSImages))
+	for _, o := range OSImages {
+		ids = append(ids, o.ID)
+	}
+	return ids
+}
+
+// LookupOS finds a canonical OS by identifier.
+func LookupOS(id string) (OSImage, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	for _, o := range OSImages {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return OSImage{}, false
+}
+
+// OSUnavailableError is returned when a provider (or one of its regions) doesn't offer the requested OS.
+type OSUnavailableError struct {
+	Provider string
+	OS       OSImage
+	Region   string
+}
+
+func (e *OSUnavailableError) Error() string {
+	if e.Region == "" {
+		return fmt.Sprintf("%s: %s (%s) is not available", e.Provider, e.OS.Title(), e.OS.ID)
+	}
+	return fmt.Sprintf("%s: %s (%s) is not available in region %s", e.Provider, e.OS.Title(), e.OS.ID, e.Region)
+}
+
+// ResolveImage turns DeployConfig.Image into the provider's own image reference for region.
+// An empty image means DefaultOS, canonical identifiers are resolved by the provider and
+// anything else is passed through as a provider-specific image.
+func ResolveImage(p Provider, image, region string) (string, error) {
+	image = strings.TrimSpace(image)
+	if image == "" {
+		image = DefaultOS
+	}
+	osImage, ok := LookupOS(image)
+	if !ok {
+		return image, nil
+	}
+	return p.ResolveImage(osImage, region)
+}
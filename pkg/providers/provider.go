@@ -24,6 +24,10 @@ type Provider interface {
 	// GetSizeForSpecs finds a size matching minimum specs
 	GetSizeForSpecs(specs Specs) (string, error)
 
+	// ResolveImage maps a canonical OS to the provider's image in region.
+	// It returns an *OSUnavailableError when the provider/region doesn't offer it.
+	ResolveImage(os OSImage, region string) (string, error)
+
 	// CreateServer creates a new server
 	CreateServer(config *DeployConfig) (*Server, error)
 
@@ -76,7 +80,7 @@ type DeployConfig struct {
 	Name          string
 	Region        string
 	Size          string
-	Image         string // OS image, defaults to Ubuntu 22.04
+	Image         string // Canonical OS (see OSImages) or provider-specific image, defaults to DefaultOS
 	SSHPublicKey  string
 	SSHPrivateKey string
 	Domain        string
@@ -99,3 +103,17 @@ func Get(name string) (Provider, error) {
 	}
 	return p, nil
 }
+
+// ListSizesForRegion lists sizes available in region when the provider supports
+// region/zone-specific sizes, and falls back to ListSizes otherwise.
+func ListSizesForRegion(p Provider, region string) ([]Size, error) {
+	type sizesByRegion interface {
+		ListSizesForRegion(region string) ([]Size, error)
+	}
+	if region != "" {
+		if sp, ok := p.(sizesByRegion); ok {
+			return sp.ListSizesForRegion(region)
+		}
+	}
+	return p.ListSizes()
+}
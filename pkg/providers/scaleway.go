@@ -464,8 +464,7 @@ func (s *Scaleway) GetSizeForSpecs(specs Specs) (string, error) {
 }
 
 func (s *Scaleway) CreateServer(config *DeployConfig) (*Server, error) {
-	api, err := s.ensureAPI()
-	if err != nil {
+	if _, err := s.ensureAPI(); err != nil {
 		return nil, err
 	}
 
@@ -478,13 +477,9 @@ func (s *Scaleway) CreateServer(config *DeployConfig) (*Server, error) {
 		return nil, fmt.Errorf("SCW_DEFAULT_PROJECT_ID (project_id) is required to create servers")
 	}
 
-	// Find image if not provided
-	image := config.Image
-	if strings.TrimSpace(image) == "" {
-		image, err = s.findUbuntuImageLabelOrID(api, zone)
-		if err != nil {
-			return nil, err
-		}
+	image, err := ResolveImage(s, config.Image, string(zone))
+	if err != nil {
+		return nil, err
 	}
 
 	// Use Terraform to create the instance
@@ -520,58 +515,50 @@ func (s *Scaleway) SetupDNS(domain, ip string) error {
 	return fmt.Errorf("scaleway DNS is not supported in this installer yet; please create an A record for %s -> %s at your DNS provider", domain, ip)
 }
 
-func (s *Scaleway) findUbuntuImageLabelOrID(api *instance.API, zone scw.Zone) (string, error) {
-	// Prefer a public Ubuntu 24.04 image when available; fall back to 22.04.
+// scwImageLabels maps canonical OS identifiers to Scaleway marketplace image labels.
+var scwImageLabels = map[string]string{
+	OSUbuntu2204: "ubuntu_jammy",
+	OSUbuntu2404: "ubuntu_noble",
+	OSDebian12:   "debian_bookworm",
+}
+
+func (s *Scaleway) ResolveImage(osImage OSImage, region string) (string, error) {
+	api, err := s.ensureAPI()
+	if err != nil {
+		return "", err
+	}
+	zone := scw.Zone(region)
+	if strings.TrimSpace(region) == "" {
+		zone = s.zone
+	}
+	return s.findImageLabelOrID(api, zone, osImage)
+}
+
+func (s *Scaleway) findImageLabelOrID(api *instance.API, zone scw.Zone, osImage OSImage) (string, error) {
 	public := true
 	perPage := uint32(100)
 
 	resp, err := api.ListImages(&instance.ListImagesRequest{
 		Zone:    zone,
 		Public:  &public,
-		Name:    scw.StringPtr("Ubuntu"),
+		Name:    scw.StringPtr(osImage.Distro),
 		PerPage: &perPage,
 	})
 	if err != nil {
 		return "", fmt.Errorf("scaleway: list images: %w", err)
 	}
 
-	// Try to derive image label from name, or use ID with zone prefix
-	bestLabel := ""
-	bestID := ""
 	for _, img := range resp.Images {
-		if img == nil {
+		if img == nil || !osImage.Matches(img.Name) {
 			continue
 		}
-		name := strings.ToLower(img.Name)
-		if strings.Contains(name, "24.04") || strings.Contains(name, "noble") {
-			// Try to derive label from name (e.g., "Ubuntu 24.04" -> "ubuntu_noble")
-			if strings.Contains(name, "noble") {
-				bestLabel = "ubuntu_noble"
-				break
-			}
-			if bestID == "" {
-				// Use zone/id format for Terraform
-				bestID = fmt.Sprintf("%s/%s", zone, img.ID)
-			}
+		// Prefer the marketplace label for Terraform compatibility; fall back to zone/id.
+		if label, ok := scwImageLabels[osImage.ID]; ok {
+			return label, nil
 		}
-		if bestLabel == "" && bestID == "" && (strings.Contains(name, "22.04") || strings.Contains(name, "jammy")) {
-			if strings.Contains(name, "jammy") {
-				bestLabel = "ubuntu_jammy"
-			} else if bestID == "" {
-				// Use zone/id format for Terraform
-				bestID = fmt.Sprintf("%s/%s", zone, img.ID)
-			}
-		}
-	}
-
-	// Prefer label over ID for Terraform compatibility
-	if bestLabel != "" {
-		return bestLabel, nil
-	}
-	if bestID != "" {
-		return bestID, nil
+		return fmt.Sprintf("%s/%s", zone, img.ID), nil
 	}
-	return "", fmt.Errorf("scaleway: could not find a public Ubuntu image in zone %s; set provider image explicitly", zone)
+	return "", &OSUnavailableError{Provider: s.Name(), OS: osImage, Region: string(zone)}
 }
 
 func humanZoneName(zone string) string {
@@ -601,7 +588,7 @@ func (s *Scaleway) createServerWithTerraform(config *DeployConfig, zone, imageID
 		profile = "basic"
 	}
 
-	moduleDir, err := terraform.FindModuleDir("scaleway", profile)
+	module, err := terraform.FindModule("scaleway", profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find terraform module for scaleway/%s: %w", profile, err)
 	}
@@ -627,7 +614,7 @@ func (s *Scaleway) createServerWithTerraform(config *DeployConfig, zone, imageID
 	}
 
 	runID := fmt.Sprintf("%s-%d", config.Name, time.Now().Unix())
-	result, err := terraform.Apply(s.ctx, moduleDir, runID, env, vars)
+	result, err := terraform.Apply(s.ctx, module, runID, env, vars)
 	if err != nil {
 		return nil, fmt.Errorf("terraform apply failed: %w", err)
 	}
@@ -366,7 +366,7 @@ func (u *UpCloud) CreateServer(config *DeployConfig) (*Server, error) {
 		profile = "basic"
 	}
 
-	moduleDir, err := terraform.FindModuleDir("upcloud", profile)
+	module, err := terraform.FindModule("upcloud", profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find terraform module for upcloud/%s: %w", profile, err)
 	}
@@ -431,8 +431,7 @@ func (u *UpCloud) CreateServer(config *DeployConfig) (*Server, error) {
 		}
 	}
 
-	// Find Ubuntu template name - template block accepts names
-	templateName, err := u.findUbuntuTemplateName(svc, zone)
+	templateName, err := ResolveImage(u, config.Image, zone)
 	if err != nil {
 		return nil, err
 	}
@@ -473,7 +472,7 @@ func (u *UpCloud) CreateServer(config *DeployConfig) (*Server, error) {
 	}
 
 	runID := fmt.Sprintf("%s-%d", config.Name, time.Now().Unix())
-	result, err := terraform.Apply(u.ctx, moduleDir, runID, env, vars)
+	result, err := terraform.Apply(u.ctx, module, runID, env, vars)
 	if err != nil {
 		return nil, fmt.Errorf("terraform apply failed: %w", err)
 	}
@@ -599,105 +598,19 @@ func (u *UpCloud) SetupDNS(domain, ip string) error {
 	return fmt.Errorf("upcloud DNS is not supported in this installer yet; please create an A record for %s -> %s at your DNS provider", domain, ip)
 }
 
-func (u *UpCloud) findUbuntuTemplateUUID(svc *service.Service, zone string) (string, error) {
-	storages, err := u.getTemplateStoragesForZone(zone)
-	if err != nil {
+func (u *UpCloud) ResolveImage(osImage OSImage, region string) (string, error) {
+	if _, err := u.ensureService(); err != nil {
 		return "", err
 	}
-
-	type cand struct {
-		uuid     string
-		priority int
-		title    string
-		zone     string
-	}
-	var bestExact *cand
-	var bestAny *cand
-
-	want := []struct {
-		substr   string
-		priority int
-	}{
-		{"ubuntu 24.04", 100},
-		{"ubuntu 22.04", 90},
-		{"ubuntu 20.04", 80},
-		{"ubuntu", 10},
-	}
-
-	for _, s := range storages.Storages {
-		z := strings.TrimSpace(s.Zone)
-		isExactZone := z != "" && strings.EqualFold(z, zone)
-		if s.Type != upcloud.StorageTypeTemplate {
-			continue
-		}
-		if s.State != upcloud.StorageStateOnline {
-			continue
-		}
-		// Only use public templates - private templates may not be accessible
-		// Check Access field - if it's not Public, skip it
-		if s.Access != upcloud.StorageAccessPublic {
-			fmt.Fprintf(os.Stderr, "[DEBUG] UpCloud: Skipping template %s (title: %q, access: %v, not public)\n", s.UUID, s.Title, s.Access)
-			continue
-		}
-
-		title := strings.ToLower(strings.TrimSpace(s.Title))
-		if title == "" {
-			continue
-		}
-
-		p := 0
-		for _, w := range want {
-			if strings.Contains(title, w.substr) {
-				p = w.priority
-				break
-			}
-		}
-		if p == 0 {
-			continue
-		}
-		// Prefer cloud-init templates when available.
-		if strings.EqualFold(strings.TrimSpace(s.TemplateType), upcloud.StorageTemplateTypeCloudInit) {
-			p += 5
-		}
-
-		fmt.Fprintf(os.Stderr, "[DEBUG] UpCloud: Found candidate template %s (title: %q, zone: %q, access: %v, priority: %d)\n", s.UUID, s.Title, s.Zone, s.Access, p)
-
-		c := cand{uuid: s.UUID, priority: p, title: s.Title, zone: s.Zone}
-		if isExactZone {
-			if bestExact == nil || c.priority > bestExact.priority {
-				bestExact = &c
-			}
-			continue
-		}
-		// Many accounts/APIs return template storages without a zone or with a different zone.
-		// Those templates are still cloneable into the desired zone, so keep them as a fallback.
-		if bestAny == nil || c.priority > bestAny.priority {
-			bestAny = &c
-		}
-	}
-
-	best := bestExact
-	if best == nil {
-		best = bestAny
-	}
-	if best == nil || strings.TrimSpace(best.uuid) == "" {
-		// Helpful hint: show a few templates we did see in that zone (any OS).
-		hints := u.zoneTemplateHints(storages, zone, 10)
-		if hints != "" {
-			return "", fmt.Errorf("upcloud: could not find a public Ubuntu template in zone %s (templates in zone: %s)", zone, hints)
-		}
-		anyHints := u.anyTemplateHints(storages, 10)
-		if anyHints != "" {
-			return "", fmt.Errorf("upcloud: could not find any public Ubuntu templates (examples: %s)", anyHints)
-		}
-		return "", fmt.Errorf("upcloud: could not find any public templates")
+	zone := strings.TrimSpace(region)
+	if zone == "" {
+		zone = u.DefaultRegion()
 	}
-
-	fmt.Fprintf(os.Stderr, "[DEBUG] UpCloud: Selected template UUID: %s (title: %q, zone: %q)\n", best.uuid, best.title, best.zone)
-	return best.uuid, nil
+	return u.findTemplateName(zone, osImage)
 }
 
-func (u *UpCloud) findUbuntuTemplateName(svc *service.Service, zone string) (string, error) {
+// findTemplateName finds the public template for osImage; the terraform template block accepts names.
+func (u *UpCloud) findTemplateName(zone string, osImage OSImage) (string, error) {
 	storages, err := u.getTemplateStoragesForZone(zone)
 	if err != nil {
 		return "", err
@@ -706,22 +619,11 @@ func (u *UpCloud) findUbuntuTemplateName(svc *service.Service, zone string) (str
 	type cand struct {
 		name     string
 		priority int
-		title    string
 		zone     string
 	}
 	var bestExact *cand
 	var bestAny *cand
 
-	want := []struct {
-		substr   string
-		priority int
-	}{
-		{"ubuntu 24.04", 100},
-		{"ubuntu 22.04", 90},
-		{"ubuntu 20.04", 80},
-		{"ubuntu", 10},
-	}
-
 	for _, s := range storages.Storages {
 		z := strings.TrimSpace(s.Zone)
 		isExactZone := z != "" && strings.EqualFold(z, zone)
@@ -738,21 +640,11 @@ func (u *UpCloud) findUbuntuTemplateName(svc *service.Service, zone string) (str
 		}
 
 		title := strings.TrimSpace(s.Title)
-		if title == "" {
+		if title == "" || !osImage.Matches(title) {
 			continue
 		}
-		titleLower := strings.ToLower(title)
 
-		p := 0
-		for _, w := range want {
-			if strings.Contains(titleLower, w.substr) {
-				p = w.priority
-				break
-			}
-		}
-		if p == 0 {
-			continue
-		}
+		p := 1
 		// Prefer cloud-init templates when available.
 		if strings.EqualFold(strings.TrimSpace(s.TemplateType), upcloud.StorageTemplateTypeCloudInit) {
 			p += 5
@@ -760,7 +652,7 @@ func (u *UpCloud) findUbuntuTemplateName(svc *service.Service, zone string) (str
 
 		fmt.Fprintf(os.Stderr, "[DEBUG] UpCloud: Found candidate template %s (title: %q, zone: %q, access: %v, priority: %d)\n", s.UUID, title, s.Zone, s.Access, p)
 
-		c := cand{name: title, priority: p, title: title, zone: s.Zone}
+		c := cand{name: title, priority: p, zone: s.Zone}
 		if isExactZone {
 			if bestExact == nil || c.priority > bestExact.priority {
 				bestExact = &c
@@ -779,14 +671,13 @@ func (u *UpCloud) findUbuntuTemplateName(svc *service.Service, zone string) (str
 		best = bestAny
 	}
 	if best == nil || strings.TrimSpace(best.name) == "" {
+		unavailable := &OSUnavailableError{Provider: u.Name(), OS: osImage, Region: zone}
 		// Helpful hint: show a few templates we did see in that zone (any OS).
-		hints := u.zoneTemplateHints(storages, zone, 10)
-		if hints != "" {
-			return "", fmt.Errorf("upcloud: could not find a public Ubuntu template in zone %s (templates in zone: %s)", zone, hints)
+		if hints := u.zoneTemplateHints(storages, zone, 10); hints != "" {
+			return "", fmt.Errorf("%w (templates in zone: %s)", unavailable, hints)
 		}
-		anyHints := u.anyTemplateHints(storages, 10)
-		if anyHints != "" {
-			return "", fmt.Errorf("upcloud: could not find any public Ubuntu templates (examples: %s)", anyHints)
+		if anyHints := u.anyTemplateHints(storages, 10); anyHints != "" {
+			return "", fmt.Errorf("%w (examples: %s)", unavailable, anyHints)
 		}
 		return "", fmt.Errorf("upcloud: could not find any public templates")
 	}
@@ -809,7 +700,7 @@ func (u *UpCloud) getTemplateStoragesForZone(zone string) (*upcloud.Storages, er
 		// Most intuitive, but may 404 depending on API behavior.
 		{Access: upcloud.StorageAccessPublic, Type: upcloud.StorageTypeTemplate},
 		// Alternative: omit access and just ask for templates (some APIs accept /storage/template).
-		// We'll filter for public templates in findUbuntuTemplateUUID
+		// We'll filter for public templates in findTemplateName
 		{Type: upcloud.StorageTypeTemplate},
 	}
 	for _, r := range tryReqs {
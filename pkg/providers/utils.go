@@ -3,6 +3,7 @@ package providers
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -15,7 +16,7 @@ func min(a, b int) int {
 
 // WaitForSSH waits for SSH to become available
 func WaitForSSH(host string, port int) error {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	timeout := time.After(5 * time.Minute)
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
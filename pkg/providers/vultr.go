@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,8 +35,8 @@ type Vultr struct {
 
 	apiKey string
 
-	// cached OS id for Ubuntu
-	ubuntuOSID int
+	// cached OS ids, keyed by canonical OS identifier
+	osIDs map[string]int
 
 	// Terraform state
 	tfServer  *Server
@@ -77,7 +78,7 @@ func (v *Vultr) Configure(config map[string]string) error {
 
 	// reset cached client so ensureClient rebuilds.
 	v.client = nil
-	v.ubuntuOSID = 0
+	v.osIDs = nil
 
 	_, err := v.ensureClient()
 	return err
@@ -294,7 +295,7 @@ func (v *Vultr) CreateServer(config *DeployConfig) (*Server, error) {
 		profile = "basic"
 	}
 
-	moduleDir, err := terraform.FindModuleDir("vultr", profile)
+	module, err := terraform.FindModule("vultr", profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find terraform module for vultr/%s: %w", profile, err)
 	}
@@ -310,11 +311,14 @@ func (v *Vultr) CreateServer(config *DeployConfig) (*Server, error) {
 		return nil, fmt.Errorf("vultr: ssh key setup failed: %w", err)
 	}
 
-	// Get Ubuntu OS ID
-	osID, err := v.ensureUbuntuOSID(c)
+	image, err := ResolveImage(v, config.Image, region)
 	if err != nil {
 		return nil, err
 	}
+	osID, err := strconv.Atoi(image)
+	if err != nil {
+		return nil, fmt.Errorf("vultr: image must be a numeric OS id, got %q", image)
+	}
 
 	label := strings.TrimSpace(config.Name)
 	if label == "" {
@@ -336,7 +340,7 @@ func (v *Vultr) CreateServer(config *DeployConfig) (*Server, error) {
 	}
 
 	runID := fmt.Sprintf("%s-%d", label, time.Now().Unix())
-	result, err := terraform.Apply(v.ctx, moduleDir, runID, env, vars)
+	result, err := terraform.Apply(v.ctx, module, runID, env, vars)
 	if err != nil {
 		return nil, fmt.Errorf("terraform apply failed: %w", err)
 	}
@@ -399,9 +403,22 @@ func (v *Vultr) SetupDNS(domain, ip string) error {
 	return fmt.Errorf("vultr DNS is not supported in this installer yet; please create an A record for %s -> %s at your DNS provider", domain, ip)
 }
 
-func (v *Vultr) ensureUbuntuOSID(c *govultr.Client) (int, error) {
-	if v.ubuntuOSID != 0 {
-		return v.ubuntuOSID, nil
+func (v *Vultr) ResolveImage(osImage OSImage, region string) (string, error) {
+	c, err := v.ensureClient()
+	if err != nil {
+		return "", err
+	}
+	id, err := v.ensureOSID(c, osImage)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(id), nil
+}
+
+// ensureOSID finds the x64 OS id for osImage. Vultr OS images are global, so the region doesn't matter.
+func (v *Vultr) ensureOSID(c *govultr.Client, osImage OSImage) (int, error) {
+	if id, ok := v.osIDs[osImage.ID]; ok {
+		return id, nil
 	}
 
 	oses, _, _, err := c.OS.List(v.ctx, &govultr.ListOptions{PerPage: 500})
@@ -409,33 +426,20 @@ func (v *Vultr) ensureUbuntuOSID(c *govultr.Client) (int, error) {
 		return 0, err
 	}
 
-	// Prefer newer Ubuntu.
-	want := []string{"Ubuntu 24.04", "Ubuntu 22.04", "Ubuntu 20.04", "Ubuntu"}
-	bestID := 0
-	bestScore := -1
 	for _, osx := range oses {
-		name := strings.TrimSpace(osx.Name)
-		if name == "" {
+		if osx.Arch != "" && !strings.EqualFold(osx.Arch, "x64") {
 			continue
 		}
-		l := strings.ToLower(name)
-		score := -1
-		for i, w := range want {
-			if strings.Contains(l, strings.ToLower(w)) {
-				score = len(want) - i
-				break
-			}
+		if !osImage.Matches(osx.Name) {
+			continue
 		}
-		if score > bestScore {
-			bestScore = score
-			bestID = osx.ID
+		if v.osIDs == nil {
+			v.osIDs = make(map[string]int)
 		}
+		v.osIDs[osImage.ID] = osx.ID
+		return osx.ID, nil
 	}
-	if bestID == 0 {
-		return 0, fmt.Errorf("vultr: could not find an Ubuntu OS id")
-	}
-	v.ubuntuOSID = bestID
-	return bestID, nil
+	return 0, &OSUnavailableError{Provider: v.Name(), OS: osImage}
 }
 
 func (v *Vultr) ensureSSHKey(c *govultr.Client, publicKey string) (string, error) {
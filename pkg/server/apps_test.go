@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/zdunecki/selfhosted/marketplace"
+	"github.com/zdunecki/selfhosted/pkg/apps"
+)
+
+// /api/apps stays a plain list of apps; categories and broken apps have their own endpoints.
+func TestListAppsIsArray(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(marketplace.PathEnv, "")
+	if err := apps.LoadRegistry(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleListApps(rec, httptest.NewRequest("GET", "/api/apps?category=analytics", nil))
+	var list []struct {
+		Name     string `json:"name"`
+		Category string `json:"category"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("/api/apps is not a JSON array: %v\n%s", err, rec.Body)
+	}
+	if len(list) == 0 {
+		t.Fatal("no analytics apps listed")
+	}
+	for _, a := range list {
+		if a.Category != "analytics" {
+			t.Errorf("app %s has category %q", a.Name, a.Category)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handleAppCategories(rec, httptest.NewRequest("GET", "/api/apps/categories", nil))
+	var categories []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &categories); err != nil || !slices.Contains(categories, "analytics") {
+		t.Errorf("/api/apps/categories = %s, %v", rec.Body, err)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// PTY WebSocket protocol, at /api/pty/{session}/ws?token=TOKEN&offset=N:
+//
+//	server → client  binary frames: output bytes, in order
+//	                 {"type":"offset","offset":N}: the output that follows starts at byte N
+//	                 (sent first, and again if the client fell behind the kept backlog)
+//	                 {"type":"resize","cols":C,"rows":R}: the current size (sent first)
+//	                 {"type":"error","message":"..."}: a rejected message
+//	                 {"type":"close"}: the session ended; all output has been sent
+//	client → server  binary frames: input
+//	                 {"type":"resize","cols":C,"rows":R}
+//	                 {"type":"close"}: the client is leaving (the session keeps running)
+//
+// offset is the number of output bytes the client already has, so a reconnecting client resumes
+// where it left off. Each client reads output at its own pace; a slow one falls behind instead of
+// slowing down the command.
+const (
+	ptySocketChunk     = 32 * 1024
+	ptySocketReadLimit = 64 * 1024
+	ptySocketPing      = 30 * time.Second
+	ptySocketPongWait  = 75 * time.Second
+	ptySocketWriteWait = 10 * time.Second
+)
+
+type ptyFrame struct {
+	Type    string `json:"type"`
+	Offset  int64  `json:"offset,omitempty"`
+	Cols    int    `json:"cols,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+var ptyUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: ptySocketChunk,
+	CheckOrigin:     checkPTYOrigin,
+}
+
+// checkPTYOrigin accepts same-origin pages and the localhost origins corsMiddleware allows.
+func checkPTYOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || isLocalOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// isLocalOrigin reports whether origin is a localhost page, such as the desktop dev server.
+func isLocalOrigin(origin string) bool {
+	return strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:")
+}
+
+func handlePTYSocket(w http.ResponseWriter, r *http.Request) {
+	session, err := utils.GetPTY(r.PathValue("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := session.Authorize(r.URL.Query().Get("token")); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var offset int64
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.ParseInt(v, 10, 64); err != nil || offset < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	conn, err := ptyUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied
+	}
+	defer conn.Close()
+
+	// The reader handles input and resizes; replies go through the writer, the only goroutine writing to conn.
+	replies := make(chan ptyFrame, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		readPTYSocket(conn, session, replies)
+	}()
+
+	writePTYSocket(conn, session, offset, replies, done)
+}
+
+// readPTYSocket applies client messages until the connection closes or the client says goodbye.
+func readPTYSocket(conn *websocket.Conn, session *utils.PTYSession, replies chan<- ptyFrame) {
+	conn.SetReadLimit(ptySocketReadLimit)
+	conn.SetReadDeadline(time.Now().Add(ptySocketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(ptySocketPongWait))
+	})
+	reply := func(f ptyFrame) {
+		select {
+		case replies <- f:
+		default: // the client isn't reading; drop it
+		}
+	}
+
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(ptySocketPongWait))
+		switch kind {
+		case websocket.BinaryMessage:
+			// Writing blocks until the command takes the input, which holds back a fast typist.
+			if _, err := session.Write(data); err != nil {
+				reply(ptyFrame{Type: "error", Message: err.Error()})
+			}
+		case websocket.TextMessage:
+			var f ptyFrame
+			if err := json.Unmarshal(data, &f); err != nil {
+				reply(ptyFrame{Type: "error", Message: "invalid message: " + err.Error()})
+				continue
+			}
+			switch f.Type {
+			case "resize":
+				if err := session.Resize(f.Rows, f.Cols); err != nil {
+					reply(ptyFrame{Type: "error", Message: err.Error()})
+				}
+			case "close":
+				return
+			default:
+				reply(ptyFrame{Type: "error", Message: "unknown message type: " + f.Type})
+			}
+		}
+	}
+}
+
+// writePTYSocket streams output from offset on, until the session ends or the client goes away.
+func writePTYSocket(conn *websocket.Conn, session *utils.PTYSession, offset int64, replies <-chan ptyFrame, done <-chan struct{}) {
+	write := func(kind int, data []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(ptySocketWriteWait))
+		return conn.WriteMessage(kind, data)
+	}
+	writeFrame := func(f ptyFrame) error {
+		data, _ := json.Marshal(f)
+		return write(websocket.TextMessage, data)
+	}
+
+	rows, cols := session.Size()
+	if writeFrame(ptyFrame{Type: "resize", Cols: cols, Rows: rows}) != nil {
+		return
+	}
+	ping := time.NewTicker(ptySocketPing)
+	defer ping.Stop()
+
+	first := true
+	for {
+		data, from, next, closed, changed := session.ReadOutput(offset)
+		if first || from != offset {
+			if writeFrame(ptyFrame{Type: "offset", Offset: from}) != nil {
+				return
+			}
+			first = false
+		}
+		for len(data) > 0 {
+			n := min(len(data), ptySocketChunk)
+			if write(websocket.BinaryMessage, data[:n]) != nil {
+				return
+			}
+			data = data[n:]
+		}
+		offset = next
+		if closed {
+			writeFrame(ptyFrame{Type: "close"})
+			write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session ended"))
+			return
+		}
+
+		select {
+		case <-changed:
+		case f := <-replies:
+			if writeFrame(f) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ptySocketWriteWait)) != nil {
+				return
+			}
+		case <-done:
+			write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"path"
 	"runtime"
 	"sort"
 	"strings"
@@ -18,6 +24,8 @@ import (
 
 	"github.com/zdunecki/selfhosted/pkg/apps"
 	github_com_zdunecki_selfhosted_pkg_cli "github.com/zdunecki/selfhosted/pkg/cli"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
+	"github.com/zdunecki/selfhosted/pkg/dsl"
 	"github.com/zdunecki/selfhosted/pkg/providers"
 	"github.com/zdunecki/selfhosted/pkg/utils"
 )
@@ -30,7 +38,7 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 		// Allow requests from localhost on any port (for desktop dev server)
-		if origin != "" && (strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:")) {
+		if origin != "" && isLocalOrigin(origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -93,7 +101,14 @@ func StartWithOptions(port int, openInBrowser bool) error {
 
 	// API Endpoints (with CORS middleware)
 	http.HandleFunc("/api/apps", corsMiddleware(handleListApps))
-	http.HandleFunc("/api/pty/input", corsMiddleware(handlePTYInput))
+	http.HandleFunc("/api/apps/categories", corsMiddleware(handleAppCategories))
+	http.HandleFunc("/api/apps/{name}/icon", corsMiddleware(handleAppIcon))
+	http.HandleFunc("/api/pty/input", terminalMiddleware(handlePTYInput))
+	http.HandleFunc("/api/pty/resize", terminalMiddleware(handlePTYResize))
+	http.HandleFunc("/api/pty/{session}/ws", handlePTYSocket)
+	http.HandleFunc("/api/deployments/{id}/pty", corsMiddleware(handleListRecordings))
+	http.HandleFunc("/api/deployments/{id}/pty/{file}", corsMiddleware(handleRecording))
+	http.HandleFunc("/api/deployments/{id}/shell", terminalMiddleware(handleDeploymentShell))
 	http.HandleFunc("/api/providers", corsMiddleware(handleListProviders))
 	http.HandleFunc("/api/providers/check", corsMiddleware(handleCheckProviderCredentials))
 	http.HandleFunc("/api/providers/gcp/billing-accounts", corsMiddleware(handleGCPBillingAccounts))
@@ -105,6 +120,14 @@ func StartWithOptions(port int, openInBrowser bool) error {
 	http.HandleFunc("/api/domains/check", corsMiddleware(handleDomainCheck))
 	http.HandleFunc("/api/cloudflare/verify", corsMiddleware(handleCloudflareVerify))
 	http.HandleFunc("/api/crypto/public-key", corsMiddleware(handlePublicKey))
+	http.HandleFunc("/api/dsl/schema", corsMiddleware(handleDSLSchema))
+	http.HandleFunc("/api/marketplace/status", corsMiddleware(handleMarketplaceStatus))
+
+	if err := apps.LoadRegistry(); err != nil {
+		log.Printf("⚠️  %v\n", err)
+	}
+	// Pick up marketplace edits without a restart; invalid changes keep the last good version.
+	go apps.WatchMarketplace(context.Background(), apps.MarketplacePollInterval, log.Printf)
 
 	url := fmt.Sprintf("http://localhost:%d", port)
 	log.Printf("Starting web interface at %s\n", url)
@@ -157,6 +180,7 @@ func handlePTYInput(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		SessionID string `json:"sessionId"`
+		Token     string `json:"token"`
 		DataB64   string `json:"dataB64"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -168,54 +192,228 @@ func handlePTYInput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := utils.WritePTYBase64(req.SessionID, req.DataB64); err != nil {
+	if err := utils.WritePTYBase64(req.SessionID, req.Token, req.DataB64); err != nil {
+		http.Error(w, err.Error(), ptyErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ptyErrorStatus maps PTY session errors to a status: a wrong token is forbidden, the rest are bad requests.
+func ptyErrorStatus(err error) int {
+	if errors.Is(err, utils.ErrPTYToken) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// handlePTYResize applies the browser terminal's size to a PTY session.
+func handlePTYResize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SessionID string `json:"sessionId"`
+		Token     string `json:"token"`
+		Cols      int    `json:"cols"`
+		Rows      int    `json:"rows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.SessionID == "" {
+		http.Error(w, "sessionId is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ResizePTY(req.SessionID, req.Token, req.Rows, req.Cols); err != nil {
+		http.Error(w, err.Error(), ptyErrorStatus(err))
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleListRecordings lists the recorded PTY sessions of a deployment.
+func handleListRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	recordings, err := deployments.Recordings(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if recordings == nil {
+		recordings = []deployments.Recording{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recordings)
+}
+
+// handleRecording serves a PTY session recording (asciicast v2), e.g. /api/deployments/{id}/pty/{session}.cast.
+func handleRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file := r.PathValue("file")
+	if !strings.HasSuffix(file, deployments.CastExt) {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := deployments.RecordingPath(r.PathValue("id"), file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	http.ServeContent(w, r, file, info.ModTime(), f)
+}
+
 func handleListApps(w http.ResponseWriter, r *http.Request) {
 	type AppResponse struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		MinCPUs     int    `json:"min_cpus"`
-		MinMemory   int    `json:"min_memory"`
-		DomainHint  string `json:"domain_hint"`
-		Wizard      struct {
+		apps.Metadata
+		// IconURL is where the UI loads the icon from: metadata.icon when it is a URL, else /api/apps/<name>/icon.
+		IconURL    string `json:"icon_url,omitempty"`
+		MinCPUs    int    `json:"min_cpus"`
+		MinMemory  int    `json:"min_memory"`
+		MinDisk    int    `json:"min_disk"`
+		OS         string `json:"os"`
+		DomainHint string `json:"domain_hint"`
+		// Providers lists the providers the app supports; empty means all.
+		Providers []string `json:"providers,omitempty"`
+		// Versions are the versions a deploy can pick (app_version), newest first.
+		Versions       []string `json:"versions,omitempty"`
+		DefaultVersion string   `json:"default_version,omitempty"`
+		LatestVersion  string   `json:"latest_version,omitempty"`
+		// Verification is the app's signature check; apps implemented in Go have none.
+		Verification *apps.Verification `json:"verification,omitempty"`
+		Wizard       struct {
 			Application struct {
 				CustomQuestions []apps.WizardQuestion `json:"custom_questions,omitempty"`
 			} `json:"application"`
 		} `json:"wizard,omitempty"`
 	}
-	var res []AppResponse
-	for name, app := range apps.Registry {
+	// ?q= searches names, descriptions, categories and tags; ?category= filters; ?sort= orders.
+	query := r.URL.Query()
+	found, err := apps.FindApps(apps.AppQuery{
+		Text:     query.Get("q"),
+		Category: query.Get("category"),
+		Sort:     query.Get("sort"),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := []AppResponse{}
+	for _, app := range found {
+		name := app.Name()
 		specs := app.MinSpecs()
 		ar := AppResponse{
 			Name:        name,
 			Description: app.Description(),
+			Metadata:    apps.MetadataFor(app),
 			MinCPUs:     specs.CPUs,
 			MinMemory:   specs.MemoryMB,
+			MinDisk:     specs.DiskGB,
+			OS:          apps.OSFor(app),
 			DomainHint:  app.DomainHint(),
+			Providers:   apps.SupportedProviders(app),
+
+			Versions:       apps.VersionNames(app),
+			DefaultVersion: apps.DefaultVersion(app),
+			LatestVersion:  apps.LatestVersion(app),
+		}
+		switch {
+		case ar.Icon == "":
+		case apps.IconURL(ar.Metadata):
+			ar.IconURL = ar.Icon
+		default:
+			ar.IconURL = "/api/apps/" + url.PathEscape(name) + "/icon"
+		}
+		if v, ok := apps.AppVerification(name); ok {
+			ar.Verification = &v
 		}
 		if wp, ok := app.(apps.WizardProvider); ok {
 			ar.Wizard.Application.CustomQuestions = wp.WizardQuestions()
 		}
 		res = append(res, ar)
 	}
-	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	// Apps that failed to load are listed by /api/marketplace/status.
 	json.NewEncoder(w).Encode(res)
 }
 
+// handleAppCategories lists the categories of all apps, for filters.
+func handleAppCategories(w http.ResponseWriter, r *http.Request) {
+	categories := apps.Categories()
+	if categories == nil {
+		categories = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// handleAppIcon serves the icon file an app ships with (metadata.icon).
+func handleAppIcon(w http.ResponseWriter, r *http.Request) {
+	app, err := apps.Get(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	data, name, err := apps.AppIcon(app)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if strings.ToLower(path.Ext(name)) == ".svg" {
+		// SVGs can carry scripts; only let them render as images.
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
 func handleListProviders(w http.ResponseWriter, r *http.Request) {
 	type ProviderResponse struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
 		NeedsConfig bool   `json:"needs_config,omitempty"`
 	}
+	// Optional: only list providers the app supports.
+	var app apps.App
+	if appName := r.URL.Query().Get("app"); appName != "" {
+		a, err := apps.Get(appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		app = a
+	}
+
 	var res []ProviderResponse
 	for name, p := range providers.Registry {
+		if app != nil && !apps.SupportsProvider(app, name) {
+			continue
+		}
 		needsConfig := false
 		if np, ok := p.(interface{ NeedsConfig() bool }); ok {
 			needsConfig = np.NeedsConfig()
@@ -318,25 +516,25 @@ func handleListSizes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Optional: if provider supports region/zone-specific sizes, use them.
-	type sizesByRegion interface {
-		ListSizesForRegion(region string) ([]providers.Size, error)
-	}
-
-	var sizes []providers.Size
-	if region != "" {
-		if sp, ok := p.(sizesByRegion); ok {
-			sizes, err = sp.ListSizesForRegion(region)
-		} else {
-			sizes, err = p.ListSizes()
+	// Optional: only list sizes meeting the app's minimum CPU, RAM and disk.
+	var app apps.App
+	if appName := r.URL.Query().Get("app"); appName != "" {
+		app, err = apps.Get(appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-	} else {
-		sizes, err = p.ListSizes()
 	}
+
+	// If provider supports region/zone-specific sizes, use them.
+	sizes, err := providers.ListSizesForRegion(p, region)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if app != nil {
+		sizes = providers.FilterSizesForSpecs(sizes, app.MinSpecs())
+	}
 	json.NewEncoder(w).Encode(sizes)
 }
 
@@ -360,6 +558,8 @@ func handleDeploy(w http.ResponseWriter, r *http.Request) {
 		CloudflareAccountId  string                 `json:"cloudflareAccountId"`
 		CloudflareProxied    *bool                  `json:"cloudflareProxied"` // Optional, defaults to true
 		WizardAnswers        map[string]interface{} `json:"wizardAnswers"`
+		IgnoreCompatibility  bool                   `json:"ignoreCompatibility"`
+		AppVersion           string                 `json:"appVersion"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -380,6 +580,7 @@ func handleDeploy(w http.ResponseWriter, r *http.Request) {
 	// Map to CLI options
 	deployOpts := github_com_zdunecki_selfhosted_pkg_cli.DeployOptions{
 		AppName:           opts.App,
+		AppVersion:        opts.AppVersion,
 		ProviderName:      opts.Provider,
 		Region:            opts.Region,
 		Size:              opts.Size,
@@ -392,6 +593,24 @@ func handleDeploy(w http.ResponseWriter, r *http.Request) {
 		CloudflareToken:   opts.CloudflareToken,
 		CloudflareProxied: cloudflareProxied,
 		WizardAnswers:     opts.WizardAnswers,
+
+		IgnoreCompatibility: opts.IgnoreCompatibility,
+	}
+
+	// Reject invalid wizard answers up front, with errors keyed by question ID for the form.
+	if app, err := apps.Get(opts.App); err == nil {
+		if _, err := apps.ValidateWizardAnswers(app, deployOpts.WizardAnswers, deployOpts.WizardEnv()); err != nil {
+			var validationErr *apps.WizardValidationError
+			if errors.As(err, &validationErr) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":  err.Error(),
+					"errors": validationErr.Errors,
+				})
+				return
+			}
+		}
 	}
 
 	// Set headers for streaming (must be set before writing status)
@@ -579,6 +798,8 @@ func handleDeploy(w http.ResponseWriter, r *http.Request) {
 			// Client disconnected, don't send completion
 			return
 		default:
+			// Tell the UI which deployment record this was, e.g. to open a shell on it.
+			fmt.Fprintf(w, "data: [SELFHOSTED::DEPLOYMENT] %s\n\n", deployments.IDFor(deployOpts.AppName, deployOpts.Domain))
 			// Send completion message
 			if _, writeErr := fmt.Fprintf(w, "data: [SELFHOSTED::DONE]\n\n"); writeErr == nil {
 				flusher.Flush()
@@ -752,6 +973,29 @@ func handleCloudflareVerify(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+func handleDSLSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := dsl.JSONSchemaBytes(apps.LintOptions())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(data)
+}
+
+func handleMarketplaceStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apps.GetMarketplaceStatus())
+}
+
 func handlePublicKey(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zdunecki/selfhosted/pkg/cli"
+	"github.com/zdunecki/selfhosted/pkg/deployments"
+	"github.com/zdunecki/selfhosted/pkg/utils"
+)
+
+// shellIdleTimeout ends a web shell nobody has typed into for this long.
+const shellIdleTimeout = 30 * time.Minute
+
+// handleDeploymentShell opens a login shell on a deployment's server and streams it as a PTY
+// session, for the web UI's terminal. A shell is root on the server, so the request must come from
+// this machine and confirm it explicitly: POST {"confirm":true}. Serve it behind terminalMiddleware.
+func handleDeploymentShell(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoopbackRequest(r) || !isLoopbackHost(r.Host) {
+		http.Error(w, "shell access is only available from localhost", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Confirm bool `json:"confirm"`
+		Cols    int  `json:"cols"`
+		Rows    int  `json:"rows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !req.Confirm {
+		http.Error(w, "confirm is required to open a shell", http.StatusBadRequest)
+		return
+	}
+
+	d, err := deployments.Find(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	runner, err := cli.ConnectDeployment(d, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	opts := utils.PTYOptions{Rows: req.Rows, Cols: req.Cols}
+	if opts.Rows < 1 || opts.Cols < 1 || opts.Rows > utils.MaxPTYSize || opts.Cols > utils.MaxPTYSize {
+		opts.Rows, opts.Cols = 0, 0
+	}
+	opts = opts.WithDefaults()
+	session := utils.RegisterPTY(newShellID(), opts.Rows, opts.Cols)
+
+	// The shell outlives this request; it ends when the user exits it or leaves it idle.
+	ctx, cancel := context.WithCancel(context.Background())
+	stdin, wait, err := runner.RunPTYContext(ctx, "", opts, session.Output)
+	if err != nil {
+		cancel()
+		utils.ClosePTY(session.ID)
+		runner.Close()
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	input := &activityWriter{WriteCloser: stdin}
+	input.touch()
+	session.Attach(input, runner.WindowChange)
+
+	log.Printf("Opened web shell %s on deployment %s (%s)", session.ID, d.ID, d.ServerIP)
+	go closeIdleShell(ctx, cancel, input)
+	go func() {
+		err := wait()
+		cancel()
+		utils.ClosePTY(session.ID)
+		runner.Close()
+		log.Printf("Web shell %s on deployment %s ended: %v", session.ID, d.ID, err)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessionId": session.ID,
+		"token":     session.Token,
+		"cols":      opts.Cols,
+		"rows":      opts.Rows,
+	})
+}
+
+// isLoopbackRequest reports whether r comes from this machine. The server listens on all
+// interfaces, so CORS alone doesn't keep other hosts out.
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackHost reports whether the request was addressed to this machine by a loopback name, so a
+// DNS-rebound site can't pass as the same origin.
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// terminalMiddleware guards endpoints that open or type into a terminal. Unlike corsMiddleware it
+// never allows other sites: a request must come from the UI's own origin or a localhost page, and
+// must be JSON, which browsers can't send cross-site without a preflight.
+func terminalMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkPTYOrigin(r) {
+			http.Error(w, "cross-origin terminal requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); isLocalOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func newShellID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "shell-" + hex.EncodeToString(b)
+}
+
+// activityWriter records when input last went through.
+type activityWriter struct {
+	io.WriteCloser
+	last atomic.Int64
+}
+
+func (a *activityWriter) touch() { a.last.Store(time.Now().UnixNano()) }
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.touch()
+	return a.WriteCloser.Write(p)
+}
+
+func (a *activityWriter) idle() time.Duration {
+	return time.Since(time.Unix(0, a.last.Load()))
+}
+
+// closeIdleShell cancels the shell once it has had no input for shellIdleTimeout.
+func closeIdleShell(ctx context.Context, cancel context.CancelFunc, input *activityWriter) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if input.idle() >= shellIdleTimeout {
+				cancel()
+				return
+			}
+		}
+	}
+}
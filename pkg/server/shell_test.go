@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTerminalMiddleware(t *testing.T) {
+	ok := terminalMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		contentType string
+		want        int
+		wantACAO    string
+	}{
+		{"cross-site text/plain", "POST", "https://evil.example", "text/plain", http.StatusForbidden, ""},
+		{"cross-site json", "POST", "https://evil.example", "application/json", http.StatusForbidden, ""},
+		{"cross-site preflight", "OPTIONS", "https://evil.example", "", http.StatusForbidden, ""},
+		{"same origin", "POST", "http://127.0.0.1:8080", "application/json", http.StatusNoContent, "http://127.0.0.1:8080"},
+		{"same host", "POST", "http://selfhost.lan:8080", "application/json; charset=utf-8", http.StatusNoContent, ""},
+		{"no origin", "POST", "", "application/json", http.StatusNoContent, ""},
+		{"local dev server", "POST", "http://localhost:5173", "application/json", http.StatusNoContent, "http://localhost:5173"},
+		{"local preflight", "OPTIONS", "http://localhost:5173", "", http.StatusOK, "http://localhost:5173"},
+		{"form post", "POST", "", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType, ""},
+		{"no content type", "POST", "", "", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://selfhost.lan:8080/api/pty/input", strings.NewReader(`{"confirm":true}`))
+			if tt.name == "same origin" {
+				r.Host = "127.0.0.1:8080"
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			ok(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantACAO {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantACAO)
+			}
+		})
+	}
+}
+
+func TestDeploymentShellRequiresLoopbackHost(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://rebound.example:8080/api/deployments/x/shell", strings.NewReader(`{"confirm":true}`))
+	r.RemoteAddr = "127.0.0.1:50000"
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handleDeploymentShell(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	for _, host := range []string{"localhost:8080", "127.0.0.1:8080", "[::1]:8080", "localhost"} {
+		if !isLoopbackHost(host) {
+			t.Errorf("isLoopbackHost(%q) = false", host)
+		}
+	}
+	for _, host := range []string{"rebound.example:8080", "10.0.0.5:8080", "localhost.evil.example"} {
+		if isLoopbackHost(host) {
+			t.Errorf("isLoopbackHost(%q) = true", host)
+		}
+	}
+}
@@ -7,15 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
 	tfexec "github.com/hashicorp/terraform-exec/tfexec"
+
+	"github.com/zdunecki/selfhosted/marketplace"
 )
 
 const (
@@ -33,36 +37,33 @@ type ApplyResult struct {
 	Outputs map[string]OutputValue
 }
 
-func FindModuleDir(provider, profile string) (string, error) {
-	candidates := []string{}
-
-	if cwd, err := os.Getwd(); err == nil {
-		candidates = append(candidates, filepath.Join(cwd, "marketplace", "terraform"))
-	}
-
-	if exe, err := os.Executable(); err == nil {
-		exeDir := filepath.Dir(exe)
-		candidates = append(candidates, filepath.Join(exeDir, "marketplace", "terraform"))
-		candidates = append(candidates, filepath.Join(exeDir, "..", "marketplace", "terraform"))
-	}
-
-	for _, root := range candidates {
-		moduleDir := filepath.Join(root, provider, profile)
+// FindModule returns the Terraform module for a provider and profile: from the first local
+// marketplace directory that has it (see the marketplace package), else the copy built into the
+// binary. Apply copies it into the run's work dir.
+func FindModule(provider, profile string) (fs.FS, error) {
+	for _, dir := range marketplace.Dirs() {
+		moduleDir := filepath.Join(dir, "terraform", provider, profile)
 		if _, err := os.Stat(filepath.Join(moduleDir, "main.tf")); err == nil {
-			return moduleDir, nil
+			return os.DirFS(moduleDir), nil
 		}
 	}
 
-	return "", fmt.Errorf("terraform module not found for %s/%s", provider, profile)
+	module, err := fs.Sub(marketplace.FS, path.Join("terraform", provider, profile))
+	if err == nil {
+		if _, err := fs.Stat(module, "main.tf"); err == nil {
+			return module, nil
+		}
+	}
+	return nil, fmt.Errorf("terraform module not found for %s/%s", provider, profile)
 }
 
-func Apply(ctx context.Context, moduleDir, runID string, env map[string]string, vars map[string]interface{}) (*ApplyResult, error) {
+func Apply(ctx context.Context, module fs.FS, runID string, env map[string]string, vars map[string]interface{}) (*ApplyResult, error) {
 	terraformPath, err := ensureTerraformBinary()
 	if err != nil {
 		return nil, err
 	}
 
-	workDir, err := prepareWorkDir(moduleDir, runID)
+	workDir, err := prepareWorkDir(module, runID)
 	if err != nil {
 		return nil, err
 	}
@@ -176,7 +177,7 @@ func formatVar(key string, value interface{}) string {
 	}
 }
 
-func prepareWorkDir(moduleDir, runID string) (string, error) {
+func prepareWorkDir(module fs.FS, runID string) (string, error) {
 	workRoot, err := terraformWorkRoot()
 	if err != nil {
 		return "", err
@@ -191,7 +192,7 @@ func prepareWorkDir(moduleDir, runID string) (string, error) {
 	if err := os.RemoveAll(workDir); err != nil {
 		return "", err
 	}
-	if err := copyDir(moduleDir, workDir); err != nil {
+	if err := copyDir(module, ".", workDir); err != nil {
 		return "", err
 	}
 
@@ -216,8 +217,9 @@ func sanitizeRunID(input string) string {
 	return b.String()
 }
 
-func copyDir(src, dst string) error {
-	entries, err := os.ReadDir(src)
+// copyDir copies dir of fsys to dst.
+func copyDir(fsys fs.FS, dir, dst string) error {
+	entries, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return err
 	}
@@ -228,23 +230,23 @@ func copyDir(src, dst string) error {
 		if entry.Name() == ".terraform" {
 			continue
 		}
-		srcPath := filepath.Join(src, entry.Name())
+		srcPath := path.Join(dir, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 		if entry.IsDir() {
-			if err := copyDir(srcPath, dstPath); err != nil {
+			if err := copyDir(fsys, srcPath, dstPath); err != nil {
 				return err
 			}
 			continue
 		}
-		if err := copyFile(srcPath, dstPath); err != nil {
+		if err := copyFile(fsys, srcPath, dstPath); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
+func copyFile(fsys fs.FS, src, dst string) error {
+	in, err := fsys.Open(src)
 	if err != nil {
 		return err
 	}
@@ -260,8 +262,9 @@ func copyFile(src, dst string) error {
 		return err
 	}
 
-	if info, err := os.Stat(src); err == nil {
-		_ = os.Chmod(dst, info.Mode())
+	// Embedded files are read-only; keep the work dir writable.
+	if info, err := in.Stat(); err == nil {
+		_ = os.Chmod(dst, info.Mode().Perm()|0200)
 	}
 
 	return nil
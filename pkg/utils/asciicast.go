@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CastHeader is the first line of an asciicast v2 recording.
+type CastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Asciicast event types.
+const (
+	CastOutput = "o"
+	CastInput  = "i"
+	CastResize = "r"
+)
+
+// CastEvent is one line after the header: seconds since the start, the type and its data.
+type CastEvent struct {
+	Time float64
+	Type string
+	Data string
+}
+
+func (e CastEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+func (e *CastEvent) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("asciicast event: want 3 fields, got %d", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return fmt.Errorf("asciicast event time: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return fmt.Errorf("asciicast event type: %w", err)
+	}
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return fmt.Errorf("asciicast event data: %w", err)
+	}
+	return nil
+}
+
+// CastRecorder writes a PTY session as an asciicast v2 file (https://docs.asciinema.org/manual/asciicast/v2/).
+// Output and input may be recorded from different goroutines.
+type CastRecorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+	// Output chunks may end mid-rune; the incomplete tail waits for the next chunk so every event is valid UTF-8.
+	pending []byte
+	// Redactor, when set, is applied to recorded output and input. Each is redacted as a stream,
+	// so secrets split across chunks or keystrokes are hidden too.
+	Redactor *Redactor
+	output   *RedactStream
+	input    *RedactStream
+}
+
+// NewCastRecorder creates the recording at path and writes its header. Version and Timestamp
+// default to 2 and now.
+func NewCastRecorder(path string, header CastHeader) (*CastRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := &CastRecorder{f: f, w: bufio.NewWriter(f), start: time.Now()}
+	if header.Version == 0 {
+		header.Version = 2
+	}
+	if header.Timestamp == 0 {
+		header.Timestamp = r.start.Unix()
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.w.Write(append(data, '\n'))
+	return r, nil
+}
+
+// Output records PTY output.
+func (r *CastRecorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.output == nil {
+		r.output = r.Redactor.Stream()
+	}
+	data := append(r.pending, r.output.Redact(p)...)
+	cut := len(data)
+	// Hold back an incomplete rune at the end (at most utf8.UTFMax-1 bytes).
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	r.event(CastOutput, string(data[:cut]))
+}
+
+// Input records keystrokes sent to the PTY.
+func (r *CastRecorder) Input(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.input == nil {
+		r.input = r.Redactor.Stream()
+	}
+	r.event(CastInput, string(r.input.Redact(p)))
+}
+
+// Resize records a terminal size change.
+func (r *CastRecorder) Resize(rows, cols int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event(CastResize, strconv.Itoa(cols)+"x"+strconv.Itoa(rows))
+}
+
+func (r *CastRecorder) event(kind, data string) {
+	if r.w == nil || data == "" {
+		return
+	}
+	line, err := json.Marshal(CastEvent{Time: time.Since(r.start).Seconds(), Type: kind, Data: data})
+	if err != nil {
+		return
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+// RecordInput wraps a PTY's stdin so everything written to it is recorded as input.
+func (r *CastRecorder) RecordInput(stdin io.WriteCloser) io.WriteCloser {
+	return &castInput{WriteCloser: stdin, rec: r}
+}
+
+type castInput struct {
+	io.WriteCloser
+	rec *CastRecorder
+}
+
+func (c *castInput) Write(p []byte) (int, error) {
+	n, err := c.WriteCloser.Write(p)
+	if n > 0 {
+		c.rec.Input(p[:n])
+	}
+	return n, err
+}
+
+// Close flushes the recording and closes the file. Further events are dropped.
+func (r *CastRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return nil
+	}
+	if rest := r.output.Flush(); len(rest) > 0 || len(r.pending) > 0 {
+		r.event(CastOutput, string(append(r.pending, rest...)))
+		r.pending = nil
+	}
+	r.event(CastInput, string(r.input.Flush()))
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.w = nil
+	return err
+}
+
+// ReadCastHeader reads just the header of an asciicast v2 recording.
+func ReadCastHeader(path string) (CastHeader, error) {
+	var header CastHeader
+	f, err := os.Open(path)
+	if err != nil {
+		return header, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return header, err
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, fmt.Errorf("asciicast header: %w", err)
+	}
+	return header, nil
+}
+
+// ReadCast parses an asciicast v2 recording.
+func ReadCast(r io.Reader) (CastHeader, []CastEvent, error) {
+	var header CastHeader
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, fmt.Errorf("asciicast: empty recording")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, nil, fmt.Errorf("asciicast header: %w", err)
+	}
+	if header.Version != 2 {
+		return header, nil, fmt.Errorf("asciicast: unsupported version %d", header.Version)
+	}
+	var events []CastEvent
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e CastEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return header, events, fmt.Errorf("asciicast line %d: %w", line, err)
+		}
+		events = append(events, e)
+	}
+	return header, events, scanner.Err()
+}
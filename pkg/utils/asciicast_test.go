@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCastRecorderRedactsSplitSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	rec, err := NewCastRecorder(path, CastHeader{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Redactor = NewRedactor("hunter2")
+	rec.Output([]byte("pass: hun"))
+	rec.Output([]byte("ter2\r\n"))
+	for _, key := range []string{"h", "u", "n", "t", "e", "r", "2"} {
+		rec.Input([]byte(key))
+	}
+	rec.Output([]byte("tail hunt"))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var output, input strings.Builder
+	lines := bufio.NewScanner(f)
+	lines.Scan() // header
+	for lines.Scan() {
+		var e CastEvent
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		switch e.Type {
+		case CastOutput:
+			output.WriteString(e.Data)
+		case CastInput:
+			input.WriteString(e.Data)
+		}
+	}
+	if got, want := output.String(), "pass: "+redactedPlaceholder+"\r\ntail hunt"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if got := input.String(); got != redactedPlaceholder {
+		t.Errorf("input = %q, want %q", got, redactedPlaceholder)
+	}
+}
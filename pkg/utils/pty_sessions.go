@@ -1,50 +1,241 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
-// Very small in-memory registry for interactive PTY sessions.
-// This enables a "send keys from UI" flow without introducing websocket deps yet.
+// In-memory registry of interactive PTY sessions, so the UI can watch and type into them.
+// Output is kept in a bounded backlog that every client reads at its own pace (a slow client
+// never blocks the command), which also lets a client reconnect and resume from an offset.
+// Input requires the session token, which only the client that started the deploy receives.
+
+const (
+	// MaxPTYSize bounds the rows and columns a client may resize a PTY to.
+	MaxPTYSize = 1000
+	// ptyBacklog is how much output (at least) is kept for clients that connect late or reconnect.
+	ptyBacklog = 1 << 20
+	// ptyLinger keeps an ended session around, so clients can still read its last output.
+	ptyLinger = time.Minute
+)
 
 var (
+	// ErrPTYToken is returned for input without the session's token.
+	ErrPTYToken = errors.New("invalid PTY session token")
+
 	ptyMu       sync.RWMutex
-	ptySessions = map[string]io.WriteCloser{}
+	ptySessions = map[string]*PTYSession{}
 )
 
-func RegisterPTY(sessionID string, stdin io.WriteCloser) {
+// PTYSession is an interactive PTY reachable from the UI.
+type PTYSession struct {
+	ID string
+	// Token authorizes input and resizing.
+	Token string
+
+	mu      sync.Mutex
+	changed chan struct{} // closed (and replaced) whenever output arrives or the session ends
+	backlog []byte
+	start   int64 // offset of backlog[0] in the whole output
+	stdin   io.WriteCloser
+	resize  func(rows, cols int) error
+	rows    int
+	cols    int
+	closed  bool
+}
+
+// RegisterPTY registers a session of the given size with a new token. Output can be fed right away;
+// input is accepted once the command's stdin is attached.
+func RegisterPTY(sessionID string, rows, cols int) *PTYSession {
+	s := &PTYSession{
+		ID:      sessionID,
+		Token:   newPTYToken(),
+		changed: make(chan struct{}),
+		rows:    rows,
+		cols:    cols,
+	}
 	ptyMu.Lock()
 	defer ptyMu.Unlock()
-	ptySessions[sessionID] = stdin
+	ptySessions[sessionID] = s
+	return s
 }
 
+func newPTYToken() string {
+	b := make([]byte, 18)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// GetPTY returns a registered session.
+func GetPTY(sessionID string) (*PTYSession, error) {
+	ptyMu.RLock()
+	defer ptyMu.RUnlock()
+	s, ok := ptySessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("unknown PTY session: %s", sessionID)
+	}
+	return s, nil
+}
+
+// ClosePTY ends a session: its stdin is closed and clients are told, and it is dropped from the
+// registry after a minute.
 func ClosePTY(sessionID string) {
-	ptyMu.Lock()
-	defer ptyMu.Unlock()
-	if w, ok := ptySessions[sessionID]; ok {
-		_ = w.Close()
-		delete(ptySessions, sessionID)
+	s, err := GetPTY(sessionID)
+	if err != nil {
+		return
 	}
+	s.Close()
+	time.AfterFunc(ptyLinger, func() {
+		ptyMu.Lock()
+		defer ptyMu.Unlock()
+		if ptySessions[sessionID] == s {
+			delete(ptySessions, sessionID)
+		}
+	})
 }
 
-func WritePTYBase64(sessionID string, b64 string) error {
+// WritePTYBase64 sends base64-encoded input to a session.
+func WritePTYBase64(sessionID, token, b64 string) error {
 	data, err := base64.StdEncoding.DecodeString(b64)
 	if err != nil {
 		return fmt.Errorf("invalid base64: %w", err)
 	}
+	s, err := GetPTY(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.Authorize(token); err != nil {
+		return err
+	}
+	if _, err := s.Write(data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
 
-	ptyMu.RLock()
-	w, ok := ptySessions[sessionID]
-	ptyMu.RUnlock()
-	if !ok {
-		return fmt.Errorf("unknown PTY session: %s", sessionID)
+// ResizePTY changes a session's window size.
+func ResizePTY(sessionID, token string, rows, cols int) error {
+	s, err := GetPTY(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.Authorize(token); err != nil {
+		return err
+	}
+	return s.Resize(rows, cols)
+}
+
+// Attach connects the running command: stdin receives input, and resize (optional) applies a new
+// window size, e.g. SSHRunner.WindowChange.
+func (s *PTYSession) Attach(stdin io.WriteCloser, resize func(rows, cols int) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stdin = stdin
+	s.resize = resize
+}
+
+// Authorize checks a client's token.
+func (s *PTYSession) Authorize(token string) error {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		return ErrPTYToken
 	}
+	return nil
+}
 
-	if _, err := w.Write(data); err != nil {
-		return fmt.Errorf("write failed: %w", err)
+// Output appends command output for clients.
+func (s *PTYSession) Output(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || len(p) == 0 {
+		return
+	}
+	s.backlog = append(s.backlog, p...)
+	// Trim in batches rather than on every chunk once the backlog is full.
+	if len(s.backlog) > 2*ptyBacklog {
+		over := len(s.backlog) - ptyBacklog
+		s.backlog = append([]byte(nil), s.backlog[over:]...)
+		s.start += int64(over)
+	}
+	s.notify()
+}
+
+func (s *PTYSession) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// ReadOutput returns the output from offset on and the offset that follows it. When offset is
+// older than the backlog, data starts at the oldest output still kept; from tells where. changed is
+// closed when there is more output or the session ends.
+func (s *PTYSession) ReadOutput(offset int64) (data []byte, from, next int64, closed bool, changed <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.start + int64(len(s.backlog))
+	from = min(max(offset, s.start), end)
+	data = s.backlog[from-s.start:]
+	return data, from, end, s.closed, s.changed
+}
+
+// Write sends input to the command.
+func (s *PTYSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	stdin, closed := s.stdin, s.closed
+	s.mu.Unlock()
+	if closed {
+		return 0, fmt.Errorf("PTY session %s has ended", s.ID)
 	}
+	if stdin == nil {
+		return 0, fmt.Errorf("PTY session %s is not ready", s.ID)
+	}
+	return stdin.Write(p)
+}
+
+// Resize changes the window size. Resizing to the current size is a no-op.
+func (s *PTYSession) Resize(rows, cols int) error {
+	if rows < 1 || cols < 1 || rows > MaxPTYSize || cols > MaxPTYSize {
+		return fmt.Errorf("invalid size %dx%d", cols, rows)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rows == rows && s.cols == cols {
+		return nil
+	}
+	if s.closed {
+		return fmt.Errorf("PTY session %s has ended", s.ID)
+	}
+	if s.resize == nil {
+		return fmt.Errorf("PTY session %s can't be resized", s.ID)
+	}
+	if err := s.resize(rows, cols); err != nil {
+		return fmt.Errorf("resize failed: %w", err)
+	}
+	s.rows, s.cols = rows, cols
 	return nil
 }
+
+// Size returns the current window size.
+func (s *PTYSession) Size() (rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rows, s.cols
+}
+
+// Close closes stdin and tells clients the session ended. Output already received stays readable.
+func (s *PTYSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.stdin != nil {
+		_ = s.stdin.Close()
+	}
+	s.notify()
+}
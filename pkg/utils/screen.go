@@ -0,0 +1,471 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Screen is a minimal terminal emulator: it applies PTY output (text, cursor movement, erasing,
+// scrolling and the alternate screen) to a grid of cells, so prompts can be matched against what a
+// user would see instead of the raw byte stream. Colors and other attributes are ignored, and every
+// rune takes one cell.
+type Screen struct {
+	rows, cols int
+	cells      [][]rune
+	// wrapped marks rows whose text soft-wraps onto the next row.
+	wrapped []bool
+	// Cursor position; wrapNext is set after writing the last column (the cursor stays there until
+	// the next rune wraps).
+	row, col int
+	wrapNext bool
+	// Scroll region, inclusive.
+	top, bottom int
+	savedRow    int
+	savedCol    int
+	// main holds the primary screen while the alternate screen is shown.
+	main        [][]rune
+	mainWrapped []bool
+	// scrolled counts lines that scrolled off the top of the primary screen.
+	scrolled int
+
+	state  int
+	params []byte
+	utf8   []byte
+}
+
+const (
+	screenGround = iota
+	screenEscape
+	screenCSI
+	screenOSC
+	screenOSCEscape
+	screenString // DCS, SOS, PM and APC strings, skipped up to ST
+	screenStringEscape
+	screenCharset
+)
+
+// NewScreen returns a blank screen of the given size.
+func NewScreen(rows, cols int) *Screen {
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	s := &Screen{rows: rows, cols: cols, bottom: rows - 1}
+	s.cells = s.blank()
+	s.wrapped = make([]bool, rows)
+	return s
+}
+
+func (s *Screen) blank() [][]rune {
+	cells := make([][]rune, s.rows)
+	for i := range cells {
+		cells[i] = s.blankLine()
+	}
+	return cells
+}
+
+func (s *Screen) blankLine() []rune {
+	line := make([]rune, s.cols)
+	for i := range line {
+		line[i] = ' '
+	}
+	return line
+}
+
+// Resize changes the screen size, keeping the top-left content.
+func (s *Screen) Resize(rows, cols int) {
+	if rows < 1 || cols < 1 || (rows == s.rows && cols == s.cols) {
+		return
+	}
+	resize := func(old [][]rune) [][]rune {
+		cells := make([][]rune, rows)
+		for r := range cells {
+			line := make([]rune, cols)
+			for c := range line {
+				line[c] = ' '
+				if r < len(old) && c < len(old[r]) {
+					line[c] = old[r][c]
+				}
+			}
+			cells[r] = line
+		}
+		return cells
+	}
+	resizeWrapped := func(old []bool) []bool {
+		wrapped := make([]bool, rows)
+		copy(wrapped, old)
+		return wrapped
+	}
+	s.cells = resize(s.cells)
+	s.wrapped = resizeWrapped(s.wrapped)
+	if s.main != nil {
+		s.main = resize(s.main)
+		s.mainWrapped = resizeWrapped(s.mainWrapped)
+	}
+	s.rows, s.cols = rows, cols
+	s.top, s.bottom = 0, rows-1
+	s.row = min(s.row, rows-1)
+	s.col = min(s.col, cols-1)
+	// The saved cursor is also the primary screen's cursor while the alternate screen is shown.
+	s.savedRow = min(s.savedRow, rows-1)
+	s.savedCol = min(s.savedCol, cols-1)
+	s.wrapNext = false
+}
+
+// Write applies output to the screen. Escape sequences may be split across writes.
+func (s *Screen) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+// String returns the visible screen, one line per row without trailing spaces or trailing empty rows.
+func (s *Screen) String() string {
+	lines := s.Lines()
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	return strings.Join(lines[:end], "\n")
+}
+
+// Text returns the visible screen like String, but with soft-wrapped rows joined into one line, so
+// text reads the same at any width. starts holds the row each line begins on.
+func (s *Screen) Text() (text string, starts []int) {
+	var lines []string
+	var line strings.Builder
+	start := 0
+	for r, cells := range s.cells {
+		if s.wrapped[r] && r < s.rows-1 {
+			// A wrapped row is full; its trailing spaces are part of the text.
+			line.WriteString(string(cells))
+			continue
+		}
+		line.WriteString(strings.TrimRight(string(cells), " "))
+		lines = append(lines, line.String())
+		starts = append(starts, start)
+		line.Reset()
+		start = r + 1
+	}
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	return strings.Join(lines[:end], "\n"), starts[:end]
+}
+
+// Lines returns the visible rows without trailing spaces.
+func (s *Screen) Lines() []string {
+	lines := make([]string, s.rows)
+	for i, line := range s.cells {
+		lines[i] = strings.TrimRight(string(line), " ")
+	}
+	return lines
+}
+
+// Scrolled returns how many lines have scrolled off the top of the primary screen, so
+// Scrolled()+row identifies a line across scrolling.
+func (s *Screen) Scrolled() int {
+	return s.scrolled
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case screenEscape:
+		s.escape(b)
+		return
+	case screenCSI:
+		if b >= 0x40 && b <= 0x7e {
+			s.csi(b)
+			s.state = screenGround
+		} else {
+			s.params = append(s.params, b)
+		}
+		return
+	case screenOSC:
+		switch b {
+		case 0x07:
+			s.state = screenGround
+		case 0x1b:
+			s.state = screenOSCEscape
+		}
+		return
+	case screenOSCEscape:
+		s.state = screenOSC
+		if b == '\\' {
+			s.state = screenGround
+		}
+		return
+	case screenString:
+		if b == 0x1b {
+			s.state = screenStringEscape
+		}
+		return
+	case screenStringEscape:
+		s.state = screenString
+		if b == '\\' {
+			s.state = screenGround
+		}
+		return
+	case screenCharset:
+		s.state = screenGround
+		return
+	}
+
+	if len(s.utf8) > 0 || b >= 0x80 {
+		s.utf8 = append(s.utf8, b)
+		if !utf8.FullRune(s.utf8) {
+			return
+		}
+		r, _ := utf8.DecodeRune(s.utf8)
+		s.utf8 = s.utf8[:0]
+		s.put(r)
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		s.state = screenEscape
+	case '\r':
+		s.col, s.wrapNext = 0, false
+	case '\n', 0x0b, 0x0c:
+		// A newline ends the row's text, whatever wrapped there before.
+		s.wrapped[s.row] = false
+		s.lineFeed()
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapNext = false
+	case '\t':
+		s.col = min((s.col/8+1)*8, s.cols-1)
+	default:
+		if b >= 0x20 && b != 0x7f {
+			s.put(rune(b))
+		}
+	}
+}
+
+func (s *Screen) put(r rune) {
+	if s.wrapNext {
+		s.wrapped[s.row] = true
+		s.col = 0
+		s.lineFeed()
+	}
+	s.cells[s.row][s.col] = r
+	if s.col == s.cols-1 {
+		s.wrapNext = true
+	} else {
+		s.col++
+	}
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	if s.row == s.bottom {
+		s.scrollUp(1)
+	} else if s.row < s.rows-1 {
+		s.row++
+	}
+}
+
+func (s *Screen) scrollUp(n int) {
+	for i := 0; i < n; i++ {
+		copy(s.cells[s.top:s.bottom], s.cells[s.top+1:s.bottom+1])
+		copy(s.wrapped[s.top:s.bottom], s.wrapped[s.top+1:s.bottom+1])
+		s.cells[s.bottom] = s.blankLine()
+		s.wrapped[s.bottom] = false
+		if s.top == 0 && s.main == nil {
+			s.scrolled++
+		}
+	}
+}
+
+func (s *Screen) scrollDown(n int) {
+	for i := 0; i < n; i++ {
+		copy(s.cells[s.top+1:s.bottom+1], s.cells[s.top:s.bottom])
+		copy(s.wrapped[s.top+1:s.bottom+1], s.wrapped[s.top:s.bottom])
+		s.cells[s.top] = s.blankLine()
+		s.wrapped[s.top] = false
+	}
+}
+
+func (s *Screen) escape(b byte) {
+	s.state = screenGround
+	switch b {
+	case '[':
+		s.state = screenCSI
+		s.params = s.params[:0]
+	case ']':
+		s.state = screenOSC
+	case 'P', 'X', '^', '_':
+		s.state = screenString
+	case '(', ')', '*', '+', '#', '%':
+		s.state = screenCharset
+	case '7':
+		s.savedRow, s.savedCol = s.row, s.col
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.col = 0
+		s.lineFeed()
+	case 'M':
+		if s.row == s.top {
+			s.scrollDown(1)
+		} else if s.row > 0 {
+			s.row--
+		}
+	case 'c':
+		*s = *NewScreen(s.rows, s.cols)
+	}
+}
+
+func (s *Screen) csi(final byte) {
+	raw := string(s.params)
+	private := strings.HasPrefix(raw, "?")
+	raw = strings.TrimLeft(raw, "?>=<")
+	var args []int
+	for _, part := range strings.Split(raw, ";") {
+		n, _ := strconv.Atoi(strings.TrimSpace(part))
+		args = append(args, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+	s.wrapNext = false
+
+	switch final {
+	case 'A':
+		s.row = max(s.row-arg(0, 1), 0)
+	case 'B':
+		s.row = min(s.row+arg(0, 1), s.rows-1)
+	case 'C':
+		s.col = min(s.col+arg(0, 1), s.cols-1)
+	case 'D':
+		s.col = max(s.col-arg(0, 1), 0)
+	case 'E':
+		s.row, s.col = min(s.row+arg(0, 1), s.rows-1), 0
+	case 'F':
+		s.row, s.col = max(s.row-arg(0, 1), 0), 0
+	case 'G', '`':
+		s.col = min(arg(0, 1), s.cols) - 1
+	case 'd':
+		s.row = min(arg(0, 1), s.rows) - 1
+	case 'H', 'f':
+		s.row = min(arg(0, 1), s.rows) - 1
+		s.col = min(arg(1, 1), s.cols) - 1
+	case 'J':
+		switch arg(0, 0) {
+		case 0:
+			s.eraseLine(s.row, s.col, s.cols)
+			for r := s.row + 1; r < s.rows; r++ {
+				s.cells[r] = s.blankLine()
+				s.wrapped[r] = false
+			}
+		case 1:
+			for r := 0; r < s.row; r++ {
+				s.cells[r] = s.blankLine()
+				s.wrapped[r] = false
+			}
+			s.eraseLine(s.row, 0, s.col+1)
+		default:
+			s.cells = s.blank()
+			s.wrapped = make([]bool, s.rows)
+		}
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			s.eraseLine(s.row, s.col, s.cols)
+		case 1:
+			s.eraseLine(s.row, 0, s.col+1)
+		default:
+			s.eraseLine(s.row, 0, s.cols)
+		}
+	case 'X':
+		s.eraseLine(s.row, s.col, min(s.col+arg(0, 1), s.cols))
+	case 'P':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-s.col)
+		copy(line[s.col:], line[s.col+n:])
+		s.eraseLine(s.row, s.cols-n, s.cols)
+	case '@':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-s.col)
+		copy(line[s.col+n:], line[s.col:])
+		s.eraseLine(s.row, s.col, s.col+n)
+	case 'L', 'M':
+		if s.row < s.top || s.row > s.bottom {
+			return
+		}
+		top := s.top
+		s.top = s.row
+		if final == 'L' {
+			s.scrollDown(min(arg(0, 1), s.bottom-s.row+1))
+		} else {
+			scrolled := s.scrolled
+			s.scrollUp(min(arg(0, 1), s.bottom-s.row+1))
+			s.scrolled = scrolled
+		}
+		s.top = top
+	case 'S':
+		s.scrollUp(arg(0, 1))
+	case 'T':
+		s.scrollDown(arg(0, 1))
+	case 'r':
+		top, bottom := arg(0, 1)-1, arg(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			s.row, s.col = 0, 0
+		}
+	case 's':
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u':
+		s.restoreCursor()
+	case 'h', 'l':
+		if !private {
+			return
+		}
+		for _, mode := range args {
+			if mode == 47 || mode == 1047 || mode == 1049 {
+				s.alternate(final == 'h')
+			}
+		}
+	}
+}
+
+// alternate switches to (on) or back from the alternate screen used by full-screen programs.
+func (s *Screen) alternate(on bool) {
+	switch {
+	case on && s.main == nil:
+		s.main, s.mainWrapped = s.cells, s.wrapped
+		s.cells, s.wrapped = s.blank(), make([]bool, s.rows)
+		s.savedRow, s.savedCol = s.row, s.col
+	case !on && s.main != nil:
+		s.cells, s.wrapped = s.main, s.mainWrapped
+		s.main, s.mainWrapped = nil, nil
+		s.restoreCursor()
+	}
+}
+
+// restoreCursor moves the cursor to the saved position, kept on the screen.
+func (s *Screen) restoreCursor() {
+	s.row = min(max(s.savedRow, 0), s.rows-1)
+	s.col = min(max(s.savedCol, 0), s.cols-1)
+	s.wrapNext = false
+}
+
+func (s *Screen) eraseLine(row, from, to int) {
+	line := s.cells[row]
+	for c := max(from, 0); c < to && c < len(line); c++ {
+		line[c] = ' '
+	}
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func screenOf(rows, cols int, output string) *Screen {
+	s := NewScreen(rows, cols)
+	s.Write([]byte(output))
+	return s
+}
+
+func TestScreenText(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"lines", "hello\r\nworld", "hello\nworld"},
+		{"carriage return overwrites", "12345\rab", "ab345"},
+		{"erase line", "hello\r\x1b[Kbye", "bye"},
+		{"cursor position", "\x1b[2;3Hx", "\n  x"},
+		{"colors ignored", "\x1b[1;31mred\x1b[0m", "red"},
+		{"title ignored", "\x1b]0;title\x07prompt", "prompt"},
+		{"utf8", "zażółć", "zażółć"},
+		{"clear screen", "junk\x1b[2J\x1b[Hclean", "clean"},
+		{"backspace", "abc\b\bX", "aXc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := screenOf(5, 20, tt.output).String(); got != tt.want {
+				t.Errorf("screen = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenSplitWrites(t *testing.T) {
+	s := NewScreen(3, 20)
+	for _, b := range []byte("\x1b[31mab\x1b[0mł") {
+		s.Write([]byte{b})
+	}
+	if got := s.String(); got != "abł" {
+		t.Errorf("screen = %q, want %q", got, "abł")
+	}
+}
+
+func TestScreenScrolling(t *testing.T) {
+	s := screenOf(2, 10, "one\r\ntwo\r\nthree")
+	if got := s.String(); got != "two\nthree" {
+		t.Errorf("screen = %q", got)
+	}
+	if s.Scrolled() != 1 {
+		t.Errorf("Scrolled() = %d, want 1", s.Scrolled())
+	}
+}
+
+func TestScreenAlternate(t *testing.T) {
+	s := screenOf(3, 10, "shell$ \x1b[?1049hfull\x1b[?1049l")
+	if got := s.String(); got != "shell$" {
+		t.Errorf("screen = %q, want the primary screen back", got)
+	}
+}
+
+// Restoring a cursor saved beyond a smaller screen must not write out of bounds.
+func TestScreenResizeClampsSavedCursor(t *testing.T) {
+	restores := map[string]string{
+		"leave alternate screen": "\x1b[?1049l",
+		"ESC 8":                  "\x1b8",
+		"CSI u":                  "\x1b[u",
+	}
+	for name, restore := range restores {
+		t.Run(name, func(t *testing.T) {
+			s := NewScreen(40, 120)
+			s.Write([]byte("\x1b[40;100H\x1b7\x1b[s\x1b[?1049h"))
+			s.Resize(24, 80)
+			s.Write([]byte(restore + "x"))
+			s.Write([]byte("\x1b[?1049l" + restore + "y"))
+			if s.row >= 24 || s.col >= 80 {
+				t.Fatalf("cursor at %d,%d on a 24x80 screen", s.row, s.col)
+			}
+		})
+	}
+}
+
+func TestScreenResizeKeepsContent(t *testing.T) {
+	s := screenOf(3, 10, "abcdef\r\nghi")
+	s.Resize(2, 4)
+	if got := s.String(); got != "abcd\nghi" {
+		t.Errorf("screen = %q", got)
+	}
+	s.Write([]byte("\r\n\r\nend"))
+	if got := s.String(); got != "\nend" {
+		t.Errorf("screen after scrolling = %q, want %q", got, "\nend")
+	}
+}
+
+func TestScreenTextJoinsWrappedRows(t *testing.T) {
+	s := screenOf(5, 10, "first\r\n0123456789abcdef\r\nlast")
+	text, starts := s.Text()
+	if text != "first\n0123456789abcdef\nlast" {
+		t.Errorf("Text() = %q", text)
+	}
+	if fmt.Sprint(starts) != "[0 1 3]" {
+		t.Errorf("starts = %v, want [0 1 3]", starts)
+	}
+	if got := s.String(); got != "first\n0123456789\nabcdef\nlast" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Wrapping moves with scrolling, and a newline ends it.
+	s = screenOf(2, 4, "abcdefg\r\nhi")
+	if text, _ := s.Text(); text != "efg\nhi" {
+		t.Errorf("after scrolling Text() = %q", text)
+	}
+	s = screenOf(3, 4, "abcdefg\x1b[H\x1b[2Jxy\r\nz")
+	if text, _ := s.Text(); text != "xy\nz" {
+		t.Errorf("after clearing Text() = %q", text)
+	}
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	charsetAlpha   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	charsetNumeric = "0123456789"
+	charsetHex     = "0123456789abcdef"
+	charsetSymbols = "!#%+,-.:=@^_~"
+)
+
+// GenerateSecret returns a random secret.
+// If encoding is set (hex, base64, base64url), length random bytes are generated and encoded.
+// Otherwise length characters are picked from charset (alnum, alpha, numeric, hex, symbols).
+func GenerateSecret(length int, charset, encoding string) (string, error) {
+	if length <= 0 {
+		length = 32
+	}
+
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding != "" {
+		buf := make([]byte, length)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		switch encoding {
+		case "hex":
+			return hex.EncodeToString(buf), nil
+		case "base64":
+			return base64.StdEncoding.EncodeToString(buf), nil
+		case "base64url":
+			return base64.RawURLEncoding.EncodeToString(buf), nil
+		default:
+			return "", fmt.Errorf("unknown secret encoding: %s", encoding)
+		}
+	}
+
+	var alphabet string
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "alnum":
+		alphabet = charsetAlpha + charsetNumeric
+	case "alpha":
+		alphabet = charsetAlpha
+	case "numeric":
+		alphabet = charsetNumeric
+	case "hex":
+		alphabet = charsetHex
+	case "symbols":
+		alphabet = charsetAlpha + charsetNumeric + charsetSymbols
+	default:
+		return "", fmt.Errorf("unknown secret charset: %s", charset)
+	}
+
+	max := big.NewInt(int64(len(alphabet)))
+	out := make([]byte, length)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// Redactor replaces known secret values with a placeholder.
+// It is safe for concurrent use so it can wrap loggers shared by goroutines (PTY readers, SSE writers).
+type Redactor struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+const redactedPlaceholder = "********"
+
+// NewRedactor creates a redactor for the given secret values. Empty values are ignored.
+func NewRedactor(values ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(values...)
+	return r
+}
+
+// Add registers more secret values to redact.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		// Very short values would redact unrelated output; generated secrets are never this short.
+		if len(v) < 4 {
+			continue
+		}
+		r.values = append(r.values, v)
+	}
+	// Replace longest values first so a secret containing another one is fully hidden.
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Redact returns s with every known secret replaced.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, v := range r.values {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, redactedPlaceholder)
+		}
+	}
+	return s
+}
+
+// Stream returns a redactor for output that arrives in chunks, such as a PTY.
+func (r *Redactor) Stream() *RedactStream {
+	return &RedactStream{r: r}
+}
+
+// RedactStream redacts a chunked stream, so a secret split across chunks is still hidden. It
+// holds back the end of a chunk while it could be the start of a secret (at most the longest
+// secret's length minus one byte) until the next chunk or Flush. It is safe for concurrent use.
+type RedactStream struct {
+	r       *Redactor
+	mu      sync.Mutex
+	pending []byte
+}
+
+// Redact returns the redacted part of the stream so far that can no longer change.
+func (s *RedactStream) Redact(p []byte) []byte {
+	if s == nil || s.r == nil {
+		return p
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.pending, p...)
+	cut := s.r.safeCut(data)
+	s.pending = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return nil
+	}
+	return []byte(s.r.Redact(string(data[:cut])))
+}
+
+// Flush returns the redacted rest of the stream. Call it when the stream ends.
+func (s *RedactStream) Flush() []byte {
+	if s == nil || s.r == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := s.pending
+	s.pending = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(s.r.Redact(string(data)))
+}
+
+// safeCut returns how much of data can be redacted now: everything before a tail that could begin
+// a secret, moved back past any secret crossing it.
+func (r *Redactor) safeCut(data []byte) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	text := string(data)
+	cut := len(text)
+	for _, v := range r.values {
+		for k := min(len(v)-1, len(text)); k > 0; k-- {
+			if len(text)-k < cut && strings.HasSuffix(text, v[:k]) {
+				cut = len(text) - k
+				break
+			}
+		}
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, v := range r.values {
+			for i := max(0, cut-len(v)+1); i < cut; i++ {
+				if strings.HasPrefix(text[i:], v) {
+					cut, moved = i, true
+					break
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// Logger wraps a printf-style logger so every formatted line is redacted before it is emitted.
+func (r *Redactor) Logger(logf func(string, ...interface{})) func(string, ...interface{}) {
+	if r == nil || logf == nil {
+		return logf
+	}
+	return func(format string, a ...interface{}) {
+		logf("%s", r.Redact(fmt.Sprintf(format, a...)))
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactStream(t *testing.T) {
+	r := NewRedactor("s3cr3t-value", "hunter2")
+	input := "login: admin\npassword: s3cr3t-value and hunter2hunter2, done\n"
+	want := r.Redact(input)
+
+	// Every way of splitting the input into three chunks gives the same output.
+	for i := 0; i <= len(input); i++ {
+		for j := i; j <= len(input); j++ {
+			s := r.Stream()
+			var got []byte
+			for _, chunk := range []string{input[:i], input[i:j], input[j:]} {
+				out := s.Redact([]byte(chunk))
+				if strings.Contains(string(out), "hunter2") || strings.Contains(string(out), "s3cr3t") {
+					t.Fatalf("split %d/%d: chunk leaks a secret: %q", i, j, out)
+				}
+				got = append(got, out...)
+			}
+			got = append(got, s.Flush()...)
+			if string(got) != want {
+				t.Fatalf("split %d/%d: got %q, want %q", i, j, got, want)
+			}
+		}
+	}
+
+	// Only a tail that could begin a secret is held back.
+	s := r.Stream()
+	if got := string(s.Redact([]byte("Password: "))); got != "Password: " {
+		t.Errorf("prompt held back: got %q", got)
+	}
+	if got := string(s.Redact([]byte("ok s3cr"))); got != "ok " {
+		t.Errorf("got %q, want the possible secret start held back", got)
+	}
+	if got := string(s.Flush()); got != "s3cr" {
+		t.Errorf("Flush() = %q, want %q", got, "s3cr")
+	}
+
+	var nilRedactor *Redactor
+	if got := string(nilRedactor.Stream().Redact([]byte("plain"))); got != "plain" {
+		t.Errorf("nil redactor stream: got %q", got)
+	}
+}
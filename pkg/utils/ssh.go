@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -18,6 +19,10 @@ type SSHRunner struct {
 	privateKey string
 	client     *ssh.Client
 	logger     func(string, ...interface{}) // Optional logger for streaming output
+	redactor   *Redactor                    // Optional; hides secrets from console mirrors
+
+	ptyMu sync.Mutex
+	pty   *ssh.Session // The running PTY command, resized by WindowChange
 }
 
 // NewSSHRunner creates a new SSH runner
@@ -34,6 +39,23 @@ func (r *SSHRunner) SetLogger(logger func(string, ...interface{})) {
 	r.logger = logger
 }
 
+// SetRedactor sets a redactor applied to output mirrored to the local console and to PTY output
+// mirrored to the logger. The logger passed to SetLogger is expected to redact on its own (see
+// Redactor.Logger).
+func (r *SSHRunner) SetRedactor(redactor *Redactor) {
+	r.redactor = redactor
+}
+
+// consoleWriter returns w, or a line-buffered redacting wrapper around it when a redactor is set.
+func (r *SSHRunner) consoleWriter(w io.Writer) io.Writer {
+	if r.redactor == nil {
+		return w
+	}
+	return &streamWriter{logger: func(format string, a ...interface{}) {
+		fmt.Fprint(w, r.redactor.Redact(fmt.Sprintf(format, a...)))
+	}}
+}
+
 // Connect establishes SSH connection
 func (r *SSHRunner) Connect() error {
 	signer, err := ssh.ParsePrivateKey([]byte(r.privateKey))
@@ -67,44 +89,89 @@ func (r *SSHRunner) Close() error {
 	return nil
 }
 
+// WithLogger returns a runner sharing r's connection but streaming output to logger.
+// It is meant for concurrent steps; only the original runner should be closed.
+func (r *SSHRunner) WithLogger(logger func(string, ...interface{})) *SSHRunner {
+	return &SSHRunner{
+		host:       r.host,
+		user:       r.user,
+		privateKey: r.privateKey,
+		client:     r.client,
+		logger:     logger,
+		redactor:   r.redactor,
+	}
+}
+
 // Run executes a single command
 func (r *SSHRunner) Run(command string) error {
+	return r.run(context.Background(), command, nil)
+}
+
+// RunContext is like Run, but kills the remote command when ctx is cancelled.
+func (r *SSHRunner) RunContext(ctx context.Context, command string) error {
+	return r.run(ctx, command, nil)
+}
+
+// run executes command, streaming output to the logger (or the console) and, when capture is set,
+// also copying stdout into it.
+func (r *SSHRunner) run(ctx context.Context, command string, capture io.Writer) error {
 	session, err := r.client.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+
+	stdoutMirror := r.consoleWriter(os.Stdout)
+	stderrMirror := r.consoleWriter(os.Stderr)
+	stdout := []io.Writer{stdoutMirror}
+	stderr := []io.Writer{stderrMirror}
+	if capture != nil {
+		stdout = append(stdout, capture)
+	}
+
+	var stdoutWriter, stderrWriter *streamWriter
 	if r.logger != nil {
 		// Use logger to capture output
 		r.logger("Running: %s\n", command)
 
 		// Create a writer that streams to logger
-		stdoutWriter := &streamWriter{logger: r.logger}
-		stderrWriter := &streamWriter{logger: r.logger}
-
-		session.Stdout = io.MultiWriter(stdoutWriter, os.Stdout)
-		session.Stderr = io.MultiWriter(stderrWriter, os.Stderr)
+		stdoutWriter = &streamWriter{logger: r.logger}
+		stderrWriter = &streamWriter{logger: r.logger}
+		stdout = append(stdout, stdoutWriter)
+		stderr = append(stderr, stderrWriter)
+	} else {
+		fmt.Printf("Running: %s\n", r.redactor.Redact(command))
+	}
+	session.Stdout = io.MultiWriter(stdout...)
+	session.Stderr = io.MultiWriter(stderr...)
 
-		err := session.Run(command)
+	err = session.Run(command)
 
-		// Flush any remaining buffer
+	// Flush any remaining buffer
+	if stdoutWriter != nil {
 		stdoutWriter.Flush()
 		stderrWriter.Flush()
-
-		if err != nil {
-			return fmt.Errorf("command failed: %w", err)
-		}
-	} else {
-		// Fallback to original behavior
-		session.Stdout = os.Stdout
-		session.Stderr = os.Stderr
-		fmt.Printf("Running: %s\n", command)
-		if err := session.Run(command); err != nil {
-			return fmt.Errorf("command failed: %w", err)
-		}
 	}
+	flushWriter(stdoutMirror)
+	flushWriter(stderrMirror)
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
 	return nil
 }
 
@@ -154,6 +221,12 @@ func (w *streamWriter) Flush() {
 	}
 }
 
+func flushWriter(w io.Writer) {
+	if sw, ok := w.(*streamWriter); ok {
+		sw.Flush()
+	}
+}
+
 // RunMultiple executes multiple commands sequentially
 func (r *SSHRunner) RunMultiple(commands []string) error {
 	for _, cmd := range commands {
@@ -164,30 +237,94 @@ func (r *SSHRunner) RunMultiple(commands []string) error {
 	return nil
 }
 
-// RunWithOutput executes a command and returns its output
+// RunWithOutput executes a command and returns its stdout, streaming output like Run.
 func (r *SSHRunner) RunWithOutput(command string) (string, error) {
+	return r.RunWithOutputContext(context.Background(), command)
+}
+
+// RunWithOutputContext is like RunWithOutput, but kills the remote command when ctx is cancelled.
+func (r *SSHRunner) RunWithOutputContext(ctx context.Context, command string) (string, error) {
+	var stdout strings.Builder
+	err := r.run(ctx, command, &stdout)
+	return stdout.String(), err
+}
+
+// RunSilent executes a command and returns its combined output without logging or mirroring it.
+// Use it for polling and for commands whose text shouldn't appear in logs.
+func (r *SSHRunner) RunSilent(command string) (string, error) {
 	session, err := r.client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
-	var stdout strings.Builder
-	session.Stdout = io.MultiWriter(&stdout, os.Stdout)
-	session.Stderr = os.Stderr
+	out, err := session.CombinedOutput(command)
+	if err != nil {
+		return string(out), fmt.Errorf("command failed: %w", err)
+	}
+	return string(out), nil
+}
 
-	fmt.Printf("Running: %s\n", command)
-	if err := session.Run(command); err != nil {
-		return "", fmt.Errorf("command failed: %w", err)
+// RunSilentStdout is like RunSilent, but returns only stdout, for output that gets parsed.
+// Stderr is added to the error when the command fails.
+func (r *SSHRunner) RunSilentStdout(command string) (string, error) {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
 	}
+	defer session.Close()
 
+	var stdout, stderr strings.Builder
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return stdout.String(), fmt.Errorf("command failed: %w", err)
+	}
 	return stdout.String(), nil
 }
 
-// RunPTY executes a command in a PTY, suitable for interactive/TUI installers.
-// It streams raw output (including ANSI escape codes) to onData if provided.
+// PTYRows, PTYCols and PTYTerm are the default terminal size and type requested for PTY commands.
+const (
+	PTYRows = 40
+	PTYCols = 120
+	PTYTerm = "xterm-256color"
+)
+
+// PTYOptions sets up the terminal of a PTY command. Zero values use the defaults.
+type PTYOptions struct {
+	Term string
+	Rows int
+	Cols int
+}
+
+// WithDefaults fills in unset options.
+func (o PTYOptions) WithDefaults() PTYOptions {
+	if o.Term == "" {
+		o.Term = PTYTerm
+	}
+	if o.Rows <= 0 {
+		o.Rows = PTYRows
+	}
+	if o.Cols <= 0 {
+		o.Cols = PTYCols
+	}
+	return o
+}
+
+// RunPTY executes a command in a PTY, suitable for interactive/TUI installers; an empty command
+// starts the user's login shell. It streams raw output (including ANSI escape codes) to onData if provided.
 // Returns stdin writer to send user keystrokes, and a wait func to wait for completion.
 func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser, func() error, error) {
+	return r.RunPTYContext(context.Background(), command, PTYOptions{}, onData)
+}
+
+// RunPTYContext is like RunPTY with terminal options, and kills the remote command when ctx is
+// cancelled; wait then returns the context's cause.
+func (r *SSHRunner) RunPTYContext(ctx context.Context, command string, opts PTYOptions, onData func([]byte)) (io.WriteCloser, func() error, error) {
+	opts = opts.WithDefaults()
 	session, err := r.client.NewSession()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create session: %w", err)
@@ -199,7 +336,7 @@ func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser,
 		ssh.TTY_OP_ISPEED: 14400,
 		ssh.TTY_OP_OSPEED: 14400,
 	}
-	if err := session.RequestPty("xterm-256color", 40, 120, modes); err != nil {
+	if err := session.RequestPty(opts.Term, opts.Rows, opts.Cols, modes); err != nil {
 		_ = session.Close()
 		return nil, nil, fmt.Errorf("failed to request pty: %w", err)
 	}
@@ -220,17 +357,27 @@ func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser,
 		return nil, nil, fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
-	// Start command
-	if err := session.Start(command); err != nil {
+	// Start command, or the user's login shell
+	start := func() error { return session.Start(command) }
+	if command == "" {
+		start = session.Shell
+	}
+	if err := start(); err != nil {
 		_ = session.Close()
 		return nil, nil, fmt.Errorf("failed to start command: %w", err)
 	}
 
+	r.ptyMu.Lock()
+	r.pty = session
+	r.ptyMu.Unlock()
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	readPipe := func(rdr io.Reader) {
 		defer wg.Done()
+		// The logger redacts each call; the stream also catches secrets split across reads.
+		mirror := r.redactor.Stream()
 		buf := make([]byte, 4096)
 		for {
 			n, err := rdr.Read(buf)
@@ -245,10 +392,15 @@ func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser,
 				// also mirror to stdout for debugging if desired
 				if r.logger != nil {
 					// best-effort: log raw bytes as string (may include ansi)
-					r.logger("%s", string(chunk))
+					if out := mirror.Redact(chunk); len(out) > 0 {
+						r.logger("%s", string(out))
+					}
 				}
 			}
 			if err != nil {
+				if rest := mirror.Flush(); r.logger != nil && len(rest) > 0 {
+					r.logger("%s", string(rest))
+				}
 				return
 			}
 		}
@@ -257,11 +409,30 @@ func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser,
 	go readPipe(stdout)
 	go readPipe(stderr)
 
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+
 	wait := func() error {
 		err := session.Wait()
+		close(done)
 		wg.Wait()
+		r.ptyMu.Lock()
+		if r.pty == session {
+			r.pty = nil
+		}
+		r.ptyMu.Unlock()
 		_ = stdin.Close()
 		_ = session.Close()
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
 		if err != nil {
 			// Include command for context, but avoid huge strings
 			cmdPreview := command
@@ -277,6 +448,17 @@ func (r *SSHRunner) RunPTY(command string, onData func([]byte)) (io.WriteCloser,
 	return nopWriteCloser{Writer: stdin}, wait, nil
 }
 
+// WindowChange resizes the terminal of the running PTY command.
+func (r *SSHRunner) WindowChange(rows, cols int) error {
+	r.ptyMu.Lock()
+	session := r.pty
+	r.ptyMu.Unlock()
+	if session == nil {
+		return fmt.Errorf("no PTY command is running")
+	}
+	return session.WindowChange(rows, cols)
+}
+
 type nopWriteCloser struct{ io.Writer }
 
 func (n nopWriteCloser) Close() error { return nil }